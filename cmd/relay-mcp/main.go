@@ -10,7 +10,9 @@ import (
     "github.com/joho/godotenv"
     "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
     "github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+    "github.com/Narcoleptic-Fox/relay-mcp/internal/redact"
     "github.com/Narcoleptic-Fox/relay-mcp/internal/server"
+    "github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
 )
 
 func main() {
@@ -23,9 +25,9 @@ func main() {
         logLevel = slog.LevelDebug
     }
 
-    logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+    logger := slog.New(redact.NewHandler(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
         Level: logLevel,
-    }))
+    })))
     slog.SetDefault(logger)
 
     // Load configuration
@@ -34,6 +36,7 @@ func main() {
         slog.Error("failed to load configuration", "error", err)
         os.Exit(1)
     }
+    redact.SetSecrets(cfg.Secrets()...)
 
     // Initialize provider registry
     registry := providers.NewRegistry(cfg)
@@ -42,6 +45,12 @@ func main() {
         os.Exit(1)
     }
 
+    // Without embedded vocabularies every OpenAI token count is an estimate
+    if missing := tokenizer.Missing(); len(missing) > 0 {
+        slog.Error("tokenizer vocabularies not embedded; token counts for OpenAI models are character-based estimates, so context trimming and TPM limits are approximate",
+            "missing", missing, "fix", "run 'go generate ./internal/tokenizer' and rebuild")
+    }
+
     // Create MCP server
     srv := server.New(cfg, registry)
 
@@ -0,0 +1,125 @@
+// Package agent lets models read the working directory during a call. The
+// model is offered sandboxed functions (read_file, list_dir, grep); relay
+// runs the calls it makes and sends the results back, until the model
+// answers or the iteration or token cap is reached.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Options bounds an agent run
+type Options struct {
+	MaxIterations int // rounds of function calls before the model must answer
+	MaxTokens     int // total tokens across all calls before the model must answer; 0 is unlimited
+}
+
+// Generate calls the provider, running the agent loop when it's enabled, a
+// working directory is given and the model supports function calling.
+// Otherwise it's a plain call.
+func Generate(
+	ctx context.Context,
+	cfg *config.Config,
+	p providers.Provider,
+	req *providers.GenerateRequest,
+	workDir string,
+) (*types.ModelResponse, error) {
+	if !cfg.AgentTools || workDir == "" || cfg.AgentMaxIterations <= 0 {
+		return p.GenerateContent(ctx, req)
+	}
+	if caps, err := p.GetCapabilities(req.Model); err != nil || !caps.SupportsFunctionCalling {
+		return p.GenerateContent(ctx, req)
+	}
+
+	sandbox, err := NewSandbox(workDir)
+	if err != nil {
+		slog.Warn("agent tools disabled for this call", "workdir", workDir, "error", err)
+		return p.GenerateContent(ctx, req)
+	}
+
+	return Run(ctx, p, req, sandbox, Options{
+		MaxIterations: cfg.AgentMaxIterations,
+		MaxTokens:     cfg.AgentMaxTokens,
+	})
+}
+
+// Run generates a response while serving the sandbox's functions to the
+// model. Each round of calls is executed and appended to the request; once
+// a cap is reached the functions are withdrawn and the model has to answer.
+// The returned usage and cost cover every call, and the agent_tool_calls
+// metadata lists the calls made.
+func Run(
+	ctx context.Context,
+	p providers.Provider,
+	req *providers.GenerateRequest,
+	sandbox *Sandbox,
+	opts Options,
+) (*types.ModelResponse, error) {
+	call := *req
+	call.Tools = append(slices.Clone(req.Tools), sandbox.Functions()...)
+
+	var usage types.TokenUsage
+	var cost float64
+	var log []string
+
+	for round := 0; ; round++ {
+		capped := round >= opts.MaxIterations || (opts.MaxTokens > 0 && usage.TotalTokens >= opts.MaxTokens)
+		if capped {
+			call.ToolChoice = providers.ToolChoiceNone
+		}
+
+		resp, err := p.GenerateContent(ctx, &call)
+		if err != nil {
+			return nil, err
+		}
+
+		usage.PromptTokens += resp.TokensUsed.PromptTokens
+		usage.CompletionTokens += resp.TokensUsed.CompletionTokens
+		usage.TotalTokens += resp.TokensUsed.TotalTokens
+		usage.ThinkingTokens += resp.TokensUsed.ThinkingTokens
+		usage.CachedTokens += resp.TokensUsed.CachedTokens
+		cost += resp.CostUSD
+
+		if len(resp.ToolCalls) == 0 || capped {
+			resp.ToolCalls = nil
+			resp.TokensUsed = usage
+			resp.CostUSD = cost
+			if len(log) > 0 {
+				setMetadata(resp, "agent_tool_calls", log)
+				setMetadata(resp, "agent_rounds", round)
+			}
+			return resp, nil
+		}
+
+		exchange := providers.ToolExchange{Content: resp.Content, Calls: resp.ToolCalls}
+		for _, tc := range resp.ToolCalls {
+			out, err := sandbox.Call(tc.Name, tc.Arguments)
+			if err != nil {
+				out = "Error: " + err.Error()
+			}
+			slog.Debug("agent tool call", "model", resp.Model, "function", tc.Name, "arguments", tc.Arguments, "error", err)
+
+			log = append(log, fmt.Sprintf("%s %s", tc.Name, tc.Arguments))
+			exchange.Results = append(exchange.Results, providers.ToolResult{
+				CallID:  tc.ID,
+				Name:    tc.Name,
+				Content: out,
+			})
+		}
+		call.ToolTurns = append(slices.Clone(call.ToolTurns), exchange)
+	}
+}
+
+func setMetadata(resp *types.ModelResponse, key string, value any) {
+	if resp.Metadata == nil {
+		resp.Metadata = make(map[string]any)
+	}
+	resp.Metadata[key] = value
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// newToolServer answers with a read_file call until it has received
+// results for calls tool rounds, then with a final answer. Every request
+// body is recorded.
+func newToolServer(t *testing.T, calls int, bodies *[]map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		*bodies = append(*bodies, body)
+
+		usage := `"usage":{"prompt_tokens":100,"completion_tokens":10,"total_tokens":110}`
+		if len(*bodies) <= calls && body["tool_choice"] != "none" {
+			fmt.Fprintf(w, `{"choices":[{"finish_reason":"tool_calls","message":{"role":"assistant",
+				"tool_calls":[{"id":"call_%d","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"main.go\"}"}}]}}],%s}`,
+				len(*bodies), usage)
+			return
+		}
+		fmt.Fprintf(w, `{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"main prints nothing"}}],%s}`, usage)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newToolProvider(url string) providers.Provider {
+	return providers.NewOpenAICompatProvider(types.ProviderCustom, "", url, []types.ModelCapabilities{
+		{ModelName: "local", SupportsFunctionCalling: true},
+	}, 0)
+}
+
+func TestRun_ServesFunctions(t *testing.T) {
+	s, _ := newTestSandbox(t)
+	var bodies []map[string]any
+	server := newToolServer(t, 1, &bodies)
+
+	resp, err := Run(context.Background(), newToolProvider(server.URL), &providers.GenerateRequest{
+		Prompt: "what does main do?",
+		Model:  "local",
+	}, s, Options{MaxIterations: 5})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(bodies))
+	}
+	if tools := bodies[0]["tools"].([]any); len(tools) != 3 {
+		t.Errorf("expected the three sandbox functions, got %v", tools)
+	}
+	messages := bodies[1]["messages"].([]any)
+	result := messages[len(messages)-1].(map[string]any)
+	if result["role"] != "tool" || !strings.Contains(result["content"].(string), "func main()") {
+		t.Errorf("expected the file contents as a tool result, got %v", result)
+	}
+
+	if resp.Content != "main prints nothing" || resp.TokensUsed.TotalTokens != 220 {
+		t.Errorf("unexpected response %q with usage %+v", resp.Content, resp.TokensUsed)
+	}
+	if calls := resp.Metadata["agent_tool_calls"].([]string); len(calls) != 1 || calls[0] != `read_file {"path":"main.go"}` {
+		t.Errorf("unexpected call log %v", resp.Metadata)
+	}
+}
+
+func TestRun_Caps(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      Options
+		wantCalls int
+	}{
+		{"iterations", Options{MaxIterations: 2}, 3},
+		{"tokens", Options{MaxIterations: 10, MaxTokens: 200}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestSandbox(t)
+			var bodies []map[string]any
+			server := newToolServer(t, 100, &bodies)
+
+			resp, err := Run(context.Background(), newToolProvider(server.URL), &providers.GenerateRequest{
+				Prompt: "keep reading",
+				Model:  "local",
+			}, s, tt.opts)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if len(bodies) != tt.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tt.wantCalls, len(bodies))
+			}
+			if last := bodies[len(bodies)-1]; last["tool_choice"] != "none" {
+				t.Errorf("expected the final call to forbid tools, got %v", last["tool_choice"])
+			}
+			if resp.Content != "main prints nothing" || resp.ToolCalls != nil {
+				t.Errorf("unexpected response %+v", resp)
+			}
+		})
+	}
+}
+
+func TestGenerate_SkipsUnsupportedModels(t *testing.T) {
+	s, _ := newTestSandbox(t)
+	var bodies []map[string]any
+	server := newToolServer(t, 1, &bodies)
+
+	p := providers.NewOpenAICompatProvider(types.ProviderCustom, "", server.URL, []types.ModelCapabilities{
+		{ModelName: "plain"},
+	}, 0)
+	cfg := &config.Config{AgentTools: true, AgentMaxIterations: 3}
+
+	if _, err := Generate(context.Background(), cfg, p, &providers.GenerateRequest{Prompt: "hi", Model: "plain"}, s.Root()); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, ok := bodies[0]["tools"]; ok {
+		t.Error("models without function calling should not be offered tools")
+	}
+}
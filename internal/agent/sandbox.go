@@ -0,0 +1,405 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/redact"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+// Limits on what a single function call returns
+const (
+	maxFileSize      = 1024 * 1024 // files larger than this aren't read or searched
+	defaultReadLines = 400
+	maxReadLines     = 2000
+	maxDirEntries    = 500
+	maxGrepMatches   = 100
+	maxLineLength    = 300
+	maxResultBytes   = 64 * 1024
+)
+
+// skipDirs are never read, listed or searched, along with anything whose
+// name starts with a dot (.env, .git, .ssh, ...)
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"__pycache__":  true,
+}
+
+// secretFiles match names of key and credential files that are refused even
+// without a leading dot
+var secretFiles = []string{
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	"id_rsa*", "id_dsa*", "id_ecdsa*", "id_ed25519*",
+	"credentials*.json", "*.tfstate", "*.tfvars",
+}
+
+// hidden reports whether a path relative to the root is off limits: a
+// dotfile or dot directory, a skipped directory, or a key or credential
+// file, at any level
+func hidden(rel string) bool {
+	if rel == "." {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if (strings.HasPrefix(part, ".") && part != "..") || skipDirs[part] {
+			return true
+		}
+	}
+	name := filepath.Base(rel)
+	for _, pattern := range secretFiles {
+		if ok, _ := filepath.Match(pattern, strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Sandbox serves read-only file functions under a root directory. Paths
+// are resolved relative to the root, and anything that resolves outside
+// it, including through symlinks, is refused, as are hidden paths such as
+// .env, .git and key files.
+type Sandbox struct {
+	root string
+}
+
+// NewSandbox creates a sandbox rooted at dir
+func NewSandbox(dir string) (*Sandbox, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving working directory: %w", err)
+	}
+	root, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("resolving working directory: %w", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("working directory %s is not a directory", dir)
+	}
+	return &Sandbox{root: root}, nil
+}
+
+// Root returns the sandbox's root directory
+func (s *Sandbox) Root() string {
+	return s.root
+}
+
+// resolve maps a path from the model to an absolute path inside the root
+func (s *Sandbox) resolve(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+
+	p := path
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.root, p)
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(p))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%s does not exist", path)
+		}
+		return "", err
+	}
+
+	if !s.contains(resolved) {
+		return "", fmt.Errorf("%s is outside the working directory", path)
+	}
+	// Both the path as given and its target, so a link can't expose a
+	// hidden file and a hidden link can't be read through
+	if hidden(s.rel(filepath.Clean(p))) || hidden(s.rel(resolved)) {
+		return "", fmt.Errorf("%s is not available to the agent (dotfiles, dependency directories and key files are hidden)", path)
+	}
+	return resolved, nil
+}
+
+func (s *Sandbox) contains(path string) bool {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// rel returns a path relative to the root for display
+func (s *Sandbox) rel(path string) string {
+	if rel, err := filepath.Rel(s.root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// ReadFile returns numbered lines of a text file, starting at line offset
+// (1-based) and returning at most limit lines
+func (s *Sandbox) ReadFile(path string, offset, limit int) (string, error) {
+	resolved, err := s.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory; use list_dir", path)
+	}
+	if info.Size() > maxFileSize {
+		return "", fmt.Errorf("%s is too large to read (%d bytes)", path, info.Size())
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+	if isBinary(resolved, data) {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+
+	if offset < 1 {
+		offset = 1
+	}
+	if limit <= 0 {
+		limit = defaultReadLines
+	}
+	limit = min(limit, maxReadLines)
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if offset > len(lines) {
+		return "", fmt.Errorf("%s has only %d lines", path, len(lines))
+	}
+	end := min(offset-1+limit, len(lines))
+
+	var sb strings.Builder
+	for i := offset - 1; i < end; i++ {
+		sb.WriteString(fmt.Sprintf("%6d\t%s\n", i+1, lines[i]))
+	}
+	if offset > 1 || end < len(lines) {
+		sb.WriteString(fmt.Sprintf("[lines %d-%d of %d]\n", offset, end, len(lines)))
+	}
+
+	return sb.String(), nil
+}
+
+// ListDir lists a directory's entries, marking subdirectories with a
+// trailing slash. Hidden entries are left out.
+func (s *Sandbox) ListDir(path string) (string, error) {
+	resolved, err := s.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	all, err := os.ReadDir(resolved)
+	if err != nil {
+		return "", err
+	}
+	var entries []fs.DirEntry
+	for _, e := range all {
+		if !hidden(s.rel(filepath.Join(resolved, e.Name()))) {
+			entries = append(entries, e)
+		}
+	}
+
+	var sb strings.Builder
+	for i, e := range entries {
+		if i == maxDirEntries {
+			sb.WriteString(fmt.Sprintf("[%d more entries not shown]\n", len(entries)-maxDirEntries))
+			break
+		}
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		sb.WriteString(name)
+		sb.WriteString("\n")
+	}
+	if len(entries) == 0 {
+		return "(empty directory)\n", nil
+	}
+
+	return sb.String(), nil
+}
+
+// Grep searches text files under path for a regular expression. An
+// optional glob such as "*.go" filters files by name.
+func (s *Sandbox) Grep(pattern, path, glob string) (string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	if glob != "" {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return "", fmt.Errorf("invalid glob: %w", err)
+		}
+	}
+
+	start, err := s.resolve(path)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	matches := 0
+
+	walkErr := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+		if p != start && hidden(s.rel(p)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Symlinks could point outside the root; WalkDir doesn't follow them
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if glob != "" {
+			if ok, _ := filepath.Match(glob, d.Name()); !ok {
+				return nil
+			}
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil || isBinary(p, data) {
+			return nil
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxFileSize)
+		for line := 1; scanner.Scan(); line++ {
+			if !re.Match(scanner.Bytes()) {
+				continue
+			}
+			matches++
+			if matches > maxGrepMatches {
+				return fs.SkipAll
+			}
+			sb.WriteString(fmt.Sprintf("%s:%d: %s\n", s.rel(p), line, utils.Truncate(strings.TrimSpace(scanner.Text()), maxLineLength)))
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return "", walkErr
+	}
+
+	switch {
+	case matches == 0:
+		return "No matches.\n", nil
+	case matches > maxGrepMatches:
+		sb.WriteString(fmt.Sprintf("[stopped after %d matches; narrow the pattern or path]\n", maxGrepMatches))
+	}
+	return sb.String(), nil
+}
+
+// isBinary checks the extension and looks for NUL bytes near the start
+func isBinary(path string, data []byte) bool {
+	if utils.IsBinaryFile(path) {
+		return true
+	}
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// Functions returns the declarations of the sandbox functions
+func (s *Sandbox) Functions() []providers.FunctionDef {
+	return []providers.FunctionDef{
+		{
+			Name:        "read_file",
+			Description: "Read a text file under the working directory. Returns numbered lines.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path":   map[string]any{"type": "string", "description": "File path relative to the working directory"},
+					"offset": map[string]any{"type": "integer", "description": "First line to return (1-based, default 1)"},
+					"limit":  map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum lines to return (default %d)", defaultReadLines)},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "list_dir",
+			Description: "List the entries of a directory under the working directory. Subdirectories end with a slash.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string", "description": "Directory path relative to the working directory (default \".\")"},
+				},
+			},
+		},
+		{
+			Name:        "grep",
+			Description: "Search text files under the working directory for a regular expression. Returns path:line: text for each match.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"pattern": map[string]any{"type": "string", "description": "RE2 regular expression"},
+					"path":    map[string]any{"type": "string", "description": "Directory or file to search (default \".\")"},
+					"glob":    map[string]any{"type": "string", "description": "Only search files whose name matches, e.g. \"*.go\""},
+				},
+				"required": []string{"pattern"},
+			},
+		},
+	}
+}
+
+// Call runs a sandbox function with JSON arguments. The result is capped so
+// one call can't flood the context, and configured keys are masked in case
+// one turns up in a file that isn't hidden.
+func (s *Sandbox) Call(name, arguments string) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Offset  int    `json:"offset"`
+		Limit   int    `json:"limit"`
+		Pattern string `json:"pattern"`
+		Glob    string `json:"glob"`
+	}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments for %s: %w", name, err)
+		}
+	}
+
+	var out string
+	var err error
+	switch name {
+	case "read_file":
+		if args.Path == "" {
+			return "", fmt.Errorf("read_file requires a path")
+		}
+		out, err = s.ReadFile(args.Path, args.Offset, args.Limit)
+	case "list_dir":
+		out, err = s.ListDir(args.Path)
+	case "grep":
+		if args.Pattern == "" {
+			return "", fmt.Errorf("grep requires a pattern")
+		}
+		out, err = s.Grep(args.Pattern, args.Path, args.Glob)
+	default:
+		return "", fmt.Errorf("unknown function %q", name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return utils.Truncate(redact.Secrets(out), maxResultBytes), nil
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSandbox creates a project with a few files and a secret outside it
+func newTestSandbox(t *testing.T) (*Sandbox, string) {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "project")
+
+	files := map[string]string{
+		"main.go":           "package main\n\nfunc main() {\n\t// TODO: flags\n}\n",
+		"internal/util.go":  "package internal\n\n// TODO: tests\n",
+		"internal/data.bin": "\x00\x01\x02",
+		".git/config":       "TODO in git metadata\n",
+		".env":              "TODO=hunter2\n",
+		"deploy/id_rsa":     "TODO private key\n",
+		"node_modules/x.js": "// TODO vendored\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	secret := filepath.Join(base, "secret.txt")
+	if err := os.WriteFile(secret, []byte("TODO: hunter2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSandbox(root)
+	if err != nil {
+		t.Fatalf("NewSandbox failed: %v", err)
+	}
+	return s, secret
+}
+
+func TestSandbox_RefusesEscapes(t *testing.T) {
+	s, secret := newTestSandbox(t)
+
+	if err := os.Symlink(secret, filepath.Join(s.Root(), "link.txt")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Symlink(filepath.Dir(secret), filepath.Join(s.Root(), "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"../secret.txt", secret, "link.txt", "up/secret.txt", "internal/../../secret.txt"} {
+		if out, err := s.ReadFile(path, 0, 0); err == nil || !strings.Contains(err.Error(), "outside the working directory") {
+			t.Errorf("ReadFile(%q) = %q, %v; want refusal", path, out, err)
+		}
+	}
+	if _, err := s.ListDir(".."); err == nil {
+		t.Error("ListDir(..) should be refused")
+	}
+
+	// grep doesn't follow symlinks out of the root
+	out, err := s.Grep("hunter2", ".", "")
+	if err != nil || out != "No matches.\n" {
+		t.Errorf("Grep followed a symlink out of the root: %q, %v", out, err)
+	}
+}
+
+func TestSandbox_RefusesHiddenFiles(t *testing.T) {
+	s, _ := newTestSandbox(t)
+
+	if err := os.Symlink(filepath.Join(s.Root(), ".env"), filepath.Join(s.Root(), "env.txt")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	for _, path := range []string{".env", ".git/config", "./.git/../.env", "deploy/id_rsa", "node_modules/x.js", "env.txt", filepath.Join(s.Root(), ".env")} {
+		if out, err := s.Call("read_file", fmt.Sprintf(`{"path":%q}`, path)); err == nil || !strings.Contains(err.Error(), "not available") {
+			t.Errorf("read_file(%q) = %q, %v; want refusal", path, out, err)
+		}
+	}
+	if _, err := s.ListDir(".git"); err == nil {
+		t.Error("ListDir(.git) should be refused")
+	}
+
+	out, err := s.ListDir("")
+	if err != nil {
+		t.Fatalf("ListDir failed: %v", err)
+	}
+	for _, name := range []string{".env", ".git", "node_modules"} {
+		if strings.Contains(out, name) {
+			t.Errorf("listing should hide %s, got %q", name, out)
+		}
+	}
+	if out, _ := s.ListDir("deploy"); out != "(empty directory)\n" {
+		t.Errorf("expected the key file hidden, got %q", out)
+	}
+
+	out, _ = s.Grep("TODO", "", "")
+	for _, name := range []string{".env", "id_rsa", "node_modules"} {
+		if strings.Contains(out, name) {
+			t.Errorf("grep should skip %s, got %q", name, out)
+		}
+	}
+}
+
+func TestSandbox_ReadFile(t *testing.T) {
+	s, _ := newTestSandbox(t)
+
+	out, err := s.ReadFile("main.go", 3, 2)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !strings.Contains(out, "     3\tfunc main() {") || !strings.Contains(out, "[lines 3-4 of 5]") || strings.Contains(out, "package main") {
+		t.Errorf("unexpected window %q", out)
+	}
+
+	if _, err := s.ReadFile("internal/data.bin", 0, 0); err == nil {
+		t.Error("binary files should be refused")
+	}
+	if _, err := s.ReadFile("internal", 0, 0); err == nil {
+		t.Error("directories should be refused")
+	}
+}
+
+func TestSandbox_ListAndGrep(t *testing.T) {
+	s, _ := newTestSandbox(t)
+
+	out, err := s.ListDir("")
+	if err != nil {
+		t.Fatalf("ListDir failed: %v", err)
+	}
+	if !strings.Contains(out, "internal/\n") || !strings.Contains(out, "main.go\n") {
+		t.Errorf("unexpected listing %q", out)
+	}
+
+	out, err = s.Grep("TODO", "", "")
+	if err != nil {
+		t.Fatalf("Grep failed: %v", err)
+	}
+	if !strings.Contains(out, "main.go:4: // TODO: flags") || !strings.Contains(out, "internal/util.go:3:") {
+		t.Errorf("missing matches in %q", out)
+	}
+	if strings.Contains(out, ".git") {
+		t.Errorf("grep should skip .git, got %q", out)
+	}
+
+	out, _ = s.Grep("TODO", "", "util.*")
+	if strings.Contains(out, "main.go") {
+		t.Errorf("glob should filter files, got %q", out)
+	}
+}
+
+func TestSandbox_Call(t *testing.T) {
+	s, _ := newTestSandbox(t)
+
+	if out, err := s.Call("read_file", `{"path":"internal/util.go"}`); err != nil || !strings.Contains(out, "package internal") {
+		t.Errorf("read_file = %q, %v", out, err)
+	}
+	if _, err := s.Call("read_file", `{}`); err == nil {
+		t.Error("read_file without a path should fail")
+	}
+	if _, err := s.Call("write_file", `{"path":"x"}`); err == nil {
+		t.Error("unknown functions should fail")
+	}
+	if _, err := s.Call("grep", `not json`); err == nil {
+		t.Error("invalid arguments should fail")
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import "fmt"
+
+// Budget actions
+const (
+	BudgetRefuse    = "refuse"    // fail calls once a limit is reached
+	BudgetDowngrade = "downgrade" // switch to a cheaper model instead
+)
+
+// BudgetConfig caps model spending in USD. Zero limits are unlimited.
+type BudgetConfig struct {
+	DailyUSD     float64            `json:"daily_usd,omitempty"`
+	PerThreadUSD float64            `json:"per_thread_usd,omitempty"`
+	PerToolUSD   map[string]float64 `json:"per_tool_usd,omitempty"` // per tool per day
+	Action       string             `json:"action,omitempty"`       // refuse (default) or downgrade
+
+	// DowngradeTo is the model used once a limit is reached in downgrade
+	// mode. Unset picks the cheapest priced model.
+	DowngradeTo string `json:"downgrade_to,omitempty"`
+}
+
+// IsEmpty reports whether no limits are set
+func (b BudgetConfig) IsEmpty() bool {
+	return b.DailyUSD <= 0 && b.PerThreadUSD <= 0 && len(b.PerToolUSD) == 0
+}
+
+func (b BudgetConfig) validate() error {
+	switch b.Action {
+	case "", BudgetRefuse, BudgetDowngrade:
+	default:
+		return fmt.Errorf("budgets: unknown action %q", b.Action)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Narcoleptic-Fox/relay-mcp/configs"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
@@ -40,7 +41,8 @@ func getConfigDir() string {
 	// 4. Fall back to relative path (original behavior)
 	return "configs"
 }
-	// Build-time variables (set via ldflags)
+
+// Build-time variables (set via ldflags)
 var (
 	Version   = "dev"
 	Commit    = "unknown"
@@ -55,24 +57,103 @@ type Config struct {
 	BuildTime string
 
 	// API Keys
-	GeminiAPIKey     string
-	OpenAIAPIKey     string
-	AzureAPIKey      string
-	AzureEndpoint    string
-	XAIAPIKey        string
-	DIALAPIKey       string
-	DIALEndpoint     string
-	OpenRouterAPIKey string
-	CustomAPIURL     string
+	GeminiAPIKey      string
+	VertexProject     string // Gemini through Vertex AI instead of the API key
+	VertexLocation    string
+	VertexCredentials string // service-account or authorized-user JSON; ADC when empty
+	OpenAIAPIKey      string
+	AzureAPIKey       string
+	AzureEndpoint     string
+	AzureAPIVersion   string
+	AzureAuthMode     string // api-key, client-credentials or managed-identity
+	AzureTenantID     string
+	AzureClientID     string
+	AzureClientSecret string
+	XAIAPIKey         string
+	DIALAPIKey        string
+	DIALEndpoint      string
+	OpenRouterAPIKey  string
+	CustomAPIURL      string
 
 	// Defaults
 	DefaultModel        string
 	DefaultThinkingMode types.ThinkingMode
 	LogLevel            string
 
-	// Model restrictions
-	GoogleAllowedModels []string
-	OpenAIAllowedModels []string
+	// Providers tried first when a model name is served by several, in
+	// order; unlisted providers follow in the default order
+	ProviderPriority []types.ProviderType
+
+	// Model restrictions, from <PREFIX>_ALLOWED_MODELS/_DENIED_MODELS and
+	// relay.json
+	ModelPolicies map[types.ProviderType]ModelPolicy
+
+	// How out-of-range request parameters are handled: clamp or strict
+	RequestValidation string
+
+	// Store Responses API calls on OpenAI's side, which lets a thread's
+	// follow-up calls chain from previous_response_id
+	OpenAIResponsesStore bool
+
+	// On-disk response cache
+	ResponseCache      bool
+	ResponseCacheDir   string
+	ResponseCacheTTL   time.Duration
+	ResponseCacheMaxMB int
+
+	// Per-tool automatic model selection profiles from relay.json, over
+	// DefaultTaskProfiles
+	TaskProfiles map[string]TaskProfile
+
+	// provider_options keys allowed per provider beyond
+	// DefaultProviderOptions, from relay.json
+	ProviderOptionAllowlist map[types.ProviderType][]string
+
+	// Spending limits from relay.json and the file recording daily spend
+	Budgets        BudgetConfig
+	CostLedgerFile string
+
+	// Per-provider and per-model RPM/TPM limits, and how long a call may
+	// wait for capacity before failing with a retryable error
+	RateLimits       map[types.ProviderType]ProviderRateLimits
+	RateLimitMaxWait time.Duration
+
+	// Circuit breaker: consecutive failures that open a provider's circuit,
+	// and how long it stays open before a probe call
+	CircuitFailureThreshold int
+	CircuitCooldown         time.Duration
+
+	// Agent loop: models that support function calling may read files under
+	// the working directory during a call, within these caps
+	AgentTools         bool
+	AgentMaxIterations int
+	AgentMaxTokens     int
+
+	// Embedding model used when a caller doesn't name one; empty picks the
+	// best embedding model of the highest-priority provider
+	EmbeddingModel string
+
+	// Mock provider: a fixtures file of scripted responses enables it, and
+	// received requests are appended to the record file as JSON lines
+	MockFixturesFile string
+	MockRecordFile   string
+
+	// HTTP record/replay: provider calls go through a cassette golden file,
+	// recording real traffic or replaying it offline
+	HTTPCassetteFile string
+	HTTPCassetteMode string
+
+	// Use Gemini's :countTokens endpoint instead of local estimates
+	GeminiCountTokensAPI bool
+
+	// Gemini safety thresholds as CATEGORY=THRESHOLD pairs (a bare threshold
+	// applies to every category), and whether to request thought summaries
+	GeminiSafetySettings  string
+	GeminiIncludeThoughts bool
+
+	// Model discovery via the providers' /models endpoints
+	ModelDiscovery         bool
+	ModelDiscoveryInterval time.Duration
 
 	// Conversation settings
 	MaxConversationTurns     int
@@ -91,6 +172,9 @@ type Config struct {
 
 	// CLI client configs
 	CLIClients map[string]CLIClientConfig
+
+	// Additional OpenAI-compatible providers from relay.json
+	Providers []ProviderConfig
 }
 
 // CLIClientConfig defines a CLI client
@@ -117,20 +201,63 @@ func Load() (*Config, error) {
 		BuildTime: BuildTime,
 
 		// Environment variables
-		GeminiAPIKey:     os.Getenv("GEMINI_API_KEY"),
-		OpenAIAPIKey:     os.Getenv("OPENAI_API_KEY"),
-		AzureAPIKey:      os.Getenv("AZURE_OPENAI_API_KEY"),
-		AzureEndpoint:    os.Getenv("AZURE_OPENAI_ENDPOINT"),
-		XAIAPIKey:        os.Getenv("XAI_API_KEY"),
-		DIALAPIKey:       os.Getenv("DIAL_API_KEY"),
-		DIALEndpoint:     os.Getenv("DIAL_ENDPOINT"),
-		OpenRouterAPIKey: os.Getenv("OPENROUTER_API_KEY"),
-		CustomAPIURL:     os.Getenv("CUSTOM_API_URL"),
+		GeminiAPIKey:      os.Getenv("GEMINI_API_KEY"),
+		VertexProject:     os.Getenv("VERTEX_PROJECT"),
+		VertexLocation:    getEnvOrDefault("VERTEX_LOCATION", "us-central1"),
+		VertexCredentials: os.Getenv("VERTEX_CREDENTIALS_FILE"),
+		OpenAIAPIKey:      os.Getenv("OPENAI_API_KEY"),
+		AzureAPIKey:       os.Getenv("AZURE_OPENAI_API_KEY"),
+		AzureEndpoint:     os.Getenv("AZURE_OPENAI_ENDPOINT"),
+		AzureAPIVersion:   os.Getenv("AZURE_OPENAI_API_VERSION"),
+		AzureAuthMode:     getEnvOrDefault("AZURE_OPENAI_AUTH", "api-key"),
+		AzureTenantID:     os.Getenv("AZURE_TENANT_ID"),
+		AzureClientID:     os.Getenv("AZURE_CLIENT_ID"),
+		AzureClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+		XAIAPIKey:         os.Getenv("XAI_API_KEY"),
+		DIALAPIKey:        os.Getenv("DIAL_API_KEY"),
+		DIALEndpoint:      os.Getenv("DIAL_ENDPOINT"),
+		OpenRouterAPIKey:  os.Getenv("OPENROUTER_API_KEY"),
+		CustomAPIURL:      os.Getenv("CUSTOM_API_URL"),
 
 		DefaultModel:        getEnvOrDefault("DEFAULT_MODEL", "auto"),
 		DefaultThinkingMode: types.ThinkingMode(getEnvOrDefault("DEFAULT_THINKING_MODE", "medium")),
 		LogLevel:            getEnvOrDefault("LOG_LEVEL", "info"),
 
+		RequestValidation:    getEnvOrDefault("REQUEST_VALIDATION", "clamp"),
+		OpenAIResponsesStore: getEnvBool("OPENAI_RESPONSES_STORE", false),
+
+		ResponseCache:      getEnvBool("RESPONSE_CACHE", false),
+		ResponseCacheDir:   os.Getenv("RESPONSE_CACHE_DIR"),
+		ResponseCacheTTL:   getEnvDuration("RESPONSE_CACHE_TTL", 24*time.Hour),
+		ResponseCacheMaxMB: getEnvInt("RESPONSE_CACHE_MAX_MB", 256),
+
+		CostLedgerFile: os.Getenv("COST_LEDGER_FILE"),
+
+		RateLimitMaxWait: getEnvDuration("RATE_LIMIT_MAX_WAIT", time.Minute),
+
+		CircuitFailureThreshold: getEnvInt("CIRCUIT_FAILURE_THRESHOLD", 5),
+		CircuitCooldown:         getEnvDuration("CIRCUIT_COOLDOWN", 30*time.Second),
+
+		AgentTools:         getEnvBool("AGENT_TOOLS", false),
+		AgentMaxIterations: getEnvInt("AGENT_MAX_ITERATIONS", 6),
+		AgentMaxTokens:     getEnvInt("AGENT_MAX_TOKENS", 200000),
+
+		EmbeddingModel: os.Getenv("EMBEDDING_MODEL"),
+
+		MockFixturesFile: os.Getenv("MOCK_FIXTURES_FILE"),
+		MockRecordFile:   os.Getenv("MOCK_RECORD_FILE"),
+
+		HTTPCassetteFile: os.Getenv("HTTP_CASSETTE_FILE"),
+		HTTPCassetteMode: getEnvOrDefault("HTTP_CASSETTE_MODE", "replay"),
+
+		GeminiCountTokensAPI: getEnvBool("GEMINI_COUNT_TOKENS_API", false),
+
+		GeminiSafetySettings:  os.Getenv("GEMINI_SAFETY_SETTINGS"),
+		GeminiIncludeThoughts: getEnvBool("GEMINI_INCLUDE_THOUGHTS", false),
+
+		ModelDiscovery:         getEnvBool("MODEL_DISCOVERY", false),
+		ModelDiscoveryInterval: getEnvDuration("MODEL_DISCOVERY_INTERVAL", time.Hour),
+
 		MaxConversationTurns:     getEnvInt("MAX_CONVERSATION_TURNS", 50),
 		ConversationTimeoutHours: getEnvInt("CONVERSATION_TIMEOUT_HOURS", 3),
 
@@ -138,16 +265,12 @@ func Load() (*Config, error) {
 		ClaudeCLIPath: getEnvOrDefault("CLAUDE_CLI_PATH", "claude"),
 		CodexCLIPath:  getEnvOrDefault("CODEX_CLI_PATH", "codex"),
 
-		ModelRegistries: make(map[types.ProviderType][]types.ModelCapabilities),
-		CLIClients:      make(map[string]CLIClientConfig),
-	}
-
-	// Parse allowed models
-	if v := os.Getenv("GOOGLE_ALLOWED_MODELS"); v != "" {
-		cfg.GoogleAllowedModels = strings.Split(v, ",")
-	}
-	if v := os.Getenv("OPENAI_ALLOWED_MODELS"); v != "" {
-		cfg.OpenAIAllowedModels = strings.Split(v, ",")
+		ModelRegistries:         make(map[types.ProviderType][]types.ModelCapabilities),
+		ModelPolicies:           make(map[types.ProviderType]ModelPolicy),
+		RateLimits:              make(map[types.ProviderType]ProviderRateLimits),
+		TaskProfiles:            make(map[string]TaskProfile),
+		ProviderOptionAllowlist: make(map[types.ProviderType][]string),
+		CLIClients:              make(map[string]CLIClientConfig),
 	}
 
 	// Parse disabled tools
@@ -160,6 +283,18 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("loading model registries: %w", err)
 	}
 
+	// Load relay.json (after registries so provider model files take effect)
+	if err := cfg.loadSettings(); err != nil {
+		return nil, fmt.Errorf("loading settings: %w", err)
+	}
+	cfg.loadPolicyEnv()
+	cfg.loadRateLimitEnv()
+
+	// Parse provider priority (after relay.json so its providers can be listed)
+	if err := cfg.loadProviderPriority(os.Getenv("PROVIDER_PRIORITY")); err != nil {
+		return nil, err
+	}
+
 	// Load CLI client configs
 	if err := cfg.loadCLIClients(); err != nil {
 		return nil, fmt.Errorf("loading CLI clients: %w", err)
@@ -275,14 +410,34 @@ func (c *Config) IsToolDisabled(name string) bool {
 	return false
 }
 
+// Secrets returns every configured credential, for redaction
+func (c *Config) Secrets() []string {
+	secrets := []string{
+		c.GeminiAPIKey,
+		c.OpenAIAPIKey,
+		c.AzureAPIKey,
+		c.AzureClientSecret,
+		c.XAIAPIKey,
+		c.DIALAPIKey,
+		c.OpenRouterAPIKey,
+	}
+	for _, pc := range c.Providers {
+		secrets = append(secrets, pc.APIKey())
+	}
+	return secrets
+}
+
 // HasProvider checks if a provider is configured
 func (c *Config) HasProvider(p types.ProviderType) bool {
 	switch p {
 	case types.ProviderGemini:
-		return c.GeminiAPIKey != ""
+		return c.GeminiAPIKey != "" || c.VertexProject != ""
 	case types.ProviderOpenAI:
 		return c.OpenAIAPIKey != ""
 	case types.ProviderAzure:
+		if c.AzureAuthMode != "" && c.AzureAuthMode != "api-key" {
+			return c.AzureEndpoint != ""
+		}
 		return c.AzureAPIKey != "" && c.AzureEndpoint != ""
 	case types.ProviderXAI:
 		return c.XAIAPIKey != ""
@@ -292,8 +447,11 @@ func (c *Config) HasProvider(p types.ProviderType) bool {
 		return c.OpenRouterAPIKey != ""
 	case types.ProviderCustom:
 		return c.CustomAPIURL != ""
+	case types.ProviderMock:
+		return c.MockFixturesFile != ""
 	default:
-		return false
+		_, ok := c.ProviderConfigFor(p)
+		return ok
 	}
 }
 
@@ -312,3 +470,21 @@ func getEnvInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultVal
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"slices"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// AnyProviderOption in an allowlist lets every provider option through
+const AnyProviderOption = "*"
+
+// DefaultProviderOptions are the provider_options keys each provider accepts
+// without configuration. allowed_provider_options in relay.json adds to them.
+// OpenRouter's models and route are left out, since fallback models would
+// bypass the model policy.
+var DefaultProviderOptions = map[types.ProviderType][]string{
+	types.ProviderOpenAI:     {"logit_bias", "logprobs", "top_logprobs", "user", "service_tier", "parallel_tool_calls", "metadata", "prediction", "verbosity", "store"},
+	types.ProviderAzure:      {"logit_bias", "logprobs", "top_logprobs", "user", "parallel_tool_calls", "data_sources"},
+	types.ProviderXAI:        {"logprobs", "top_logprobs", "user", "search_parameters"},
+	types.ProviderDIAL:       {"user", "addons", "custom_fields"},
+	types.ProviderOpenRouter: {"provider", "transforms", "top_k", "min_p", "top_a", "repetition_penalty", "logit_bias", "user"},
+	types.ProviderCustom:     {"top_k", "min_p", "repetition_penalty", "guided_json", "guided_regex", "guided_choice", "guided_grammar", "options", "keep_alive"},
+	types.ProviderGemini:     {"generationConfig", "cachedContent", "labels", "toolConfig"},
+	types.ProviderMock:       {AnyProviderOption},
+}
+
+// AllowedProviderOptions returns the provider_options keys a provider
+// accepts
+func (c *Config) AllowedProviderOptions(pt types.ProviderType) []string {
+	return slices.Concat(DefaultProviderOptions[pt], c.ProviderOptionAllowlist[pt])
+}
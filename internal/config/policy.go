@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// ModelPolicy restricts which models a provider may serve. Patterns are
+// case-insensitive globs ("*" and "?") matched against the model name and
+// its aliases. Deny wins over allow; an empty allow list allows everything.
+type ModelPolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// policyEnvPrefixes maps built-in providers to their <PREFIX>_ALLOWED_MODELS
+// and <PREFIX>_DENIED_MODELS variables
+var policyEnvPrefixes = map[types.ProviderType]string{
+	types.ProviderGemini:     "GOOGLE",
+	types.ProviderOpenAI:     "OPENAI",
+	types.ProviderAzure:      "AZURE",
+	types.ProviderXAI:        "XAI",
+	types.ProviderDIAL:       "DIAL",
+	types.ProviderOpenRouter: "OPENROUTER",
+	types.ProviderCustom:     "CUSTOM",
+}
+
+// IsEmpty reports whether the policy restricts nothing
+func (mp ModelPolicy) IsEmpty() bool {
+	return len(mp.Allow) == 0 && len(mp.Deny) == 0
+}
+
+// Evaluate checks a model, given by its name and aliases, against the policy.
+// When the model is blocked the returned reason explains why.
+func (mp ModelPolicy) Evaluate(names ...string) (bool, string) {
+	for _, pattern := range mp.Deny {
+		for _, name := range names {
+			if MatchGlob(pattern, name) {
+				return false, fmt.Sprintf("denied by %q", pattern)
+			}
+		}
+	}
+
+	if len(mp.Allow) == 0 {
+		return true, ""
+	}
+
+	for _, pattern := range mp.Allow {
+		for _, name := range names {
+			if MatchGlob(pattern, name) {
+				return true, ""
+			}
+		}
+	}
+
+	return false, fmt.Sprintf("not in allow list (%s)", strings.Join(mp.Allow, ", "))
+}
+
+// MatchGlob reports whether name matches a case-insensitive glob pattern.
+// Unlike path.Match, "*" also matches "/" so "openai/*" style names work.
+func MatchGlob(pattern, name string) bool {
+	var re strings.Builder
+	re.WriteString("(?i)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString("$")
+
+	matched, err := regexp.MatchString(re.String(), name)
+	return err == nil && matched
+}
+
+// PolicyFor returns the model policy for a provider
+func (c *Config) PolicyFor(pt types.ProviderType) ModelPolicy {
+	return c.ModelPolicies[pt]
+}
+
+// loadPolicyEnv merges <PREFIX>_ALLOWED_MODELS and <PREFIX>_DENIED_MODELS
+// into the policies from relay.json
+func (c *Config) loadPolicyEnv() {
+	for pt, prefix := range policyEnvPrefixes {
+		allow := splitList(os.Getenv(prefix + "_ALLOWED_MODELS"))
+		deny := splitList(os.Getenv(prefix + "_DENIED_MODELS"))
+		if len(allow) == 0 && len(deny) == 0 {
+			continue
+		}
+
+		policy := c.ModelPolicies[pt]
+		policy.Allow = append(policy.Allow, allow...)
+		policy.Deny = append(policy.Deny, deny...)
+		c.ModelPolicies[pt] = policy
+	}
+}
+
+// splitList splits a comma-separated list, dropping blanks
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"gpt-5*", "gpt-5.2-pro", true},
+		{"gpt-5*", "gpt-4o", false},
+		{"openai/*", "openai/gpt-4o", true},
+		{"GEMINI-2.5-?RO", "gemini-2.5-pro", true},
+		{"o3", "o3-mini", false},
+		{"*mini*", "o4-mini", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestModelPolicy_Evaluate(t *testing.T) {
+	policy := ModelPolicy{
+		Allow: []string{"gpt-5*", "o*"},
+		Deny:  []string{"*-pro"},
+	}
+
+	if ok, _ := policy.Evaluate("gpt-5.2"); !ok {
+		t.Error("expected gpt-5.2 to be allowed")
+	}
+	if ok, reason := policy.Evaluate("gpt-5.2-pro"); ok || reason == "" {
+		t.Errorf("expected gpt-5.2-pro to be denied with a reason, got %v %q", ok, reason)
+	}
+	if ok, _ := policy.Evaluate("gpt-4o"); ok {
+		t.Error("expected gpt-4o to be outside the allow list")
+	}
+	// Aliases count for both lists
+	if ok, _ := policy.Evaluate("gemini-2.5-flash", "o-flash"); !ok {
+		t.Error("expected alias to satisfy the allow list")
+	}
+
+	if ok, _ := (ModelPolicy{}).Evaluate("anything"); !ok {
+		t.Error("expected empty policy to allow everything")
+	}
+}
+
+func TestLoadPolicyEnv(t *testing.T) {
+	t.Setenv("GOOGLE_ALLOWED_MODELS", "gemini-2.5-pro, flash")
+	t.Setenv("XAI_DENIED_MODELS", "grok-3*")
+
+	cfg := &Config{ModelPolicies: map[types.ProviderType]ModelPolicy{
+		types.ProviderGemini: {Deny: []string{"*-exp"}},
+	}}
+	cfg.loadPolicyEnv()
+
+	gemini := cfg.PolicyFor(types.ProviderGemini)
+	if len(gemini.Allow) != 2 || gemini.Allow[1] != "flash" || len(gemini.Deny) != 1 {
+		t.Errorf("unexpected gemini policy: %+v", gemini)
+	}
+	if xai := cfg.PolicyFor(types.ProviderXAI); len(xai.Deny) != 1 || xai.Deny[0] != "grok-3*" {
+		t.Errorf("unexpected xai policy: %+v", xai)
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// RateLimit caps requests and tokens per minute. Zero is unlimited.
+type RateLimit struct {
+	RPM int `json:"rpm,omitempty"`
+	TPM int `json:"tpm,omitempty"`
+}
+
+// IsEmpty reports whether the limit restricts nothing
+func (rl RateLimit) IsEmpty() bool {
+	return rl.RPM <= 0 && rl.TPM <= 0
+}
+
+// ProviderRateLimits is a provider-wide limit plus per-model limits. A call
+// must fit within both.
+type ProviderRateLimits struct {
+	RateLimit
+	Models map[string]RateLimit `json:"models,omitempty"`
+}
+
+// RateLimitsFor returns the rate limits for a provider
+func (c *Config) RateLimitsFor(pt types.ProviderType) ProviderRateLimits {
+	return c.RateLimits[pt]
+}
+
+// loadRateLimitEnv applies <PREFIX>_RPM and <PREFIX>_TPM as provider-wide
+// limits, overriding relay.json
+func (c *Config) loadRateLimitEnv() {
+	for pt, prefix := range policyEnvPrefixes {
+		limits := c.RateLimits[pt]
+		changed := false
+		if v, err := strconv.Atoi(os.Getenv(prefix + "_RPM")); err == nil {
+			limits.RPM = v
+			changed = true
+		}
+		if v, err := strconv.Atoi(os.Getenv(prefix + "_TPM")); err == nil {
+			limits.TPM = v
+			changed = true
+		}
+		if changed {
+			c.RateLimits[pt] = limits
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import "fmt"
+
+// TaskProfile tells automatic model selection what a tool needs. CostWeight
+// trades intelligence for price: 0 picks the most capable qualifying model,
+// 1 the cheapest.
+type TaskProfile struct {
+	MinIntelligence     int     `json:"min_intelligence,omitempty"`
+	NeedsThinking       bool    `json:"needs_thinking,omitempty"`
+	NeedsCodeGeneration bool    `json:"needs_code_generation,omitempty"`
+	CostWeight          float64 `json:"cost_weight,omitempty"`
+}
+
+// expertProfile is shared by the workflow tools' expert analysis
+var expertProfile = TaskProfile{MinIntelligence: 80, NeedsThinking: true, NeedsCodeGeneration: true, CostWeight: 0.1}
+
+// DefaultTaskProfiles are used for tools without a task_profiles entry in
+// relay.json. Tools not listed route on intelligence alone.
+var DefaultTaskProfiles = map[string]TaskProfile{
+	"apilookup":  {CostWeight: 0.7},
+	"chat":       {CostWeight: 0.3},
+	"challenge":  {CostWeight: 0.3},
+	"consensus":  {MinIntelligence: 80, CostWeight: 0.2},
+	"planner":    {MinIntelligence: 80, NeedsThinking: true, CostWeight: 0.2},
+	"analyze":    expertProfile,
+	"codereview": expertProfile,
+	"debug":      expertProfile,
+	"precommit":  expertProfile,
+	"refactor":   expertProfile,
+	"testgen":    expertProfile,
+	"thinkdeep":  expertProfile,
+}
+
+// TaskProfileFor returns the routing profile for a tool
+func (c *Config) TaskProfileFor(tool string) TaskProfile {
+	if p, ok := c.TaskProfiles[tool]; ok {
+		return p
+	}
+	return DefaultTaskProfiles[tool]
+}
+
+func (p TaskProfile) validate() error {
+	if p.CostWeight < 0 || p.CostWeight > 1 {
+		return fmt.Errorf("cost_weight %v is outside 0-1", p.CostWeight)
+	}
+	if p.MinIntelligence < 0 || p.MinIntelligence > 100 {
+		return fmt.Errorf("min_intelligence %d is outside 0-100", p.MinIntelligence)
+	}
+	return nil
+}
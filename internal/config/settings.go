@@ -0,0 +1,221 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// SettingsFile is the structured settings file in the config directory.
+// Scalar options stay in environment variables; anything list- or
+// map-shaped lives here.
+const SettingsFile = "relay.json"
+
+// Settings is the on-disk layout of relay.json
+type Settings struct {
+	Providers     []ProviderConfig                          `json:"providers,omitempty"`
+	ModelPolicies map[types.ProviderType]ModelPolicy        `json:"model_policies,omitempty"`
+	Budgets       *BudgetConfig                             `json:"budgets,omitempty"`
+	RateLimits    map[types.ProviderType]ProviderRateLimits `json:"rate_limits,omitempty"`
+	TaskProfiles  map[string]TaskProfile                    `json:"task_profiles,omitempty"`
+
+	AllowedProviderOptions map[types.ProviderType][]string `json:"allowed_provider_options,omitempty"`
+}
+
+// Auth styles for config-defined providers
+const (
+	AuthStyleBearer = "bearer" // Authorization: Bearer <key>
+	AuthStyleHeader = "header" // <auth_header>: <key>
+	AuthStyleNone   = "none"
+)
+
+// ProviderConfig defines an additional OpenAI-compatible endpoint
+type ProviderConfig struct {
+	Name       string `json:"name"`
+	BaseURL    string `json:"base_url"`
+	AuthStyle  string `json:"auth_style,omitempty"`  // bearer (default), header or none
+	AuthHeader string `json:"auth_header,omitempty"` // header name for the header style, default api-key
+	APIKeyEnv  string `json:"api_key_env,omitempty"` // environment variable holding the key
+	Timeout    string `json:"timeout,omitempty"`
+	ModelsFile string `json:"models_file,omitempty"` // relative to configs/models
+
+	// Priority is the provider's slot in the selection order (0 = first).
+	// Unset places it just before OpenRouter.
+	Priority *int `json:"priority,omitempty"`
+}
+
+// ProviderType returns the provider type used for this endpoint
+func (pc ProviderConfig) ProviderType() types.ProviderType {
+	return types.ProviderType(pc.Name)
+}
+
+// APIKey reads the key from the configured environment variable
+func (pc ProviderConfig) APIKey() string {
+	if pc.APIKeyEnv == "" {
+		return ""
+	}
+	return os.Getenv(pc.APIKeyEnv)
+}
+
+// TimeoutDuration parses Timeout, falling back to def
+func (pc ProviderConfig) TimeoutDuration(def time.Duration) time.Duration {
+	if pc.Timeout != "" {
+		if d, err := time.ParseDuration(pc.Timeout); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
+// settingsPath returns RELAY_SETTINGS or relay.json in the config directory
+func settingsPath() string {
+	if path := os.Getenv("RELAY_SETTINGS"); path != "" {
+		return path
+	}
+	return filepath.Join(getConfigDir(), SettingsFile)
+}
+
+func (c *Config) loadSettings() error {
+	path := settingsPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil // Settings file is optional
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	slog.Debug("loading settings", "file", path)
+
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for pt, policy := range settings.ModelPolicies {
+		c.ModelPolicies[pt] = policy
+	}
+
+	for pt, limits := range settings.RateLimits {
+		c.RateLimits[pt] = limits
+	}
+
+	for pt, keys := range settings.AllowedProviderOptions {
+		c.ProviderOptionAllowlist[pt] = keys
+	}
+
+	for tool, profile := range settings.TaskProfiles {
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("%s: task_profiles.%s: %w", path, tool, err)
+		}
+		c.TaskProfiles[tool] = profile
+	}
+
+	if settings.Budgets != nil {
+		if err := settings.Budgets.validate(); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		c.Budgets = *settings.Budgets
+	}
+
+	return c.applyProviders(settings.Providers)
+}
+
+// applyProviders validates config-defined providers and loads their model files
+func (c *Config) applyProviders(providers []ProviderConfig) error {
+	seen := make(map[string]bool)
+
+	for _, pc := range providers {
+		if pc.Name == "" || pc.BaseURL == "" {
+			return fmt.Errorf("provider entries need a name and base_url")
+		}
+		if seen[pc.Name] || isBuiltinProvider(pc.ProviderType()) {
+			return fmt.Errorf("duplicate provider name %q", pc.Name)
+		}
+		seen[pc.Name] = true
+
+		switch pc.AuthStyle {
+		case "", AuthStyleBearer, AuthStyleHeader, AuthStyleNone:
+		default:
+			return fmt.Errorf("provider %s: unknown auth_style %q", pc.Name, pc.AuthStyle)
+		}
+
+		if pc.ModelsFile != "" {
+			models, err := loadModelFile(pc.ModelsFile, pc.ProviderType())
+			if err != nil {
+				return fmt.Errorf("provider %s: %w", pc.Name, err)
+			}
+			c.ModelRegistries[pc.ProviderType()] = models
+		}
+
+		c.Providers = append(c.Providers, pc)
+	}
+
+	return nil
+}
+
+// loadModelFile reads a model registry file, resolving relative paths against
+// the models config directory. Every entry is assigned to pt.
+func loadModelFile(path string, pt types.ProviderType) ([]types.ModelCapabilities, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(getConfigDir(), "models", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var models []types.ModelCapabilities
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for i := range models {
+		models[i].Provider = pt
+	}
+
+	return models, nil
+}
+
+func isBuiltinProvider(pt types.ProviderType) bool {
+	switch pt {
+	case types.ProviderGemini, types.ProviderOpenAI, types.ProviderAzure, types.ProviderXAI,
+		types.ProviderDIAL, types.ProviderOpenRouter, types.ProviderCustom, types.ProviderMock:
+		return true
+	}
+	return false
+}
+
+// loadProviderPriority parses a comma-separated provider list, rejecting
+// unknown and repeated names
+func (c *Config) loadProviderPriority(spec string) error {
+	c.ProviderPriority = nil
+	for _, name := range splitList(spec) {
+		pt := types.ProviderType(name)
+		if _, ok := c.ProviderConfigFor(pt); !ok && !isBuiltinProvider(pt) {
+			return fmt.Errorf("PROVIDER_PRIORITY: unknown provider %q", name)
+		}
+		if slices.Contains(c.ProviderPriority, pt) {
+			return fmt.Errorf("PROVIDER_PRIORITY: %s is listed twice", pt)
+		}
+		c.ProviderPriority = append(c.ProviderPriority, pt)
+	}
+	return nil
+}
+
+// ProviderConfigFor returns the config-defined provider with the given type
+func (c *Config) ProviderConfigFor(pt types.ProviderType) (ProviderConfig, bool) {
+	for _, pc := range c.Providers {
+		if pc.ProviderType() == pt {
+			return pc, true
+		}
+	}
+	return ProviderConfig{}, false
+}
@@ -5,23 +5,39 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
 )
-	// ThreadBuilder helps build thread context for AI prompts
+
+// TokenCounter counts the tokens in a piece of text
+type TokenCounter func(text string) int
+
+// ThreadBuilder helps build thread context for AI prompts
 type ThreadBuilder struct {
-	thread     *types.ThreadContext
-	maxTokens  int
-	tokenCount int
+	thread      *types.ThreadContext
+	maxTokens   int
+	tokenCount  int
+	countTokens TokenCounter
 }
 
 // NewThreadBuilder creates a new thread builder
 func NewThreadBuilder(thread *types.ThreadContext, maxTokens int) *ThreadBuilder {
 	return &ThreadBuilder{
-		thread:    thread,
-		maxTokens: maxTokens,
+		thread:      thread,
+		maxTokens:   maxTokens,
+		countTokens: tokenizer.Estimate,
 	}
 }
 
+// WithTokenCounter sets the counter used for budgeting, normally the
+// target provider's CountTokens for the selected model
+func (b *ThreadBuilder) WithTokenCounter(counter TokenCounter) *ThreadBuilder {
+	if counter != nil {
+		b.countTokens = counter
+	}
+	return b
+}
+
 // BuildConversationHistory builds the conversation history for the AI
 func (b *ThreadBuilder) BuildConversationHistory() []types.ConversationTurn {
 	if len(b.thread.Turns) == 0 {
@@ -33,7 +49,7 @@ func (b *ThreadBuilder) BuildConversationHistory() []types.ConversationTurn {
 
 	for i := len(b.thread.Turns) - 1; i >= 0; i-- {
 		turn := b.thread.Turns[i]
-		turnTokens := b.estimateTokens(turn.Content)
+		turnTokens := b.countTokens(turn.Content)
 
 		if b.tokenCount+turnTokens > b.maxTokens {
 			break
@@ -97,8 +113,3 @@ func (b *ThreadBuilder) GetRecentFiles(maxFiles int) []string {
 
 	return files
 }
-
-func (b *ThreadBuilder) estimateTokens(content string) int {
-	// Rough estimate: 4 characters per token
-	return len(content) / 4
-}
@@ -90,3 +90,27 @@ func TestThreadBuilder_BuildConversationHistory(t *testing.T) {
 		t.Errorf("expected first turn to be 'Hello', got '%s'", history[0].Content)
 	}
 }
+
+func TestThreadBuilder_WithTokenCounter(t *testing.T) {
+	thread := &types.ThreadContext{
+		ThreadID:  "test-counter",
+		CreatedAt: time.Now(),
+		ToolName:  "chat",
+		Turns: []types.ConversationTurn{
+			{Role: "user", Content: "first"},
+			{Role: "assistant", Content: "second"},
+			{Role: "user", Content: "third"},
+		},
+	}
+
+	// Every turn costs 10 tokens, so a budget of 25 keeps the newest two
+	builder := NewThreadBuilder(thread, 25).WithTokenCounter(func(string) int { return 10 })
+	history := builder.BuildConversationHistory()
+
+	if len(history) != 2 {
+		t.Fatalf("expected 2 turns within budget, got %d", len(history))
+	}
+	if history[0].Content != "second" || history[1].Content != "third" {
+		t.Errorf("expected newest turns in order, got %+v", history)
+	}
+}
@@ -7,16 +7,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
 )
 
 const (
-	// Azure API version - using a stable, recent version
-	azureAPIVersion = "2024-02-15-preview"
+	// Default Azure API version (latest GA), overridden by
+	// AZURE_OPENAI_API_VERSION or a model's api_version
+	azureAPIVersion = "2024-10-21"
 )
 
 // AzureProvider implements Provider for Azure OpenAI
@@ -24,12 +28,21 @@ type AzureProvider struct {
 	*BaseProvider
 	apiKey     string
 	endpoint   string
+	apiVersion string
+	tokens     *entraTokenSource // nil when using api-key auth
 	httpClient *http.Client
 }
 
 // NewAzureProvider creates a new Azure provider
 func NewAzureProvider(cfg *config.Config) (*AzureProvider, error) {
-	if cfg.AzureAPIKey == "" {
+	var tokens *entraTokenSource
+	if cfg.AzureAuthMode != "" && cfg.AzureAuthMode != AzureAuthAPIKey {
+		ts, err := newEntraTokenSource(cfg.AzureAuthMode, cfg.AzureTenantID, cfg.AzureClientID, cfg.AzureClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		tokens = ts
+	} else if cfg.AzureAPIKey == "" {
 		return nil, fmt.Errorf("AZURE_OPENAI_API_KEY not configured")
 	}
 	if cfg.AzureEndpoint == "" {
@@ -40,33 +53,89 @@ func NewAzureProvider(cfg *config.Config) (*AzureProvider, error) {
 	if len(models) == 0 {
 		models = defaultAzureModels()
 	}
+	models = withDeploymentAliases(models)
 
 	// Normalize endpoint - remove trailing slash
 	endpoint := strings.TrimSuffix(cfg.AzureEndpoint, "/")
 
+	apiVersion := cfg.AzureAPIVersion
+	if apiVersion == "" {
+		apiVersion = azureAPIVersion
+	}
+
 	return &AzureProvider{
 		BaseProvider: NewBaseProvider(types.ProviderAzure, models),
 		apiKey:       cfg.AzureAPIKey,
 		endpoint:     endpoint,
+		apiVersion:   apiVersion,
+		tokens:       tokens,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Minute,
 		},
 	}, nil
 }
 
+// withDeploymentAliases makes each model addressable by its deployment name
+func withDeploymentAliases(models []types.ModelCapabilities) []types.ModelCapabilities {
+	out := make([]types.ModelCapabilities, len(models))
+	for i, m := range models {
+		if m.Deployment != "" && m.Deployment != m.ModelName && !slices.Contains(m.Aliases, m.Deployment) {
+			m.Aliases = append(slices.Clone(m.Aliases), m.Deployment)
+		}
+		out[i] = m
+	}
+	return out
+}
+
 func (p *AzureProvider) IsConfigured() bool {
-	return p.apiKey != "" && p.endpoint != ""
+	return (p.apiKey != "" || p.tokens != nil) && p.endpoint != ""
+}
+
+// deploymentFor returns the deployment name and API version for a model
+func (p *AzureProvider) deploymentFor(modelName string, caps *types.ModelCapabilities) (string, string) {
+	deployment, apiVersion := modelName, p.apiVersion
+	if caps != nil {
+		if caps.Deployment != "" {
+			deployment = caps.Deployment
+		}
+		if caps.APIVersion != "" {
+			apiVersion = caps.APIVersion
+		}
+	}
+	return deployment, apiVersion
+}
+
+// authorize sets the api-key header, or an Entra ID bearer token when
+// client-credentials or managed-identity auth is configured
+func (p *AzureProvider) authorize(ctx context.Context, httpReq *http.Request) error {
+	if p.tokens == nil {
+		httpReq.Header.Set("api-key", p.apiKey) // Azure uses api-key header, NOT Authorization: Bearer
+		return nil
+	}
+
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	return nil
 }
 
-func (p *AzureProvider) CountTokens(text string, modelName string) (int, error) {
-	// Rough estimate: 4 chars per token
-	return len(text) / 4, nil
+func (p *AzureProvider) CountTokens(ctx context.Context, text string, modelName string) (int, error) {
+	return tokenizer.CountTokens(text, p.ResolveModelName(modelName)), nil
 }
 
 // GenerateContent calls the Azure OpenAI API with proper authentication
 func (p *AzureProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*types.ModelResponse, error) {
 	modelName := p.ResolveModelName(req.Model)
 
+	if err := p.CheckAllowed(modelName); err != nil {
+		return nil, err
+	}
+	if err := p.CheckVision(modelName, req); err != nil {
+		return nil, err
+	}
+
 	// Build messages
 	messages := p.buildMessages(req)
 
@@ -75,30 +144,31 @@ func (p *AzureProvider) GenerateContent(ctx context.Context, req *GenerateReques
 		"messages": messages,
 	}
 
-	if req.Temperature > 0 {
-		body["temperature"] = req.Temperature
-	}
-	if req.MaxOutputTokens > 0 {
-		body["max_tokens"] = req.MaxOutputTokens
-	}
+	caps, _ := p.GetCapabilities(modelName)
+	applySamplingParams(body, modelName, caps, req)
+	applyResponseFormat(body, req)
+	applyTools(body, req)
+	applyProviderOptions(body, req.ProviderOptions)
 
 	// Azure-specific URL format: /openai/deployments/{deployment-name}/chat/completions?api-version={version}
-	// The deployment name in Azure typically matches the model name
-	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
-		p.endpoint, modelName, azureAPIVersion)
+	deployment, apiVersion := p.deploymentFor(modelName, caps)
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		p.endpoint, url.PathEscape(deployment), url.QueryEscape(apiVersion))
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("api-key", p.apiKey) // Azure uses api-key header, NOT Authorization: Bearer
+	if err := p.authorize(ctx, httpReq); err != nil {
+		return nil, err
+	}
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
@@ -112,7 +182,7 @@ func (p *AzureProvider) GenerateContent(ctx context.Context, req *GenerateReques
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Azure API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, ErrAPIError{Provider: types.ProviderAzure, StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	// Parse response - Azure uses same format as OpenAI
@@ -146,10 +216,10 @@ func (p *AzureProvider) buildMessages(req *GenerateRequest) []map[string]any {
 	// Add current prompt
 	messages = append(messages, map[string]any{
 		"role":    "user",
-		"content": req.Prompt,
+		"content": buildUserContent(req.Prompt, req.Images),
 	})
 
-	return messages
+	return appendToolMessages(messages, req.ToolTurns)
 }
 
 func (p *AzureProvider) parseResponse(model string, resp *openAIResponse) (*types.ModelResponse, error) {
@@ -164,10 +234,14 @@ func (p *AzureProvider) parseResponse(model string, resp *openAIResponse) (*type
 		Model:        model,
 		Provider:     types.ProviderAzure,
 		FinishReason: choice.FinishReason,
+		ToolCalls:    parseToolCalls(choice.Message.ToolCalls),
+		Alternatives: resp.alternatives(),
 		TokensUsed: types.TokenUsage{
 			PromptTokens:     resp.Usage.PromptTokens,
 			CompletionTokens: resp.Usage.CompletionTokens,
 			TotalTokens:      resp.Usage.TotalTokens,
+			ThinkingTokens:   resp.Usage.CompletionTokensDetails.ReasoningTokens,
+			CachedTokens:     resp.Usage.PromptTokensDetails.CachedTokens,
 		},
 	}, nil
 }
@@ -175,22 +249,28 @@ func (p *AzureProvider) parseResponse(model string, resp *openAIResponse) (*type
 func defaultAzureModels() []types.ModelCapabilities {
 	return []types.ModelCapabilities{
 		{
-			Provider:          types.ProviderAzure,
-			ModelName:         "gpt-4o",
-			FriendlyName:      "Azure GPT-4o",
-			IntelligenceScore: 90,
-			ContextWindow:     128000,
-			MaxOutputTokens:   4096,
-			SupportsStreaming: true,
+			Provider:                types.ProviderAzure,
+			ModelName:               "gpt-4o",
+			FriendlyName:            "Azure GPT-4o",
+			IntelligenceScore:       90,
+			ContextWindow:           128000,
+			MaxOutputTokens:         4096,
+			SupportsStreaming:       true,
+			SupportsSystemPrompts:   true,
+			SupportsJSONSchema:      true,
+			SupportsFunctionCalling: true,
 		},
 		{
-			Provider:          types.ProviderAzure,
-			ModelName:         "gpt-4o-mini",
-			FriendlyName:      "Azure GPT-4o Mini",
-			IntelligenceScore: 75,
-			ContextWindow:     128000,
-			MaxOutputTokens:   4096,
-			SupportsStreaming: true,
+			Provider:                types.ProviderAzure,
+			ModelName:               "gpt-4o-mini",
+			FriendlyName:            "Azure GPT-4o Mini",
+			IntelligenceScore:       75,
+			ContextWindow:           128000,
+			MaxOutputTokens:         4096,
+			SupportsStreaming:       true,
+			SupportsSystemPrompts:   true,
+			SupportsJSONSchema:      true,
+			SupportsFunctionCalling: true,
 		},
 	}
 }
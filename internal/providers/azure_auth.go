@@ -0,0 +1,224 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Azure authentication modes (AZURE_OPENAI_AUTH)
+const (
+	AzureAuthAPIKey            = "api-key"
+	AzureAuthClientCredentials = "client-credentials"
+	AzureAuthManagedIdentity   = "managed-identity"
+)
+
+const (
+	cognitiveServicesScope    = "https://cognitiveservices.azure.com/.default"
+	cognitiveServicesResource = "https://cognitiveservices.azure.com"
+
+	entraAuthorityURL = "https://login.microsoftonline.com"
+	imdsTokenURL      = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// tokenRefreshSkew refreshes tokens this long before they expire so
+	// in-flight requests never carry an expired token
+	tokenRefreshSkew = 5 * time.Minute
+)
+
+// entraTokenSource fetches and caches Microsoft Entra ID bearer tokens for
+// Azure OpenAI using client credentials or a managed identity
+type entraTokenSource struct {
+	mode         string
+	tenantID     string
+	clientID     string
+	clientSecret string
+
+	// Endpoints, overridable for tests
+	authorityURL string
+	imdsURL      string
+
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newEntraTokenSource(mode, tenantID, clientID, clientSecret string) (*entraTokenSource, error) {
+	switch mode {
+	case AzureAuthClientCredentials:
+		if tenantID == "" || clientID == "" || clientSecret == "" {
+			return nil, fmt.Errorf("AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET are required for client-credentials auth")
+		}
+	case AzureAuthManagedIdentity:
+		// AZURE_CLIENT_ID optionally selects a user-assigned identity
+	default:
+		return nil, fmt.Errorf("unknown AZURE_OPENAI_AUTH mode %q", mode)
+	}
+
+	return &entraTokenSource{
+		mode:         mode,
+		tenantID:     tenantID,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		authorityURL: entraAuthorityURL,
+		imdsURL:      imdsTokenURL,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Token returns a cached token, fetching a new one when it is close to expiry
+func (s *entraTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiry) > tokenRefreshSkew {
+		return s.token, nil
+	}
+
+	var tok *entraToken
+	var err error
+	if s.mode == AzureAuthClientCredentials {
+		tok, err = s.fetchClientCredentials(ctx)
+	} else {
+		tok, err = s.fetchManagedIdentity(ctx)
+	}
+	if err != nil {
+		return "", fmt.Errorf("acquiring Entra ID token: %w", err)
+	}
+
+	s.token = tok.AccessToken
+	s.expiry = tok.expiry()
+	return s.token, nil
+}
+
+func (s *entraTokenSource) fetchClientCredentials(ctx context.Context) (*entraToken, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+		"scope":         {cognitiveServicesScope},
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", s.authorityURL, url.PathEscape(s.tenantID))
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return s.do(httpReq)
+}
+
+// fetchManagedIdentity uses the App Service / Container Apps identity endpoint
+// when IDENTITY_ENDPOINT is set, and the VM instance metadata service otherwise
+func (s *entraTokenSource) fetchManagedIdentity(ctx context.Context) (*entraToken, error) {
+	query := url.Values{"resource": {cognitiveServicesResource}}
+	if s.clientID != "" {
+		query.Set("client_id", s.clientID)
+	}
+
+	endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER")
+	var httpReq *http.Request
+	var err error
+
+	if endpoint != "" && header != "" {
+		query.Set("api-version", "2019-08-01")
+		httpReq, err = http.NewRequestWithContext(ctx, "GET", endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		httpReq.Header.Set("X-IDENTITY-HEADER", header)
+	} else {
+		query.Set("api-version", "2018-02-01")
+		httpReq, err = http.NewRequestWithContext(ctx, "GET", s.imdsURL+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		httpReq.Header.Set("Metadata", "true")
+	}
+
+	return s.do(httpReq)
+}
+
+func (s *entraTokenSource) do(httpReq *http.Request) (*entraToken, error) {
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint error %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var tok entraToken
+	if err := json.Unmarshal(respBody, &tok); err != nil {
+		return nil, fmt.Errorf("parsing token: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access_token")
+	}
+
+	return &tok, nil
+}
+
+// entraToken covers both the OAuth2 token response (numeric expires_in) and
+// the managed identity response (string expires_in and expires_on)
+type entraToken struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	ExpiresOn   json.Number `json:"expires_on"`
+}
+
+func (t *entraToken) expiry() time.Time {
+	if on, err := t.ExpiresOn.Int64(); err == nil && on > 0 {
+		return time.Unix(on, 0)
+	}
+	if in, err := t.ExpiresIn.Int64(); err == nil && in > 0 {
+		return time.Now().Add(time.Duration(in) * time.Second)
+	}
+	// No lifetime reported; Entra tokens last at least an hour
+	return time.Now().Add(time.Hour)
+}
+
+// UnmarshalJSON accepts expires_in/expires_on as either numbers or strings
+func (t *entraToken) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   any    `json:"expires_in"`
+		ExpiresOn   any    `json:"expires_on"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	t.AccessToken = raw.AccessToken
+	t.ExpiresIn = toJSONNumber(raw.ExpiresIn)
+	t.ExpiresOn = toJSONNumber(raw.ExpiresOn)
+	return nil
+}
+
+func toJSONNumber(v any) json.Number {
+	switch n := v.(type) {
+	case float64:
+		return json.Number(strconv.FormatInt(int64(n), 10))
+	case string:
+		return json.Number(n)
+	default:
+		return ""
+	}
+}
@@ -187,3 +187,171 @@ func TestAzureProvider_IsConfigured(t *testing.T) {
 		t.Error("expected provider to be configured")
 	}
 }
+
+func TestAzureProvider_DeploymentMapping(t *testing.T) {
+	var capturedPaths, capturedVersions []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedPaths = append(capturedPaths, r.URL.Path)
+		capturedVersions = append(capturedVersions, r.URL.Query().Get("api-version"))
+
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Content: "ok"}}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		AzureAPIKey:     "test-key",
+		AzureEndpoint:   server.URL,
+		AzureAPIVersion: "2024-06-01",
+		ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+			types.ProviderAzure: {
+				{Provider: types.ProviderAzure, ModelName: "gpt-4o", Deployment: "prod-gpt4o"},
+				{Provider: types.ProviderAzure, ModelName: "o4-mini", Deployment: "reasoning", APIVersion: "2025-04-01-preview"},
+			},
+		},
+	}
+
+	provider, err := NewAzureProvider(cfg)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	for _, model := range []string{"gpt-4o", "reasoning"} {
+		if _, err := provider.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: model}); err != nil {
+			t.Fatalf("GenerateContent(%s) failed: %v", model, err)
+		}
+	}
+
+	wantPaths := []string{
+		"/openai/deployments/prod-gpt4o/chat/completions",
+		"/openai/deployments/reasoning/chat/completions",
+	}
+	wantVersions := []string{"2024-06-01", "2025-04-01-preview"}
+	for i := range wantPaths {
+		if capturedPaths[i] != wantPaths[i] {
+			t.Errorf("request %d: expected path %s, got %s", i, wantPaths[i], capturedPaths[i])
+		}
+		if capturedVersions[i] != wantVersions[i] {
+			t.Errorf("request %d: expected api-version %s, got %s", i, wantVersions[i], capturedVersions[i])
+		}
+	}
+
+	// The deployment name resolves to the model it serves
+	if got := provider.ResolveModelName("reasoning"); got != "o4-mini" {
+		t.Errorf("expected deployment alias to resolve to o4-mini, got %s", got)
+	}
+}
+
+func TestAzureProvider_ClientCredentials(t *testing.T) {
+	tokenRequests := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if r.URL.Path != "/tenant-1/oauth2/v2.0/token" {
+			t.Errorf("unexpected token path: %s", r.URL.Path)
+		}
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "client-1" {
+			t.Errorf("unexpected token form: %v", r.Form)
+		}
+		if r.Form.Get("scope") != cognitiveServicesScope {
+			t.Errorf("unexpected scope: %s", r.Form.Get("scope"))
+		}
+		w.Write([]byte(`{"token_type":"Bearer","expires_in":3599,"access_token":"entra-token"}`))
+	}))
+	defer tokenServer.Close()
+
+	var capturedHeaders http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedHeaders = r.Header
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Content: "ok"}}},
+		})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		AzureEndpoint:     server.URL,
+		AzureAuthMode:     AzureAuthClientCredentials,
+		AzureTenantID:     "tenant-1",
+		AzureClientID:     "client-1",
+		AzureClientSecret: "secret",
+	}
+
+	provider, err := NewAzureProvider(cfg)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	provider.tokens.authorityURL = tokenServer.URL
+
+	if !provider.IsConfigured() {
+		t.Error("expected provider to be configured without an API key")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "gpt-4o"}); err != nil {
+			t.Fatalf("GenerateContent failed: %v", err)
+		}
+	}
+
+	if got := capturedHeaders.Get("Authorization"); got != "Bearer entra-token" {
+		t.Errorf("expected bearer token, got %q", got)
+	}
+	if capturedHeaders.Get("api-key") != "" {
+		t.Error("should not send api-key header with Entra ID auth")
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected token to be cached, got %d token requests", tokenRequests)
+	}
+}
+
+func TestEntraTokenSource_ManagedIdentityRefresh(t *testing.T) {
+	t.Setenv("IDENTITY_ENDPOINT", "")
+	t.Setenv("IDENTITY_HEADER", "")
+
+	tokenRequests := 0
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if r.Header.Get("Metadata") != "true" {
+			t.Error("missing Metadata header")
+		}
+		if r.URL.Query().Get("resource") != cognitiveServicesResource {
+			t.Errorf("unexpected resource: %s", r.URL.Query().Get("resource"))
+		}
+		// IMDS returns lifetimes as strings; this token is inside the refresh window
+		w.Write([]byte(`{"access_token":"mi-token","expires_in":"60"}`))
+	}))
+	defer imds.Close()
+
+	ts, err := newEntraTokenSource(AzureAuthManagedIdentity, "", "", "")
+	if err != nil {
+		t.Fatalf("newEntraTokenSource failed: %v", err)
+	}
+	ts.imdsURL = imds.URL
+
+	for i := 0; i < 2; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		if token != "mi-token" {
+			t.Errorf("expected mi-token, got %s", token)
+		}
+	}
+
+	if tokenRequests != 2 {
+		t.Errorf("expected a near-expiry token to be refreshed, got %d requests", tokenRequests)
+	}
+}
+
+func TestAzureProvider_ClientCredentialsMissing(t *testing.T) {
+	cfg := &config.Config{
+		AzureEndpoint: "https://example.openai.azure.com",
+		AzureAuthMode: AzureAuthClientCredentials,
+	}
+	_, err := NewAzureProvider(cfg)
+	if err == nil || !strings.Contains(err.Error(), "AZURE_CLIENT_SECRET") {
+		t.Errorf("expected error about missing client credentials, got: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+// sweepEvery is how many writes may pass between full sweeps of the cache
+// directory, which remove expired entries and pick up writes made by other
+// processes sharing it
+const sweepEvery = 256
+
+// ResponseCache is a content-addressed on-disk cache of model responses.
+// Entries expire after the TTL; when the directory grows past the size cap
+// the least recently used entries are evicted.
+type ResponseCache struct {
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+	mu       sync.Mutex
+	size     int64 // bytes on disk as of the last sweep, plus changes since
+	writes   int   // writes since the last sweep
+}
+
+// cacheEntry is the on-disk format of a cached response
+type cacheEntry struct {
+	CreatedAt time.Time           `json:"created_at"`
+	Response  types.ModelResponse `json:"response"`
+}
+
+// NewResponseCache creates a cache in dir, creating the directory if needed.
+// An empty dir uses the user cache directory.
+func NewResponseCache(dir string, ttl time.Duration, maxBytes int64) (*ResponseCache, error) {
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("locating cache directory: %w", err)
+		}
+		dir = filepath.Join(base, "relay-mcp", "responses")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	c := &ResponseCache{dir: dir, ttl: ttl, maxBytes: maxBytes}
+	c.mu.Lock()
+	c.sweep()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// CacheKey hashes everything that affects a model's output: the provider,
+// resolved model, prompts, history, image contents and sampling settings
+func CacheKey(pt types.ProviderType, model string, req *GenerateRequest) string {
+	type turn struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+
+	key := struct {
+		Provider        types.ProviderType   `json:"provider"`
+		Model           string               `json:"model"`
+		SystemPrompt    string               `json:"system_prompt"`
+		Prompt          string               `json:"prompt"`
+		History         []turn               `json:"history"`
+		Images          []string             `json:"images"`
+		Temperature     float64              `json:"temperature"`
+		MaxOutputTokens int                  `json:"max_output_tokens"`
+		Sampling        types.SamplingParams `json:"sampling"`
+		ProviderOptions map[string]any       `json:"provider_options,omitempty"`
+		ThinkingMode    types.ThinkingMode   `json:"thinking_mode"`
+		ThinkingBudget  int                  `json:"thinking_budget"`
+		ResponseSchema  *JSONSchema          `json:"response_schema,omitempty"`
+		Tools           []FunctionDef        `json:"tools,omitempty"`
+		ToolTurns       []ToolExchange       `json:"tool_turns,omitempty"`
+		ToolChoice      string               `json:"tool_choice,omitempty"`
+	}{
+		Provider:        pt,
+		Model:           model,
+		SystemPrompt:    req.SystemPrompt,
+		Prompt:          req.Prompt,
+		Temperature:     req.Temperature,
+		MaxOutputTokens: req.MaxOutputTokens,
+		Sampling:        req.Sampling,
+		ProviderOptions: req.ProviderOptions,
+		ThinkingMode:    req.ThinkingMode,
+		ThinkingBudget:  req.ThinkingBudget,
+		ResponseSchema:  req.ResponseSchema,
+		Tools:           req.Tools,
+		ToolTurns:       req.ToolTurns,
+		ToolChoice:      req.ToolChoice,
+	}
+
+	// Timestamps and bookkeeping fields don't reach the model
+	for _, t := range req.ConversationHistory {
+		key.History = append(key.History, turn{Role: t.Role, Content: t.Content})
+	}
+
+	// Hash image contents so the same path with a changed file misses
+	for _, img := range utils.ProcessImages(req.Images) {
+		sum := sha256.Sum256([]byte(img.Base64))
+		key.Images = append(key.Images, img.MimeType+":"+hex.EncodeToString(sum[:]))
+	}
+
+	data, _ := json.Marshal(key)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get returns a cached response, or false if missing or expired
+func (c *ResponseCache) Get(key string) (*types.ModelResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		c.remove(path, int64(len(data)))
+		return nil, false
+	}
+
+	if c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl {
+		c.remove(path, int64(len(data)))
+		return nil, false
+	}
+
+	// Touch for LRU eviction
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return &entry.Response, true
+}
+
+// Put stores a response and enforces the size cap. The directory is only
+// walked when the tracked size passes the cap or every sweepEvery writes.
+func (c *ResponseCache) Put(key string, resp *types.ModelResponse) error {
+	data, err := json.Marshal(cacheEntry{CreatedAt: time.Now(), Response: *resp})
+	if err != nil {
+		return fmt.Errorf("marshaling cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+
+	// Write then rename so readers never see a partial entry
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	c.size += int64(len(data)) - replaced
+	c.writes++
+
+	if (c.maxBytes > 0 && c.size > c.maxBytes) || c.writes >= sweepEvery {
+		c.sweep()
+	}
+	return nil
+}
+
+// remove deletes an entry of the given size. Callers must hold c.mu.
+func (c *ResponseCache) remove(path string, size int64) {
+	if err := os.Remove(path); err == nil {
+		c.size -= size
+	}
+}
+
+// sweep walks the cache directory, removing expired entries and then the
+// least recently used ones until the cache fits within maxBytes, and resets
+// the tracked size. Callers must hold c.mu.
+func (c *ResponseCache) sweep() {
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []file
+	var total int64
+
+	filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		// mtime is the last access (Get touches hits), never earlier than
+		// creation, so an entry untouched for the TTL has expired. Entries
+		// read since then may be expired too; Get drops those by CreatedAt.
+		if c.ttl > 0 && time.Since(info.ModTime()) > c.ttl {
+			os.Remove(path)
+			return nil
+		}
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+
+	defer func() {
+		c.size = total
+		c.writes = 0
+	}()
+
+	if c.maxBytes <= 0 || total <= c.maxBytes {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, f := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			slog.Debug("failed to evict cache entry", "path", f.path, "error", err)
+			continue
+		}
+		total -= f.size
+	}
+}
@@ -0,0 +1,185 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestCacheKey(t *testing.T) {
+	base := &GenerateRequest{
+		Prompt:      "review this",
+		Temperature: 0.3,
+		ConversationHistory: []types.ConversationTurn{
+			{Role: "user", Content: "hi", Timestamp: time.Unix(1, 0)},
+		},
+	}
+	key := CacheKey(types.ProviderOpenAI, "gpt-5.2", base)
+
+	// Timestamps don't affect the key
+	same := *base
+	same.ConversationHistory = []types.ConversationTurn{
+		{Role: "user", Content: "hi", Timestamp: time.Unix(2, 0)},
+	}
+	if CacheKey(types.ProviderOpenAI, "gpt-5.2", &same) != key {
+		t.Error("expected turn timestamps to be ignored")
+	}
+
+	changed := *base
+	changed.Temperature = 0.7
+	if CacheKey(types.ProviderOpenAI, "gpt-5.2", &changed) == key {
+		t.Error("expected temperature to change the key")
+	}
+	if CacheKey(types.ProviderOpenAI, "gpt-5.1", base) == key {
+		t.Error("expected model to change the key")
+	}
+	if CacheKey(types.ProviderOpenAI, "gpt-5.2", &GenerateRequest{Prompt: "review this", Temperature: 0.3}) == key {
+		t.Error("expected history to change the key")
+	}
+}
+
+func TestCacheKey_ImageContents(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "shot.png")
+	os.WriteFile(img, []byte("first"), 0o600)
+
+	req := &GenerateRequest{Prompt: "what is this", Images: []string{img}}
+	first := CacheKey(types.ProviderGemini, "gemini-2.5-pro", req)
+
+	os.WriteFile(img, []byte("second"), 0o600)
+	if CacheKey(types.ProviderGemini, "gemini-2.5-pro", req) == first {
+		t.Error("expected changed image contents to change the key")
+	}
+}
+
+func TestResponseCache_TTL(t *testing.T) {
+	cache, err := NewResponseCache(t.TempDir(), time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewResponseCache failed: %v", err)
+	}
+
+	key := CacheKey(types.ProviderOpenAI, "gpt-5.2", &GenerateRequest{Prompt: "p"})
+	if err := cache.Put(key, &types.ModelResponse{Content: "cached"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	resp, ok := cache.Get(key)
+	if !ok || resp.Content != "cached" {
+		t.Fatalf("expected cached response, got %v %v", resp, ok)
+	}
+
+	cache.ttl = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.Get(key); ok {
+		t.Error("expected expired entry to miss")
+	}
+	if _, err := os.Stat(cache.path(key)); !os.IsNotExist(err) {
+		t.Error("expected expired entry to be removed")
+	}
+}
+
+func TestResponseCache_SizeCap(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewResponseCache(dir, time.Hour, 600)
+	if err != nil {
+		t.Fatalf("NewResponseCache failed: %v", err)
+	}
+
+	var keys []string
+	for i := 0; i < 5; i++ {
+		key := CacheKey(types.ProviderOpenAI, "gpt-5.2", &GenerateRequest{Prompt: string(rune('a' + i))})
+		keys = append(keys, key)
+		if err := cache.Put(key, &types.ModelResponse{Content: "response body of some length"}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		// Distinct mtimes so eviction order is deterministic
+		old := time.Now().Add(time.Duration(i-10) * time.Second)
+		os.Chtimes(cache.path(key), old, old)
+	}
+
+	if _, ok := cache.Get(keys[0]); ok {
+		t.Error("expected the oldest entry to be evicted")
+	}
+	if _, ok := cache.Get(keys[4]); !ok {
+		t.Error("expected the newest entry to be kept")
+	}
+
+	// The tracked size matches the directory without walking it on every
+	// write, and rewriting an entry replaces its size rather than adding to it
+	onDisk := func() int64 {
+		var total int64
+		for _, key := range keys {
+			if info, err := os.Stat(cache.path(key)); err == nil {
+				total += info.Size()
+			}
+		}
+		return total
+	}
+	if cache.size != onDisk() || cache.size > 600 {
+		t.Errorf("tracked size %d, want %d within the 600-byte cap", cache.size, onDisk())
+	}
+	if err := cache.Put(keys[4], &types.ModelResponse{Content: "response body of some length"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if cache.size != onDisk() {
+		t.Errorf("tracked size %d after rewriting an entry, want %d", cache.size, onDisk())
+	}
+}
+
+func TestManagedProvider_Cache(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Content: "fresh"}, FinishReason: "stop"}},
+		})
+	}))
+	defer server.Close()
+
+	cache, err := NewResponseCache(t.TempDir(), time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewResponseCache failed: %v", err)
+	}
+
+	inner := NewOpenAICompatProvider(types.ProviderCustom, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "local", Aliases: []string{"l"}, SupportsSystemPrompts: true},
+	}, 0)
+	p := &managedProvider{Provider: inner, cache: cache}
+
+	req := &GenerateRequest{Prompt: "hi", Model: "local"}
+	resp, err := p.GenerateContent(context.Background(), req)
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if resp.Metadata["cache_hit"] != false {
+		t.Errorf("expected cache miss, got %v", resp.Metadata)
+	}
+
+	// The alias resolves to the same cache entry
+	resp, err = p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "l"})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if resp.Metadata["cache_hit"] != true || resp.Content != "fresh" {
+		t.Errorf("expected cache hit, got %v", resp.Metadata)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 API call, got %d", calls)
+	}
+
+	// no_cache goes to the API again
+	req.NoCache = true
+	if _, err := p.GenerateContent(context.Background(), req); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected no_cache to bypass the cache, got %d calls", calls)
+	}
+}
@@ -0,0 +1,353 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Cassette modes
+const (
+	CassetteReplay = "replay"
+	CassetteRecord = "record"
+)
+
+// redacted replaces secrets in recorded interactions. A recorded header with
+// this value only asserts that the header was sent.
+const redacted = "REDACTED"
+
+// Headers, query parameters and JSON or form fields never written to disk
+var (
+	secretHeaders = []string{"Authorization", "Api-Key", "X-Api-Key", "X-Goog-Api-Key", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	secretParams  = []string{"key", "api_key", "access_token", "client_secret", "refresh_token", "assertion"}
+)
+
+// Interaction is one recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a sanitized outgoing request. JSON bodies are stored
+// as JSON so golden files diff cleanly; other bodies are stored as strings.
+type RecordedRequest struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+}
+
+// RecordedResponse is a sanitized response
+type RecordedResponse struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+}
+
+// Cassette is an http.RoundTripper that records request and response pairs
+// to a golden file, or replays them without touching the network. In replay
+// mode each request must match a recorded one exactly: method, URL, the
+// recorded headers and the JSON body.
+type Cassette struct {
+	path      string
+	mode      string
+	transport http.RoundTripper // real transport used when recording
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewCassette opens a golden file. Replay mode loads the recorded
+// interactions; record mode starts an empty cassette and rewrites the file
+// after every request.
+func NewCassette(path, mode string) (*Cassette, error) {
+	c := &Cassette{path: path, mode: mode, transport: http.DefaultTransport}
+
+	switch mode {
+	case CassetteRecord:
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("creating cassette directory: %w", err)
+		}
+	case CassetteReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &c.interactions); err != nil {
+			return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+		}
+		c.used = make([]bool, len(c.interactions))
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q (want %s or %s)", mode, CassetteReplay, CassetteRecord)
+	}
+
+	return c, nil
+}
+
+// RoundTrip records or replays a request
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("cassette: reading request body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	recorded := RecordedRequest{
+		Method: req.Method,
+		URL:    sanitizeURL(req.URL),
+		Header: sanitizeHeader(req.Header),
+		Body:   sanitizeBody(body, req.Header.Get("Content-Type")),
+	}
+
+	if c.mode == CassetteRecord {
+		return c.record(req, recorded)
+	}
+	return c.replay(req, recorded)
+}
+
+func (c *Cassette) replay(req *http.Request, got RecordedRequest) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var mismatch error
+	for i, in := range c.interactions {
+		if c.used[i] || in.Request.Method != got.Method || in.Request.URL != got.URL {
+			continue
+		}
+		if err := matchRequest(in.Request, got); err != nil {
+			mismatch = err
+			continue
+		}
+		c.used[i] = true
+		return in.Response.httpResponse(req), nil
+	}
+
+	if mismatch != nil {
+		return nil, fmt.Errorf("cassette %s: %s %s: %w", filepath.Base(c.path), got.Method, got.URL, mismatch)
+	}
+	return nil, fmt.Errorf("cassette %s: no recorded interaction for %s %s", filepath.Base(c.path), got.Method, got.URL)
+}
+
+func (c *Cassette) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			Status: resp.StatusCode,
+			Header: sanitizeHeader(resp.Header),
+			Body:   sanitizeBody(body, resp.Header.Get("Content-Type")),
+		},
+	})
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c.interactions); err != nil {
+		return nil, fmt.Errorf("cassette: encoding interactions: %w", err)
+	}
+	if err := os.WriteFile(c.path, buf.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("cassette: writing %s: %w", c.path, err)
+	}
+
+	return resp, nil
+}
+
+// Unused returns how many recorded interactions were never replayed
+func (c *Cassette) Unused() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, used := range c.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// matchRequest compares a recorded request with an outgoing one. Recorded
+// headers must be sent with the same value; bodies must be equal JSON.
+func matchRequest(want, got RecordedRequest) error {
+	for name, value := range want.Header {
+		sent, ok := got.Header[name]
+		if !ok {
+			return fmt.Errorf("missing header %s", name)
+		}
+		if value != redacted && sent != value {
+			return fmt.Errorf("header %s is %q, recorded %q", name, sent, value)
+		}
+	}
+
+	if len(want.Body) == 0 && len(got.Body) == 0 {
+		return nil
+	}
+	var w, g any
+	if err := json.Unmarshal(want.Body, &w); err != nil {
+		return fmt.Errorf("recorded body: %w", err)
+	}
+	if err := json.Unmarshal(got.Body, &g); err != nil {
+		return fmt.Errorf("request body: %w", err)
+	}
+	if !reflect.DeepEqual(w, g) {
+		sent, _ := json.MarshalIndent(g, "", "  ")
+		return fmt.Errorf("request body differs from the recording, sent:\n%s", sent)
+	}
+	return nil
+}
+
+// httpResponse rebuilds the recorded response for a request
+func (r RecordedResponse) httpResponse(req *http.Request) *http.Response {
+	body := []byte(r.Body)
+	var text string
+	if json.Unmarshal(r.Body, &text) == nil {
+		body = []byte(text)
+	}
+
+	header := make(http.Header, len(r.Header))
+	for name, value := range r.Header {
+		header.Set(name, value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// sanitizeURL redacts secret query parameters
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	query := clean.Query()
+	for _, name := range secretParams {
+		if query.Has(name) {
+			query.Set(name, redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// sanitizeHeader flattens headers, redacting credentials
+func sanitizeHeader(h http.Header) map[string]string {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(h))
+	for name := range h {
+		out[name] = h.Get(name)
+	}
+	for _, name := range secretHeaders {
+		if _, ok := out[name]; ok {
+			out[name] = redacted
+		}
+	}
+	return out
+}
+
+// sanitizeBody stores JSON bodies as JSON and anything else as a string,
+// redacting secret fields at the top level of JSON objects and forms
+func sanitizeBody(body []byte, contentType string) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var obj map[string]any
+	if json.Unmarshal(body, &obj) == nil {
+		for _, name := range secretParams {
+			if _, ok := obj[name]; ok {
+				obj[name] = redacted
+			}
+		}
+		return marshalRaw(obj)
+	}
+	if json.Valid(body) {
+		return body
+	}
+
+	text := string(body)
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if form, err := url.ParseQuery(text); err == nil {
+			for _, name := range secretParams {
+				if form.Has(name) {
+					form.Set(name, redacted)
+				}
+			}
+			text = form.Encode()
+		}
+	}
+	return marshalRaw(text)
+}
+
+// marshalRaw encodes v without escaping <, > and &, which are common in
+// prompts and form bodies and would make golden files hard to read
+func marshalRaw(v any) json.RawMessage {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// transportHolder is implemented by providers that make HTTP calls
+type transportHolder interface {
+	SetTransport(rt http.RoundTripper)
+}
+
+// SetTransport routes the provider's HTTP calls through rt
+func (p *OpenAICompatProvider) SetTransport(rt http.RoundTripper) {
+	p.httpClient.Transport = rt
+}
+
+// SetTransport routes the provider's HTTP calls, including Entra ID token
+// requests, through rt
+func (p *AzureProvider) SetTransport(rt http.RoundTripper) {
+	p.httpClient.Transport = rt
+	if p.tokens != nil {
+		p.tokens.httpClient.Transport = rt
+	}
+}
+
+// SetTransport routes the provider's HTTP calls, including Vertex AI token
+// requests, through rt
+func (p *GeminiProvider) SetTransport(rt http.RoundTripper) {
+	p.httpClient.Transport = rt
+	if p.tokens != nil {
+		p.tokens.httpClient.Transport = rt
+	}
+}
@@ -0,0 +1,392 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// goldenCase replays testdata/cassettes/<name>.json against a provider built
+// by its real constructor, so the golden file pins the exact outgoing JSON
+type goldenCase struct {
+	name        string
+	provider    func(t *testing.T) Provider
+	req         *GenerateRequest
+	wantContent string
+	wantTokens  int
+	wantCalls   int
+}
+
+func goldenCases() []goldenCase {
+	history := []types.ConversationTurn{
+		{Role: "user", Content: "We use Go 1.27."},
+		{Role: "assistant", Content: "Noted."},
+	}
+	readFile := []FunctionDef{readFileDef}
+	toolTurn := []ToolExchange{{
+		Calls:   []types.ToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`}},
+		Results: []ToolResult{{CallID: "call_1", Name: "read_file", Content: "package main"}},
+	}}
+
+	return []goldenCase{
+		{
+			name: "openai_chat",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewOpenAIProvider(&config.Config{OpenAIAPIKey: "sk-test"}))
+			},
+			req: &GenerateRequest{
+				Prompt:              "Summarize the release notes.",
+				SystemPrompt:        "You are terse.",
+				Model:               "gpt5",
+				Temperature:         0.3,
+				MaxOutputTokens:     500,
+				ConversationHistory: history,
+			},
+			wantContent: "Three fixes, one feature.",
+			wantTokens:  48,
+		},
+		{
+			name: "openai_reasoning",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewOpenAIProvider(&config.Config{OpenAIAPIKey: "sk-test"}))
+			},
+			req: &GenerateRequest{
+				Prompt:          "Is 2^61-1 prime?",
+				Model:           "o3",
+				Temperature:     0.7,
+				MaxOutputTokens: 4000,
+				ThinkingMode:    types.ThinkingHigh,
+			},
+			wantContent: "Yes, it is a Mersenne prime.",
+			wantTokens:  1230,
+		},
+		{
+			name: "openai_responses",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewOpenAIProvider(&config.Config{
+					OpenAIAPIKey: "sk-test",
+					ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+						types.ProviderOpenAI: {{ModelName: "gpt-5-pro", API: types.APIResponses, SupportsExtendedThinking: true, SupportsJSONSchema: true}},
+					},
+				}))
+			},
+			req: &GenerateRequest{
+				Prompt:         "Judge this patch.",
+				SystemPrompt:   "You review code.",
+				Model:          "gpt-5-pro",
+				ThinkingMode:   types.ThinkingMedium,
+				ResponseSchema: testSchema,
+			},
+			wantContent: `{"score":8,"severity":"low"}`,
+			wantTokens:  160,
+		},
+		{
+			name: "openai_tools",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewOpenAIProvider(&config.Config{OpenAIAPIKey: "sk-test"}))
+			},
+			req: &GenerateRequest{
+				Prompt:    "What does main.go do?",
+				Model:     "gpt-5",
+				Tools:     readFile,
+				ToolTurns: toolTurn,
+			},
+			wantTokens: 95,
+			wantCalls:  1,
+		},
+		{
+			name: "azure_deployment",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewAzureProvider(&config.Config{
+					AzureAPIKey:     "azure-test",
+					AzureEndpoint:   "https://relay.openai.azure.com/",
+					AzureAPIVersion: "2024-10-21",
+					ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+						types.ProviderAzure: {{ModelName: "gpt-4o", Deployment: "prod-4o", APIVersion: "2025-01-01-preview", SupportsJSONSchema: true}},
+					},
+				}))
+			},
+			req: &GenerateRequest{
+				Prompt:          "Judge this patch.",
+				SystemPrompt:    "You review code.",
+				Model:           "prod-4o",
+				MaxOutputTokens: 300,
+				ResponseSchema:  testSchema,
+			},
+			wantContent: `{"score":6,"severity":"high"}`,
+			wantTokens:  70,
+		},
+		{
+			name: "gemini_chat",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewGeminiProvider(&config.Config{GeminiAPIKey: "gemini-test"}))
+			},
+			req: &GenerateRequest{
+				Prompt:              "What is in this image?",
+				SystemPrompt:        "You are terse.",
+				Model:               "gemini-2.5-pro",
+				Temperature:         0.5,
+				MaxOutputTokens:     1024,
+				ThinkingMode:        types.ThinkingLow,
+				ConversationHistory: history,
+				Images:              []string{testImageURI},
+			},
+			wantContent: "A single red pixel.",
+			wantTokens:  310,
+		},
+		{
+			name: "gemini_tools",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewGeminiProvider(&config.Config{GeminiAPIKey: "gemini-test"}))
+			},
+			req: &GenerateRequest{
+				Prompt:    "What does main.go do?",
+				Model:     "gemini-2.5-flash",
+				Tools:     readFile,
+				ToolTurns: toolTurn,
+			},
+			wantContent: "It declares package main.",
+			wantTokens:  120,
+		},
+		{
+			name: "gemini_thoughts",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewGeminiProvider(&config.Config{
+					GeminiAPIKey:          "gemini-test",
+					GeminiIncludeThoughts: true,
+					GeminiSafetySettings:  "BLOCK_ONLY_HIGH,DANGEROUS_CONTENT=BLOCK_NONE",
+				}))
+			},
+			req: &GenerateRequest{
+				Prompt:       "A or B?",
+				Model:        "gemini-2.5-pro",
+				ThinkingMode: types.ThinkingMedium,
+			},
+			wantContent: "Pick B.",
+			wantTokens:  173,
+		},
+		{
+			name: "gemini_vertex",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewGeminiProvider(&config.Config{
+					VertexProject:     "example-project",
+					VertexLocation:    "us-central1",
+					VertexCredentials: writeServiceAccount(t, googleTokenURL),
+				}))
+			},
+			req:         &GenerateRequest{Prompt: "Hello", SystemPrompt: "Be brief.", Model: "pro"},
+			wantContent: "Hello from Vertex.",
+			wantTokens:  15,
+		},
+		{
+			name: "xai_chat",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewXAIProvider(&config.Config{XAIAPIKey: "xai-test"}))
+			},
+			req:         &GenerateRequest{Prompt: "Hello", Model: "grok-beta"},
+			wantContent: "Hi there.",
+			wantTokens:  12,
+		},
+		{
+			name: "dial_chat",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewDIALProvider(&config.Config{DIALAPIKey: "dial-test", DIALEndpoint: "https://dial.example.com/openai"}))
+			},
+			req:         &GenerateRequest{Prompt: "Hello", Model: "gpt-4"},
+			wantContent: "Hello from DIAL.",
+			wantTokens:  14,
+		},
+		{
+			name: "openrouter_chat",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewOpenRouterProvider(&config.Config{OpenRouterAPIKey: "or-test"}))
+			},
+			req:         &GenerateRequest{Prompt: "Hello", Model: "sonnet", SystemPrompt: "Be brief."},
+			wantContent: "Hi.",
+			wantTokens:  20,
+		},
+		{
+			name: "custom_chat",
+			provider: func(t *testing.T) Provider {
+				return mustProvider(t)(NewCustomProvider(&config.Config{CustomAPIURL: "http://localhost:11434/v1"}))
+			},
+			req:         &GenerateRequest{Prompt: "Hello", Model: "llama", MaxOutputTokens: 64},
+			wantContent: "Hello! How can I help?",
+			wantTokens:  18,
+		},
+		{
+			name: "configured_header_auth",
+			provider: func(t *testing.T) Provider {
+				t.Setenv("GOLDEN_GATEWAY_KEY", "gateway-test")
+				return mustProvider(t)(NewConfiguredProvider(&config.Config{
+					ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+						"gateway": {{ModelName: "house-model"}},
+					},
+				}, config.ProviderConfig{
+					Name:       "gateway",
+					BaseURL:    "https://llm.internal.example.com/v1/",
+					AuthStyle:  config.AuthStyleHeader,
+					AuthHeader: "X-Api-Key",
+					APIKeyEnv:  "GOLDEN_GATEWAY_KEY",
+				}))
+			},
+			req:         &GenerateRequest{Prompt: "Hello", Model: "house-model"},
+			wantContent: "Hello from the gateway.",
+			wantTokens:  16,
+		},
+	}
+}
+
+// mustProvider unwraps a constructor's result
+func mustProvider(t *testing.T) func(Provider, error) Provider {
+	return func(p Provider, err error) Provider {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("creating provider: %v", err)
+		}
+		return p
+	}
+}
+
+func TestProviders_Golden(t *testing.T) {
+	for _, tt := range goldenCases() {
+		t.Run(tt.name, func(t *testing.T) {
+			cassette, err := NewCassette(filepath.Join("testdata", "cassettes", tt.name+".json"), CassetteReplay)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := tt.provider(t)
+			p.(transportHolder).SetTransport(cassette)
+
+			resp, err := p.GenerateContent(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+			if resp.Content != tt.wantContent || resp.TokensUsed.TotalTokens != tt.wantTokens || len(resp.ToolCalls) != tt.wantCalls {
+				t.Errorf("got content %q, %d tokens and %d tool calls; want %q, %d and %d",
+					resp.Content, resp.TokensUsed.TotalTokens, len(resp.ToolCalls), tt.wantContent, tt.wantTokens, tt.wantCalls)
+			}
+			if n := cassette.Unused(); n != 0 {
+				t.Errorf("%d recorded interactions were not replayed", n)
+			}
+		})
+	}
+}
+
+func TestCassette_ReplayMismatch(t *testing.T) {
+	cassette, err := NewCassette(filepath.Join("testdata", "cassettes", "xai_chat.json"), CassetteReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := NewXAIProvider(&config.Config{XAIAPIKey: "xai-test"})
+	p.SetTransport(cassette)
+
+	_, err = p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "Goodbye", Model: "grok-beta"})
+	if err == nil || !strings.Contains(err.Error(), "request body differs") || !strings.Contains(err.Error(), `"Goodbye"`) {
+		t.Errorf("expected a body mismatch showing the sent JSON, got %v", err)
+	}
+}
+
+func TestCassette_RecordSanitizes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"live-token","expires_in":3600}`)
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, "slow down")
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "recorded.json")
+	cassette, err := NewCassette(path, CassetteRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: cassette}
+
+	resp, err := client.PostForm(server.URL+"/token", map[string][]string{"client_secret": {"hunter2"}, "grant_type": {"client_credentials"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/v1beta/models/m:generateContent?key=secret-key", strings.NewReader(`{"api_key":"secret-key","prompt":"hi"}`))
+	req.Header.Set("Authorization", "Bearer secret-key")
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err = client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "secret-key", "live-token", "session=abc"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("recording leaks %q:\n%s", secret, data)
+		}
+	}
+
+	// The recording replays offline, including the non-JSON error body
+	replay, err := NewCassette(path, CassetteReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	req, _ = http.NewRequest("POST", server.URL+"/v1beta/models/m:generateContent?key=other-key", strings.NewReader(`{"prompt":"hi","api_key":"other-key"}`))
+	req.Header.Set("Authorization", "Bearer other-key")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = (&http.Client{Transport: replay}).Do(req)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the recorded 429, got %d", resp.StatusCode)
+	}
+	if _, err := (&http.Client{Transport: replay}).Do(req); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("each interaction should replay once, got %v", err)
+	}
+}
+
+func TestRegistry_HTTPCassette(t *testing.T) {
+	r := NewRegistry(&config.Config{
+		OpenAIAPIKey:     "sk-test",
+		HTTPCassetteFile: filepath.Join("testdata", "cassettes", "openai_chat.json"),
+		HTTPCassetteMode: CassetteReplay,
+		CostLedgerFile:   filepath.Join(t.TempDir(), "costs.jsonl"),
+	})
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	p, err := r.GetProviderForModel("gpt5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := p.GenerateContent(context.Background(), goldenCases()[0].req)
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if resp.Content != "Three fixes, one feature." {
+		t.Errorf("unexpected content %q", resp.Content)
+	}
+
+	var apiErr ErrAPIError
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "unrecorded", Model: "gpt5"}); err == nil || errors.As(err, &apiErr) {
+		t.Errorf("an unrecorded request should fail without reaching the network, got %v", err)
+	}
+}
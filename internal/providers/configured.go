@@ -0,0 +1,43 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+)
+
+// NewConfiguredProvider creates an OpenAI-compatible provider from a
+// relay.json providers entry
+func NewConfiguredProvider(cfg *config.Config, pc config.ProviderConfig) (*OpenAICompatProvider, error) {
+	apiKey := pc.APIKey()
+	if pc.AuthStyle != config.AuthStyleNone {
+		if pc.APIKeyEnv == "" {
+			return nil, fmt.Errorf("provider %s: api_key_env required unless auth_style is none", pc.Name)
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("%s not configured", pc.APIKeyEnv)
+		}
+	}
+
+	p := NewOpenAICompatProvider(
+		pc.ProviderType(),
+		apiKey,
+		strings.TrimSuffix(pc.BaseURL, "/"),
+		cfg.ModelRegistries[pc.ProviderType()],
+		pc.TimeoutDuration(5*time.Minute),
+	)
+
+	switch pc.AuthStyle {
+	case config.AuthStyleHeader:
+		p.authHeader, p.authPrefix = pc.AuthHeader, ""
+		if p.authHeader == "" {
+			p.authHeader = "api-key"
+		}
+	case config.AuthStyleNone:
+		p.authHeader, p.authPrefix = "", ""
+	}
+
+	return p, nil
+}
@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestConfiguredProvider_AuthStyles(t *testing.T) {
+	t.Setenv("TEST_GATEWAY_KEY", "gw-key")
+
+	tests := []struct {
+		name       string
+		pc         config.ProviderConfig
+		wantHeader string
+		wantValue  string
+	}{
+		{
+			name:       "bearer",
+			pc:         config.ProviderConfig{APIKeyEnv: "TEST_GATEWAY_KEY"},
+			wantHeader: "Authorization",
+			wantValue:  "Bearer gw-key",
+		},
+		{
+			name:       "custom header",
+			pc:         config.ProviderConfig{AuthStyle: config.AuthStyleHeader, AuthHeader: "X-Api-Key", APIKeyEnv: "TEST_GATEWAY_KEY"},
+			wantHeader: "X-Api-Key",
+			wantValue:  "gw-key",
+		},
+		{
+			name:       "none",
+			pc:         config.ProviderConfig{AuthStyle: config.AuthStyleNone},
+			wantHeader: "Authorization",
+			wantValue:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured http.Header
+			var capturedPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				captured = r.Header
+				capturedPath = r.URL.Path
+				json.NewEncoder(w).Encode(openAIResponse{
+					Choices: []openAIChoice{{Message: openAIMessage{Content: "ok"}}},
+				})
+			}))
+			defer server.Close()
+
+			pc := tt.pc
+			pc.Name = "gateway"
+			pc.BaseURL = server.URL + "/v1/"
+
+			cfg := &config.Config{
+				ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+					"gateway": {{Provider: "gateway", ModelName: "llama-3.3-70b"}},
+				},
+			}
+
+			p, err := NewConfiguredProvider(cfg, pc)
+			if err != nil {
+				t.Fatalf("NewConfiguredProvider failed: %v", err)
+			}
+			if !p.IsConfigured() {
+				t.Error("expected provider to be configured")
+			}
+
+			resp, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "llama-3.3-70b"})
+			if err != nil {
+				t.Fatalf("GenerateContent failed: %v", err)
+			}
+
+			if resp.Provider != "gateway" {
+				t.Errorf("expected provider gateway, got %s", resp.Provider)
+			}
+			if capturedPath != "/v1/chat/completions" {
+				t.Errorf("unexpected path %s", capturedPath)
+			}
+			if got := captured.Get(tt.wantHeader); got != tt.wantValue {
+				t.Errorf("expected %s %q, got %q", tt.wantHeader, tt.wantValue, got)
+			}
+		})
+	}
+}
+
+func TestConfiguredProvider_MissingKey(t *testing.T) {
+	pc := config.ProviderConfig{Name: "groq", BaseURL: "https://example.com/v1", APIKeyEnv: "TEST_UNSET_GROQ_KEY"}
+	if _, err := NewConfiguredProvider(&config.Config{}, pc); err == nil {
+		t.Error("expected error when the key variable is unset")
+	}
+}
+
+func TestRegistry_ConfiguredProviderPriority(t *testing.T) {
+	first := 0
+	cfg := &config.Config{
+		ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+			"vllm":     {{Provider: "vllm", ModelName: "qwen3-coder", IntelligenceScore: 60}},
+			"lmstudio": {{Provider: "lmstudio", ModelName: "gemma-3", IntelligenceScore: 40}},
+		},
+		Providers: []config.ProviderConfig{
+			{Name: "vllm", BaseURL: "http://localhost:8000/v1", AuthStyle: config.AuthStyleNone, Priority: &first},
+			{Name: "lmstudio", BaseURL: "http://localhost:1234/v1", AuthStyle: config.AuthStyleNone},
+		},
+	}
+
+	r := NewRegistry(cfg)
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	priority := r.Priority()
+	if priority[0] != "vllm" {
+		t.Errorf("expected vllm first, got %v", priority)
+	}
+	lm, or := slices.Index(priority, "lmstudio"), slices.Index(priority, types.ProviderOpenRouter)
+	if lm < 0 || lm != or-1 {
+		t.Errorf("expected lmstudio just before openrouter, got %v", priority)
+	}
+
+	p, err := r.GetProviderForModel("gemma-3")
+	if err != nil {
+		t.Fatalf("GetProviderForModel failed: %v", err)
+	}
+	if p.GetProviderType() != "lmstudio" {
+		t.Errorf("expected lmstudio provider, got %s", p.GetProviderType())
+	}
+}
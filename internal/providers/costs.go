@@ -0,0 +1,405 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+)
+
+// ledgerRetentionDays is how many days of spend the ledger file keeps
+const ledgerRetentionDays = 31
+
+// CostLedger totals model spend per day, tool and model, and per
+// conversation thread. Each call is appended to a log file, so budgets
+// survive restarts, and the log is re-read before totals are used, so relay
+// processes sharing the file see each other's spend.
+type CostLedger struct {
+	path    string // empty keeps the ledger in memory
+	days    map[string]*DailySpend
+	threads map[string]float64
+	now     func() time.Time
+	mu      sync.Mutex
+
+	readMu sync.Mutex // serializes reads of the log
+	offset int64      // bytes of the log already counted
+}
+
+// DailySpend is the spend recorded on one day
+type DailySpend struct {
+	TotalUSD float64            `json:"total_usd"`
+	Calls    int                `json:"calls"`
+	Tools    map[string]float64 `json:"tools,omitempty"`
+	Models   map[string]float64 `json:"models,omitempty"`
+}
+
+// ledgerEntry is one call in the log
+type ledgerEntry struct {
+	Day     string  `json:"day"`
+	Tool    string  `json:"tool,omitempty"`
+	Thread  string  `json:"thread,omitempty"`
+	Model   string  `json:"model"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// defaultLedgerPath returns costs.jsonl in the user cache directory
+func defaultLedgerPath() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locating cache directory: %w", err)
+	}
+	return filepath.Join(base, "relay-mcp", "costs.jsonl"), nil
+}
+
+// NewCostLedger loads the ledger at path, first dropping entries past the
+// retention window. An empty path keeps it in memory.
+func NewCostLedger(path string) (*CostLedger, error) {
+	return newCostLedger(path, time.Now)
+}
+
+func newCostLedger(path string, now func() time.Time) (*CostLedger, error) {
+	l := &CostLedger{
+		path:    path,
+		days:    make(map[string]*DailySpend),
+		threads: make(map[string]float64),
+		now:     now,
+	}
+
+	if path == "" {
+		return l, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("creating cost ledger directory: %w", err)
+	}
+	if err := l.compact(); err != nil {
+		return nil, fmt.Errorf("compacting cost ledger %s: %w", path, err)
+	}
+	if err := l.refresh(); err != nil {
+		return nil, fmt.Errorf("reading cost ledger %s: %w", path, err)
+	}
+
+	return l, nil
+}
+
+func (l *CostLedger) today() string {
+	return l.now().Format(time.DateOnly)
+}
+
+// Record adds a call's cost to today's totals and the thread's total
+func (l *CostLedger) Record(tool, threadID, model string, costUSD float64) {
+	e := ledgerEntry{Day: l.today(), Tool: tool, Thread: threadID, Model: model, CostUSD: costUSD}
+	if l.path == "" {
+		l.add(e)
+		return
+	}
+
+	if err := l.append(e); err != nil {
+		slog.Warn("failed to save cost ledger", "path", l.path, "error", err)
+		l.add(e)
+		return
+	}
+	if err := l.refresh(); err != nil {
+		slog.Warn("failed to read cost ledger", "path", l.path, "error", err)
+	}
+}
+
+// add counts an entry in the totals
+func (l *CostLedger) add(e ledgerEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day, ok := l.days[e.Day]
+	if !ok {
+		day = &DailySpend{}
+		l.days[e.Day] = day
+	}
+	day.TotalUSD += e.CostUSD
+	day.Calls++
+	if e.Tool != "" {
+		if day.Tools == nil {
+			day.Tools = make(map[string]float64)
+		}
+		day.Tools[e.Tool] += e.CostUSD
+	}
+	if day.Models == nil {
+		day.Models = make(map[string]float64)
+	}
+	day.Models[e.Model] += e.CostUSD
+
+	if e.Thread != "" {
+		l.threads[e.Thread] += e.CostUSD
+	}
+}
+
+// append writes an entry to the end of the log in a single write, which
+// O_APPEND keeps whole when several processes share the file
+func (l *CostLedger) append(e ledgerEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// refresh counts entries appended to the log since the last read, by this
+// process or another. A log that shrank was compacted and is read again
+// from the start.
+func (l *CostLedger) refresh() error {
+	if l.path == "" {
+		return nil
+	}
+	l.readMu.Lock()
+	defer l.readMu.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == l.offset {
+		return nil
+	}
+	if info.Size() < l.offset {
+		l.mu.Lock()
+		l.days = make(map[string]*DailySpend)
+		l.threads = make(map[string]float64)
+		l.mu.Unlock()
+		l.offset = 0
+	}
+
+	data := make([]byte, info.Size()-l.offset)
+	if _, err := f.ReadAt(data, l.offset); err != nil && err != io.EOF {
+		return err
+	}
+
+	// A line still being written is left for the next read
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	for _, line := range bytes.Split(data[:complete], []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e ledgerEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			slog.Warn("skipping malformed cost ledger entry", "path", l.path, "error", err)
+			continue
+		}
+		l.add(e)
+	}
+	l.offset += int64(complete)
+	return nil
+}
+
+// compact rewrites the log without entries past the retention window.
+// Entries another process appends while it runs may be lost, so it only
+// runs at startup and only when there is something to drop.
+func (l *CostLedger) compact() error {
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cutoff := l.now().AddDate(0, 0, -ledgerRetentionDays).Format(time.DateOnly)
+	var kept [][]byte
+	dropped := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e ledgerEntry
+		if err := json.Unmarshal(line, &e); err != nil || e.Day < cutoff {
+			dropped++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if dropped == 0 {
+		return nil
+	}
+
+	var out []byte
+	for _, line := range kept {
+		out = append(append(out, line...), '\n')
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// Today returns a copy of today's spend
+func (l *CostLedger) Today() DailySpend {
+	if err := l.refresh(); err != nil {
+		slog.Warn("failed to read cost ledger", "path", l.path, "error", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day, ok := l.days[l.today()]
+	if !ok {
+		return DailySpend{}
+	}
+
+	out := DailySpend{TotalUSD: day.TotalUSD, Calls: day.Calls}
+	if len(day.Tools) > 0 {
+		out.Tools = make(map[string]float64, len(day.Tools))
+		for k, v := range day.Tools {
+			out.Tools[k] = v
+		}
+	}
+	if len(day.Models) > 0 {
+		out.Models = make(map[string]float64, len(day.Models))
+		for k, v := range day.Models {
+			out.Models[k] = v
+		}
+	}
+	return out
+}
+
+// ThreadSpend returns the spend recorded against a conversation thread
+func (l *CostLedger) ThreadSpend(threadID string) float64 {
+	if err := l.refresh(); err != nil {
+		slog.Warn("failed to read cost ledger", "path", l.path, "error", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.threads[threadID]
+}
+
+// CheckBudget returns ErrBudgetExceeded when the day, the tool or the thread
+// has reached its limit
+func (l *CostLedger) CheckBudget(b config.BudgetConfig, tool, threadID string) error {
+	today := l.Today()
+
+	if b.DailyUSD > 0 && today.TotalUSD >= b.DailyUSD {
+		return ErrBudgetExceeded{Scope: "daily", LimitUSD: b.DailyUSD, SpentUSD: today.TotalUSD}
+	}
+	if limit, ok := b.PerToolUSD[tool]; ok && limit > 0 && today.Tools[tool] >= limit {
+		return ErrBudgetExceeded{Scope: "daily " + tool, LimitUSD: limit, SpentUSD: today.Tools[tool]}
+	}
+	if threadID != "" && b.PerThreadUSD > 0 {
+		if spent := l.ThreadSpend(threadID); spent >= b.PerThreadUSD {
+			return ErrBudgetExceeded{Scope: "thread " + threadID, LimitUSD: b.PerThreadUSD, SpentUSD: spent}
+		}
+	}
+	return nil
+}
+
+// SortedSpend returns a spend map's keys ordered by descending cost
+func SortedSpend(spend map[string]float64) []string {
+	keys := make([]string, 0, len(spend))
+	for k := range spend {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if spend[keys[i]] != spend[keys[j]] {
+			return spend[keys[i]] > spend[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// ToolUsage accumulates the model calls made while serving one tool call
+type ToolUsage struct {
+	Tool        string
+	Calls       int
+	CachedCalls int
+	Unpriced    int // calls to models without pricing
+	Tokens      int
+	CostUSD     float64
+	mu          sync.Mutex
+}
+
+type toolUsageKey struct{}
+
+// WithToolUsage returns a context that attributes model calls to tool and
+// collects their usage
+func WithToolUsage(ctx context.Context, tool string) (context.Context, *ToolUsage) {
+	usage := &ToolUsage{Tool: tool}
+	return context.WithValue(ctx, toolUsageKey{}, usage), usage
+}
+
+// toolUsageFrom returns the collector in ctx, or nil
+func toolUsageFrom(ctx context.Context) *ToolUsage {
+	usage, _ := ctx.Value(toolUsageKey{}).(*ToolUsage)
+	return usage
+}
+
+// toolName returns the tool the call is attributed to, if any
+func toolName(ctx context.Context) string {
+	if usage := toolUsageFrom(ctx); usage != nil {
+		return usage.Tool
+	}
+	return ""
+}
+
+func (u *ToolUsage) add(tokens int, costUSD float64, priced, cached bool) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.Calls++
+	u.Tokens += tokens
+	u.CostUSD += costUSD
+	if cached {
+		u.CachedCalls++
+	} else if !priced {
+		u.Unpriced++
+	}
+}
+
+// Summary describes the usage in one line, or "" when no model was called
+func (u *ToolUsage) Summary() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.Calls == 0 {
+		return ""
+	}
+
+	calls := "call"
+	if u.Calls != 1 {
+		calls = "calls"
+	}
+	s := fmt.Sprintf("$%.4f (%d model %s, %d tokens", u.CostUSD, u.Calls, calls, u.Tokens)
+	if u.CachedCalls > 0 {
+		s += fmt.Sprintf(", %d cached", u.CachedCalls)
+	}
+	if u.Unpriced > 0 {
+		s += fmt.Sprintf(", %d unpriced", u.Unpriced)
+	}
+	return s + ")"
+}
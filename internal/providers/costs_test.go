@@ -0,0 +1,332 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestModelCost(t *testing.T) {
+	caps := types.ModelCapabilities{
+		InputPricePerMTok:       2,
+		OutputPricePerMTok:      8,
+		CachedInputPricePerMTok: 0.5,
+	}
+
+	tests := []struct {
+		name  string
+		usage types.TokenUsage
+		want  float64
+	}{
+		{"plain", types.TokenUsage{PromptTokens: 1_000_000, CompletionTokens: 500_000}, 2 + 4},
+		{"cached prompt", types.TokenUsage{PromptTokens: 1_000_000, CachedTokens: 400_000}, 0.6*2 + 0.4*0.5},
+		{"thinking at output price", types.TokenUsage{CompletionTokens: 1_000_000, ThinkingTokens: 600_000}, 8},
+		{"empty", types.TokenUsage{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := caps.Cost(tt.usage); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Cost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	caps.ThinkingPricePerMTok = 4
+	got := caps.Cost(types.TokenUsage{CompletionTokens: 1_000_000, ThinkingTokens: 500_000})
+	if math.Abs(got-6) > 1e-9 {
+		t.Errorf("expected thinking tokens at their own price, got %v", got)
+	}
+}
+
+func TestCostLedger_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.jsonl")
+
+	ledger, err := NewCostLedger(path)
+	if err != nil {
+		t.Fatalf("NewCostLedger failed: %v", err)
+	}
+	ledger.Record("chat", "thread-1", "gpt-4.1", 0.25)
+	ledger.Record("consensus", "thread-2", "gpt-4.1", 0.5)
+	ledger.Record("chat", "thread-1", "gemini-2.5-flash", 0.05)
+
+	if got := ledger.ThreadSpend("thread-1"); math.Abs(got-0.3) > 1e-9 {
+		t.Errorf("expected thread spend 0.3, got %v", got)
+	}
+
+	reloaded, err := NewCostLedger(path)
+	if err != nil {
+		t.Fatalf("reloading ledger failed: %v", err)
+	}
+	today := reloaded.Today()
+	if today.Calls != 3 || math.Abs(today.TotalUSD-0.8) > 1e-9 {
+		t.Errorf("expected 3 calls totalling 0.8, got %+v", today)
+	}
+	if math.Abs(today.Tools["chat"]-0.3) > 1e-9 || math.Abs(today.Models["gpt-4.1"]-0.75) > 1e-9 {
+		t.Errorf("unexpected breakdown: %+v", today)
+	}
+	if got := reloaded.ThreadSpend("thread-1"); math.Abs(got-0.3) > 1e-9 {
+		t.Errorf("expected thread spend to be persisted, got %v", got)
+	}
+}
+
+func TestCostLedger_SharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.jsonl")
+
+	// Two relay processes on the same ledger
+	a, err := NewCostLedger(path)
+	if err != nil {
+		t.Fatalf("NewCostLedger failed: %v", err)
+	}
+	b, err := NewCostLedger(path)
+	if err != nil {
+		t.Fatalf("NewCostLedger failed: %v", err)
+	}
+
+	a.Record("chat", "thread-1", "m", 1)
+	b.Record("chat", "thread-1", "m", 2)
+	a.Record("consensus", "", "m", 0.5)
+
+	for name, l := range map[string]*CostLedger{"a": a, "b": b} {
+		if today := l.Today(); today.Calls != 3 || math.Abs(today.TotalUSD-3.5) > 1e-9 {
+			t.Errorf("%s: expected both processes' spend, got %+v", name, today)
+		}
+		if got := l.ThreadSpend("thread-1"); math.Abs(got-3) > 1e-9 {
+			t.Errorf("%s: expected thread spend 3, got %v", name, got)
+		}
+	}
+}
+
+func TestCostLedger_Retention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.jsonl")
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+
+	ledger, _ := NewCostLedger(path)
+	ledger.now = func() time.Time { return day }
+	ledger.Record("chat", "old-thread", "m", 1)
+	day = day.AddDate(0, 0, ledgerRetentionDays+1)
+	ledger.Record("chat", "", "m", 2)
+
+	// Compaction happens on load
+	reloaded, err := newCostLedger(path, func() time.Time { return day })
+	if err != nil {
+		t.Fatalf("NewCostLedger failed: %v", err)
+	}
+	if today := reloaded.Today(); today.TotalUSD != 2 {
+		t.Errorf("expected today's spend, got %+v", today)
+	}
+	if reloaded.ThreadSpend("old-thread") != 0 {
+		t.Error("expected entries past retention to be dropped")
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("expected the log compacted to 1 entry, got %d", lines)
+	}
+
+	// The other ledger notices the shorter log and reads it again
+	if today := ledger.Today(); today.TotalUSD != 2 || ledger.ThreadSpend("old-thread") != 0 {
+		t.Errorf("expected totals rebuilt after compaction, got %+v", today)
+	}
+}
+
+func TestCostLedger_NewDay(t *testing.T) {
+	ledger, _ := NewCostLedger("")
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	ledger.now = func() time.Time { return day }
+
+	ledger.Record("chat", "", "m", 1)
+	day = day.AddDate(0, 0, 1)
+
+	if today := ledger.Today(); today.TotalUSD != 0 {
+		t.Errorf("expected a fresh day, got %+v", today)
+	}
+}
+
+func TestCostLedger_CheckBudget(t *testing.T) {
+	ledger, _ := NewCostLedger("")
+	ledger.Record("consensus", "thread-1", "m", 1.5)
+
+	tests := []struct {
+		name      string
+		budgets   config.BudgetConfig
+		tool      string
+		thread    string
+		wantScope string
+	}{
+		{"no limits", config.BudgetConfig{}, "chat", "thread-1", ""},
+		{"daily", config.BudgetConfig{DailyUSD: 1}, "chat", "", "daily"},
+		{"daily under", config.BudgetConfig{DailyUSD: 2}, "chat", "", ""},
+		{"tool", config.BudgetConfig{PerToolUSD: map[string]float64{"consensus": 1}}, "consensus", "", "daily consensus"},
+		{"other tool", config.BudgetConfig{PerToolUSD: map[string]float64{"consensus": 1}}, "chat", "", ""},
+		{"thread", config.BudgetConfig{PerThreadUSD: 1}, "chat", "thread-1", "thread thread-1"},
+		{"new thread", config.BudgetConfig{PerThreadUSD: 1}, "chat", "thread-2", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ledger.CheckBudget(tt.budgets, tt.tool, tt.thread)
+			if tt.wantScope == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			var budgetErr ErrBudgetExceeded
+			if !errors.As(err, &budgetErr) || budgetErr.Scope != tt.wantScope {
+				t.Errorf("expected %s budget error, got %v", tt.wantScope, err)
+			}
+		})
+	}
+}
+
+// newPricedServer returns a server that answers every chat call with the
+// given usage, counting the calls
+func newPricedServer(t *testing.T, content string, calls *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q}}],
+			"usage":{"prompt_tokens":1000000,"completion_tokens":100000,"total_tokens":1100000}}`, content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newBudgetRegistry builds a registry with an expensive and a cheap provider
+func newBudgetRegistry(t *testing.T, budgets config.BudgetConfig) (*Registry, *int, *int) {
+	t.Helper()
+	var bigCalls, smallCalls int
+	big := newPricedServer(t, "big", &bigCalls)
+	small := newPricedServer(t, "small", &smallCalls)
+
+	r := NewRegistry(&config.Config{Budgets: budgets})
+	r.providers[types.ProviderCustom] = &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderCustom, "key", big.URL, []types.ModelCapabilities{
+			{Provider: types.ProviderCustom, ModelName: "big", InputPricePerMTok: 10, OutputPricePerMTok: 30},
+		}, 0),
+		registry: r,
+	}
+	r.providers[types.ProviderOpenRouter] = &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderOpenRouter, "key", small.URL, []types.ModelCapabilities{
+			{Provider: types.ProviderOpenRouter, ModelName: "small", InputPricePerMTok: 0.1, OutputPricePerMTok: 0.4},
+		}, 0),
+		registry: r,
+	}
+	return r, &bigCalls, &smallCalls
+}
+
+func TestManagedProvider_RecordsCost(t *testing.T) {
+	r, _, _ := newBudgetRegistry(t, config.BudgetConfig{})
+	p, _ := r.GetProvider(types.ProviderCustom)
+
+	ctx, usage := WithToolUsage(context.Background(), "chat")
+	resp, err := p.GenerateContent(ctx, &GenerateRequest{Prompt: "hi", Model: "big", ThreadID: "thread-1"})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	if math.Abs(resp.CostUSD-13) > 1e-9 {
+		t.Errorf("expected cost $13, got %v", resp.CostUSD)
+	}
+	if today := r.Costs().Today(); math.Abs(today.Tools["chat"]-13) > 1e-9 {
+		t.Errorf("expected spend attributed to chat, got %+v", today)
+	}
+	if math.Abs(r.Costs().ThreadSpend("thread-1")-13) > 1e-9 {
+		t.Error("expected spend attributed to the thread")
+	}
+	if summary := usage.Summary(); !strings.HasPrefix(summary, "$13.0000 (1 model call") {
+		t.Errorf("unexpected usage summary %q", summary)
+	}
+}
+
+func TestManagedProvider_BudgetRefuse(t *testing.T) {
+	r, bigCalls, _ := newBudgetRegistry(t, config.BudgetConfig{DailyUSD: 10})
+	p, _ := r.GetProvider(types.ProviderCustom)
+
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "one", Model: "big"}); err != nil {
+		t.Fatalf("first call should be within budget: %v", err)
+	}
+
+	_, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "two", Model: "big"})
+	var budgetErr ErrBudgetExceeded
+	if !errors.As(err, &budgetErr) || budgetErr.Scope != "daily" {
+		t.Fatalf("expected daily budget error, got %v", err)
+	}
+	if *bigCalls != 1 {
+		t.Errorf("refused call should not reach the provider, got %d calls", *bigCalls)
+	}
+
+	// A provider-qualified model outside the catalog is refused as well
+	r.cfg.ModelDiscovery = true
+	unlisted, err := r.GetProviderForModel("custom:big-2024-08-06")
+	if err != nil {
+		t.Fatalf("GetProviderForModel failed: %v", err)
+	}
+	_, err = unlisted.GenerateContent(context.Background(), &GenerateRequest{Prompt: "three", Model: "custom:big-2024-08-06"})
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected budget error for unlisted model, got %v", err)
+	}
+	if *bigCalls != 1 {
+		t.Errorf("refused unlisted call should not reach the provider, got %d calls", *bigCalls)
+	}
+}
+
+func TestManagedProvider_BudgetDowngrade(t *testing.T) {
+	r, bigCalls, smallCalls := newBudgetRegistry(t, config.BudgetConfig{
+		PerThreadUSD: 10,
+		Action:       config.BudgetDowngrade,
+	})
+	p, _ := r.GetProvider(types.ProviderCustom)
+
+	req := &GenerateRequest{Prompt: "hi", Model: "big", ThreadID: "thread-1"}
+	if _, err := p.GenerateContent(context.Background(), req); err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+
+	resp, err := p.GenerateContent(context.Background(), req)
+	if err != nil {
+		t.Fatalf("downgraded call failed: %v", err)
+	}
+	if resp.Content != "small" || *bigCalls != 1 || *smallCalls != 1 {
+		t.Errorf("expected second call served by the cheap model, got %q (big=%d small=%d)", resp.Content, *bigCalls, *smallCalls)
+	}
+	if note, _ := resp.Metadata["budget_downgrade"].(string); !strings.HasPrefix(note, "big -> small") {
+		t.Errorf("expected downgrade in metadata, got %v", resp.Metadata)
+	}
+}
+
+func TestRegistry_DowngradeToChecks(t *testing.T) {
+	r, _, _ := newBudgetRegistry(t, config.BudgetConfig{DowngradeTo: "small"})
+	big := &types.ModelCapabilities{ModelName: "big", InputPricePerMTok: 10, OutputPricePerMTok: 30}
+	small := &types.ModelCapabilities{ModelName: "small", InputPricePerMTok: 0.1, OutputPricePerMTok: 0.4}
+
+	if target, _, err := r.downgradeModel(big, false); err != nil || target.ModelName != "small" {
+		t.Fatalf("expected a downgrade to small, got %v, %v", target, err)
+	}
+	if _, _, err := r.downgradeModel(big, true); err == nil || !strings.Contains(err.Error(), "does not support images") {
+		t.Errorf("expected a target without vision to be rejected, got %v", err)
+	}
+
+	r.cfg.Budgets.DowngradeTo = "big"
+	if _, _, err := r.downgradeModel(small, false); err == nil || !strings.Contains(err.Error(), "not cheaper") {
+		t.Errorf("expected a pricier target to be rejected, got %v", err)
+	}
+
+	r.cfg.Budgets.DowngradeTo = "small"
+	r.health = newHealthTracker(1, time.Minute)
+	r.health.record(types.ProviderOpenRouter, time.Second, ErrAPIError{Provider: types.ProviderOpenRouter, StatusCode: 503})
+	if _, _, err := r.downgradeModel(big, false); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("expected a target behind an open circuit to be rejected, got %v", err)
+	}
+}
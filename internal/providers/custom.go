@@ -64,5 +64,15 @@ func defaultCustomModels() []types.ModelCapabilities {
 			SupportsVision:           false,
 			AllowCodeGeneration:      true,
 		},
+		{
+			Provider:            types.ProviderCustom,
+			ModelName:           "nomic-embed-text",
+			FriendlyName:        "Nomic Embed Text",
+			IntelligenceScore:   40,
+			Aliases:             []string{"local-embed"},
+			ContextWindow:       8192,
+			Embedding:           true,
+			EmbeddingDimensions: 768,
+		},
 	}
 }
@@ -40,13 +40,14 @@ func NewDIALProvider(cfg *config.Config) (*DIALProvider, error) {
 func defaultDIALModels() []types.ModelCapabilities {
 	return []types.ModelCapabilities{
 		{
-			Provider:          types.ProviderDIAL,
-			ModelName:         "gpt-4",
-			FriendlyName:      "DIAL GPT-4",
-			IntelligenceScore: 90,
-			ContextWindow:     8192,
-			MaxOutputTokens:   4096,
-			SupportsStreaming: true,
+			Provider:              types.ProviderDIAL,
+			ModelName:             "gpt-4",
+			FriendlyName:          "DIAL GPT-4",
+			IntelligenceScore:     90,
+			ContextWindow:         8192,
+			MaxOutputTokens:       4096,
+			SupportsStreaming:     true,
+			SupportsSystemPrompts: true,
 		},
 	}
 }
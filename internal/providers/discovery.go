@@ -0,0 +1,154 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Defaults applied to discovered models when the endpoint doesn't report limits
+const (
+	discoveredIntelligenceScore = 10
+	discoveredContextWindow     = 8192
+	discoveredMaxOutputTokens   = 4096
+)
+
+// RefreshModels queries the /models endpoint and merges unknown models
+func (p *OpenAICompatProvider) RefreshModels(ctx context.Context) (int, error) {
+	models, err := p.DiscoverModels(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return p.MergeDiscoveredModels(models), nil
+}
+
+// DiscoverModels lists the models served by an OpenAI-compatible endpoint
+func (p *OpenAICompatProvider) DiscoverModels(ctx context.Context) ([]types.ModelCapabilities, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	p.setAuth(httpReq)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrAPIError{Provider: p.providerType, StatusCode: resp.StatusCode, Message: string(respBody)}
+	}
+
+	var list openAIModelList
+	if err := json.Unmarshal(respBody, &list); err != nil {
+		return nil, fmt.Errorf("parsing model list: %w", err)
+	}
+
+	models := make([]types.ModelCapabilities, 0, len(list.Data))
+	for _, entry := range list.Data {
+		if entry.ID == "" {
+			continue
+		}
+		models = append(models, inferCapabilities(p.providerType, entry))
+	}
+
+	return models, nil
+}
+
+// inferCapabilities builds capabilities from whatever metadata the endpoint returns.
+// OpenRouter reports context length, modalities and supported parameters; vLLM
+// reports max_model_len; plain OpenAI only returns IDs.
+func inferCapabilities(pt types.ProviderType, entry openAIModelEntry) types.ModelCapabilities {
+	caps := types.ModelCapabilities{
+		Provider:              pt,
+		ModelName:             entry.ID,
+		FriendlyName:          entry.ID,
+		IntelligenceScore:     discoveredIntelligenceScore,
+		ContextWindow:         discoveredContextWindow,
+		MaxOutputTokens:       discoveredMaxOutputTokens,
+		SupportsSystemPrompts: true,
+		SupportsStreaming:     true,
+		Discovered:            true,
+	}
+
+	if entry.Name != "" {
+		caps.FriendlyName = entry.Name
+	}
+
+	switch {
+	case entry.ContextLength > 0:
+		caps.ContextWindow = entry.ContextLength
+	case entry.MaxModelLen > 0:
+		caps.ContextWindow = entry.MaxModelLen
+	case entry.ContextWindow > 0:
+		caps.ContextWindow = entry.ContextWindow
+	}
+
+	if entry.TopProvider.MaxCompletionTokens > 0 {
+		caps.MaxOutputTokens = entry.TopProvider.MaxCompletionTokens
+	}
+	if caps.MaxOutputTokens > caps.ContextWindow {
+		caps.MaxOutputTokens = caps.ContextWindow
+	}
+
+	for _, m := range entry.Architecture.InputModalities {
+		if m == "image" {
+			caps.SupportsVision = true
+		}
+	}
+	if strings.Contains(entry.Architecture.Modality, "image") {
+		caps.SupportsVision = true
+	}
+
+	// Embedding models are served by Embed, never by GenerateContent
+	if strings.Contains(strings.ToLower(entry.ID), "embed") {
+		caps.Embedding = true
+		caps.SupportsSystemPrompts = false
+		caps.SupportsStreaming = false
+	}
+
+	for _, param := range entry.SupportedParameters {
+		if param == "reasoning" || param == "include_reasoning" {
+			caps.SupportsExtendedThinking = true
+		}
+	}
+
+	return caps
+}
+
+// OpenAI-compatible /models response types
+type openAIModelList struct {
+	Data []openAIModelEntry `json:"data"`
+}
+
+type openAIModelEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+
+	// Context length as reported by OpenRouter, vLLM and some gateways
+	ContextLength int `json:"context_length,omitempty"`
+	MaxModelLen   int `json:"max_model_len,omitempty"`
+	ContextWindow int `json:"context_window,omitempty"`
+
+	Architecture struct {
+		Modality        string   `json:"modality,omitempty"`
+		InputModalities []string `json:"input_modalities,omitempty"`
+	} `json:"architecture"`
+
+	TopProvider struct {
+		MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+	} `json:"top_provider"`
+
+	SupportedParameters []string `json:"supported_parameters,omitempty"`
+}
@@ -0,0 +1,104 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestOpenAICompatProvider_RefreshModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("missing bearer token")
+		}
+		w.Write([]byte(`{"data": [
+			{"id": "curated-model", "context_length": 999},
+			{"id": "alias-name"},
+			{"id": "vendor/new-model", "name": "New Model", "context_length": 64000,
+			 "architecture": {"input_modalities": ["text", "image"]},
+			 "top_provider": {"max_completion_tokens": 16000},
+			 "supported_parameters": ["temperature", "reasoning"]},
+			{"id": "local-model", "max_model_len": 32768}
+		]}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderOpenRouter, "test-key", server.URL, []types.ModelCapabilities{
+		{ModelName: "curated-model", ContextWindow: 128000, IntelligenceScore: 90, Aliases: []string{"alias-name"}},
+	}, time.Minute)
+
+	added, err := p.RefreshModels(context.Background())
+	if err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("expected 2 models added, got %d", added)
+	}
+
+	// Curated entries keep priority
+	curated, err := p.GetCapabilities("curated-model")
+	if err != nil {
+		t.Fatalf("curated model missing: %v", err)
+	}
+	if curated.ContextWindow != 128000 || curated.Discovered {
+		t.Errorf("curated model was overwritten: %+v", curated)
+	}
+
+	discovered, err := p.GetCapabilities("vendor/new-model")
+	if err != nil {
+		t.Fatalf("discovered model missing: %v", err)
+	}
+	if !discovered.Discovered {
+		t.Error("expected model to be marked as discovered")
+	}
+	if discovered.FriendlyName != "New Model" || discovered.ContextWindow != 64000 || discovered.MaxOutputTokens != 16000 {
+		t.Errorf("unexpected inferred limits: %+v", discovered)
+	}
+	if !discovered.SupportsVision || !discovered.SupportsExtendedThinking {
+		t.Errorf("expected vision and thinking to be inferred: %+v", discovered)
+	}
+	if discovered.Provider != types.ProviderOpenRouter {
+		t.Errorf("expected provider openrouter, got %s", discovered.Provider)
+	}
+
+	local, err := p.GetCapabilities("local-model")
+	if err != nil {
+		t.Fatalf("local model missing: %v", err)
+	}
+	if local.ContextWindow != 32768 || local.IntelligenceScore != discoveredIntelligenceScore {
+		t.Errorf("unexpected defaults for local model: %+v", local)
+	}
+
+	// A second refresh should not add anything new
+	added, err = p.RefreshModels(context.Background())
+	if err != nil {
+		t.Fatalf("second refresh failed: %v", err)
+	}
+	if added != 0 {
+		t.Errorf("expected no new models on second refresh, got %d", added)
+	}
+}
+
+func TestOpenAICompatProvider_RefreshModelsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "bad key"}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderXAI, "bad", server.URL, nil, time.Minute)
+
+	if _, err := p.RefreshModels(context.Background()); err == nil {
+		t.Fatal("expected error for unauthorized response")
+	}
+	if len(p.ListModels()) != 0 {
+		t.Error("expected no models after failed discovery")
+	}
+}
@@ -0,0 +1,238 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Inputs per embedding request. Vertex AI's gemini-embedding-001 accepts a
+// single instance per request.
+const (
+	openAIEmbedBatch = 2048
+	geminiEmbedBatch = 100
+	vertexEmbedBatch = 1
+)
+
+// Embedder is implemented by providers that can turn text into vectors
+type Embedder interface {
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, texts []string, model string) (*EmbedResponse, error)
+}
+
+// EmbedResponse holds the vectors for an Embed call
+type EmbedResponse struct {
+	Model      string
+	Provider   types.ProviderType
+	Embeddings [][]float32
+	Dimensions int
+	TokensUsed types.TokenUsage
+	CostUSD    float64
+}
+
+// checkEmbedding rejects models blocked by policy and catalog models that
+// aren't embedding models. Unknown models go to the API, which reports the
+// error.
+func (p *BaseProvider) checkEmbedding(modelName string) error {
+	if err := p.CheckAllowed(modelName); err != nil {
+		return err
+	}
+	if caps, err := p.GetCapabilities(modelName); err == nil && !caps.Embedding {
+		return ErrModelKind{Model: modelName, Provider: p.providerType}
+	}
+	return nil
+}
+
+// finish fills in the vector size and total token count
+func (r *EmbedResponse) finish() *EmbedResponse {
+	if len(r.Embeddings) > 0 {
+		r.Dimensions = len(r.Embeddings[0])
+	}
+	r.TokensUsed.TotalTokens = r.TokensUsed.PromptTokens
+	return r
+}
+
+// postEmbedding sends one embedding request and returns the response body
+func postEmbedding(ctx context.Context, client *http.Client, pt types.ProviderType, url string, body any, authorize func(*http.Request) error) ([]byte, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if err := authorize(httpReq); err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrAPIError{Provider: pt, StatusCode: resp.StatusCode, Message: string(respBody)}
+	}
+	return respBody, nil
+}
+
+// Embed calls an OpenAI-compatible /embeddings endpoint. Ollama, vLLM and
+// other local servers expose the same endpoint under /v1.
+func (p *OpenAICompatProvider) Embed(ctx context.Context, texts []string, model string) (*EmbedResponse, error) {
+	modelName := p.ResolveModelName(model)
+	if err := p.checkEmbedding(modelName); err != nil {
+		return nil, err
+	}
+
+	out := &EmbedResponse{Model: modelName, Provider: p.providerType}
+	for batch := range slices.Chunk(texts, openAIEmbedBatch) {
+		body := map[string]any{
+			"model": modelName,
+			"input": batch,
+		}
+
+		respBody, err := postEmbedding(ctx, p.httpClient, p.providerType, p.baseURL+"/embeddings", body, func(r *http.Request) error {
+			p.setAuth(r)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var resp openAIEmbeddingResponse
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return nil, fmt.Errorf("parsing response: %w", err)
+		}
+
+		// Entries carry their input index and may arrive in any order
+		vectors := make([][]float32, len(batch))
+		for _, d := range resp.Data {
+			if d.Index >= 0 && d.Index < len(vectors) {
+				vectors[d.Index] = d.Embedding
+			}
+		}
+		if slices.ContainsFunc(vectors, func(v []float32) bool { return v == nil }) {
+			return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(resp.Data), len(batch))
+		}
+
+		out.Embeddings = append(out.Embeddings, vectors...)
+		out.TokensUsed.PromptTokens += resp.Usage.PromptTokens
+	}
+
+	return out.finish(), nil
+}
+
+// Embed calls Gemini's batchEmbedContents, or the :predict endpoint in
+// Vertex AI mode. The Gemini API doesn't report usage, so input tokens are
+// estimated.
+func (p *GeminiProvider) Embed(ctx context.Context, texts []string, model string) (*EmbedResponse, error) {
+	modelName := p.ResolveModelName(model)
+	if err := p.checkEmbedding(modelName); err != nil {
+		return nil, err
+	}
+
+	authorize := func(r *http.Request) error { return p.authorize(ctx, r) }
+	out := &EmbedResponse{Model: modelName, Provider: types.ProviderGemini}
+
+	if p.tokens != nil {
+		for batch := range slices.Chunk(texts, vertexEmbedBatch) {
+			instances := make([]map[string]any, len(batch))
+			for i, text := range batch {
+				instances[i] = map[string]any{"content": text}
+			}
+
+			respBody, err := postEmbedding(ctx, p.httpClient, types.ProviderGemini, p.endpoint(modelName, "predict"), map[string]any{"instances": instances}, authorize)
+			if err != nil {
+				return nil, err
+			}
+
+			var resp vertexEmbeddingResponse
+			if err := json.Unmarshal(respBody, &resp); err != nil {
+				return nil, fmt.Errorf("parsing response: %w", err)
+			}
+			if len(resp.Predictions) != len(batch) {
+				return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(resp.Predictions), len(batch))
+			}
+
+			for _, pred := range resp.Predictions {
+				out.Embeddings = append(out.Embeddings, pred.Embeddings.Values)
+				out.TokensUsed.PromptTokens += pred.Embeddings.Statistics.TokenCount
+			}
+		}
+		return out.finish(), nil
+	}
+
+	for batch := range slices.Chunk(texts, geminiEmbedBatch) {
+		requests := make([]map[string]any, len(batch))
+		for i, text := range batch {
+			requests[i] = map[string]any{
+				"model":   "models/" + modelName,
+				"content": map[string]any{"parts": []map[string]any{{"text": text}}},
+			}
+			out.TokensUsed.PromptTokens += tokenizer.Estimate(text)
+		}
+
+		respBody, err := postEmbedding(ctx, p.httpClient, types.ProviderGemini, p.endpoint(modelName, "batchEmbedContents"), map[string]any{"requests": requests}, authorize)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp geminiEmbeddingResponse
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return nil, fmt.Errorf("parsing response: %w", err)
+		}
+		if len(resp.Embeddings) != len(batch) {
+			return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(resp.Embeddings), len(batch))
+		}
+
+		for _, e := range resp.Embeddings {
+			out.Embeddings = append(out.Embeddings, e.Values)
+		}
+	}
+
+	return out.finish(), nil
+}
+
+// Embedding response types
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+type geminiEmbeddingResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+type vertexEmbeddingResponse struct {
+	Predictions []struct {
+		Embeddings struct {
+			Values     []float32 `json:"values"`
+			Statistics struct {
+				TokenCount int `json:"token_count"`
+			} `json:"statistics"`
+		} `json:"embeddings"`
+	} `json:"predictions"`
+}
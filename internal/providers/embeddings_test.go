@@ -0,0 +1,190 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+var embedModels = []types.ModelCapabilities{
+	{Provider: types.ProviderCustom, ModelName: "chat", IntelligenceScore: 60},
+	{Provider: types.ProviderCustom, ModelName: "nomic-embed-text", IntelligenceScore: 40, Aliases: []string{"embed"}, Embedding: true, EmbeddingDimensions: 3, InputPricePerMTok: 1},
+}
+
+func TestOpenAICompatProvider_Embed(t *testing.T) {
+	var got struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		// Out of order, as the API allows
+		fmt.Fprint(w, `{"data":[{"index":1,"embedding":[0,1,0]},{"index":0,"embedding":[1,0,0]}],"usage":{"prompt_tokens":7,"total_tokens":7}}`)
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderCustom, "test-key", server.URL, embedModels, time.Minute)
+
+	resp, err := p.Embed(context.Background(), []string{"first", "second"}, "embed")
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if path != "/embeddings" || auth != "Bearer test-key" || got.Model != "nomic-embed-text" || len(got.Input) != 2 {
+		t.Errorf("unexpected request %s %q %+v", path, auth, got)
+	}
+	if len(resp.Embeddings) != 2 || resp.Embeddings[0][0] != 1 || resp.Embeddings[1][1] != 1 {
+		t.Errorf("vectors should follow input order, got %v", resp.Embeddings)
+	}
+	if resp.Dimensions != 3 || resp.TokensUsed.TotalTokens != 7 {
+		t.Errorf("got %d dimensions and %+v", resp.Dimensions, resp.TokensUsed)
+	}
+
+	var kind ErrModelKind
+	if _, err := p.Embed(context.Background(), []string{"x"}, "chat"); !errors.As(err, &kind) || kind.Embedding {
+		t.Errorf("expected a text model to be rejected, got %v", err)
+	}
+}
+
+func TestGeminiProvider_Embed(t *testing.T) {
+	var path string
+	var got struct {
+		Requests []struct {
+			Model   string `json:"model"`
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"requests"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"embeddings":[{"values":[0.5,0.5]},{"values":[0.25,0.75]}]}`)
+	}))
+	defer server.Close()
+
+	p, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "key"})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.baseURL = server.URL
+
+	resp, err := p.Embed(context.Background(), []string{"alpha", "beta"}, "gemini-embedding-001")
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if path != "/models/gemini-embedding-001:batchEmbedContents" {
+		t.Errorf("unexpected path %q", path)
+	}
+	if len(got.Requests) != 2 || got.Requests[0].Model != "models/gemini-embedding-001" || got.Requests[1].Content.Parts[0].Text != "beta" {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if len(resp.Embeddings) != 2 || resp.Embeddings[1][1] != 0.75 || resp.Dimensions != 2 || resp.TokensUsed.PromptTokens == 0 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestGeminiProvider_EmbedVertex(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var body struct {
+			Instances []map[string]string `json:"instances"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Instances) != 1 || r.Header.Get("Authorization") != "Bearer vertex-token" {
+			t.Errorf("unexpected request %v %v", body, r.Header)
+		}
+		fmt.Fprint(w, `{"predictions":[{"embeddings":{"values":[1,2],"statistics":{"token_count":4}}}]}`)
+	}))
+	defer server.Close()
+
+	p, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "key"})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.tokens = &googleTokenSource{token: "vertex-token", expiry: time.Now().Add(time.Hour), httpClient: http.DefaultClient}
+	p.baseURL = server.URL
+
+	resp, err := p.Embed(context.Background(), []string{"alpha", "beta"}, "gemini-embedding-001")
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "/models/gemini-embedding-001:predict" {
+		t.Errorf("expected one :predict call per text, got %v", paths)
+	}
+	if len(resp.Embeddings) != 2 || resp.TokensUsed.TotalTokens != 8 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestRegistry_SelectEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1,0,0]}],"usage":{"prompt_tokens":1000000}}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		GeminiAPIKey:   "key",
+		CustomAPIURL:   server.URL,
+		CostLedgerFile: filepath.Join(t.TempDir(), "costs.jsonl"),
+		ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+			types.ProviderGemini: {{Provider: types.ProviderGemini, ModelName: "gemini-2.5-flash", IntelligenceScore: 60}},
+			types.ProviderCustom: embedModels,
+		},
+	}
+	r := NewRegistry(cfg)
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	// Gemini comes first but has no embedding model here
+	e, caps, err := r.SelectEmbedder("")
+	if err != nil {
+		t.Fatalf("SelectEmbedder failed: %v", err)
+	}
+	if caps.ModelName != "nomic-embed-text" || caps.EmbeddingDimensions != 3 {
+		t.Errorf("unexpected embedding model %+v", caps)
+	}
+
+	resp, err := e.Embed(context.Background(), []string{"hello"}, caps.ModelName)
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if resp.CostUSD != 1 || r.Costs().Today().Models["nomic-embed-text"] != 1 {
+		t.Errorf("expected the call to be priced and recorded, got $%v", resp.CostUSD)
+	}
+	if h := r.ProviderHealth(types.ProviderCustom); h.Calls != 1 {
+		t.Errorf("expected the call to count toward provider health, got %+v", h)
+	}
+
+	var kind ErrModelKind
+	if _, _, err := r.SelectEmbedder("chat"); !errors.As(err, &kind) {
+		t.Errorf("expected ErrModelKind for a text model, got %v", err)
+	}
+	p, _ := r.GetProvider(types.ProviderCustom)
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "embed"}); !errors.As(err, &kind) || !kind.Embedding {
+		t.Errorf("expected embedding models to refuse generation, got %v", err)
+	}
+	if best, _, err := r.SelectBestModel(ModelRequirements{}); err != nil || best.Embedding {
+		t.Errorf("auto-selection should skip embedding models, got %+v, %v", best, err)
+	}
+
+	cfg.EmbeddingModel = "gemini-2.5-flash"
+	if _, _, err := r.SelectEmbedder(""); err == nil || !strings.Contains(err.Error(), "not an embedding model") {
+		t.Errorf("expected EMBEDDING_MODEL to be honored, got %v", err)
+	}
+}
@@ -1,11 +1,13 @@
 package providers
 
 import (
-	    "fmt"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/types"
-	)
-	// ErrModelNotFound indicates a model wasn't found
+	"fmt"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// ErrModelNotFound indicates a model wasn't found
 type ErrModelNotFound struct {
 	Model    string
 	Provider types.ProviderType
@@ -27,6 +29,51 @@ func (e ErrProviderNotConfigured) Error() string {
 	return fmt.Sprintf("provider %s not configured", e.Provider)
 }
 
+// ErrVisionNotSupported indicates images were sent to a model without vision support
+type ErrVisionNotSupported struct {
+	Model    string
+	Provider types.ProviderType
+}
+
+func (e ErrVisionNotSupported) Error() string {
+	return fmt.Sprintf("model %q (%s) does not support image input; choose a vision-capable model or use 'auto'", e.Model, e.Provider)
+}
+
+// ErrModelRestricted indicates a model is blocked by the provider's model policy
+type ErrModelRestricted struct {
+	Model    string
+	Provider types.ProviderType
+	Reason   string
+}
+
+func (e ErrModelRestricted) Error() string {
+	return fmt.Sprintf("model %q is restricted for provider %s: %s", e.Model, e.Provider, e.Reason)
+}
+
+// ErrModelKind indicates an embedding model was asked to generate text, or
+// a text model to embed
+type ErrModelKind struct {
+	Model     string
+	Provider  types.ProviderType
+	Embedding bool // the model is an embedding model
+}
+
+func (e ErrModelKind) Error() string {
+	if e.Embedding {
+		return fmt.Sprintf("model %q (%s) is an embedding model and cannot generate text", e.Model, e.Provider)
+	}
+	return fmt.Sprintf("model %q (%s) is not an embedding model", e.Model, e.Provider)
+}
+
+// ErrEmbeddingsNotSupported indicates a provider has no embeddings API
+type ErrEmbeddingsNotSupported struct {
+	Provider types.ProviderType
+}
+
+func (e ErrEmbeddingsNotSupported) Error() string {
+	return fmt.Sprintf("provider %s does not support embeddings", e.Provider)
+}
+
 // ErrAPIError indicates an API error
 type ErrAPIError struct {
 	Provider   types.ProviderType
@@ -37,3 +84,32 @@ type ErrAPIError struct {
 func (e ErrAPIError) Error() string {
 	return fmt.Sprintf("%s API error (%d): %s", e.Provider, e.StatusCode, e.Message)
 }
+
+// ErrContentBlocked indicates the provider's safety filters blocked the
+// prompt or stopped the response
+type ErrContentBlocked struct {
+	Provider   types.ProviderType
+	Model      string
+	Stage      string   // prompt or response
+	Reason     string   // e.g. SAFETY, RECITATION, PROHIBITED_CONTENT
+	Categories []string // harm categories that triggered the block, when reported
+}
+
+func (e ErrContentBlocked) Error() string {
+	msg := fmt.Sprintf("%s blocked the %s for model %q: %s", e.Provider, e.Stage, e.Model, e.Reason)
+	if len(e.Categories) > 0 {
+		msg += " (" + strings.Join(e.Categories, ", ") + ")"
+	}
+	return msg
+}
+
+// ErrBudgetExceeded indicates a spending limit has been reached
+type ErrBudgetExceeded struct {
+	Scope    string // daily, daily <tool> or thread <id>
+	LimitUSD float64
+	SpentUSD float64
+}
+
+func (e ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf("%s budget of $%.2f reached ($%.4f spent)", e.Scope, e.LimitUSD, e.SpentUSD)
+}
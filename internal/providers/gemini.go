@@ -3,32 +3,67 @@ package providers
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
 )
-	const (
+
+const (
 	geminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+	// countTokensTimeout bounds a countTokens call so budgeting can't stall a
+	// request for long; the estimate is used instead
+	countTokensTimeout = 3 * time.Second
+
+	// maxCachedCounts bounds the countTokens cache; it is cleared when full
+	maxCachedCounts = 4096
 )
 
-// GeminiProvider implements Provider for Google Gemini
+// GeminiProvider implements Provider for Google Gemini, through the Gemini
+// API with an API key or through Vertex AI with OAuth2 credentials
 type GeminiProvider struct {
 	*BaseProvider
-	apiKey     string
-	baseURL    string
-	httpClient *http.Client
+	apiKey         string
+	tokens         *googleTokenSource // nil when using the Gemini API key
+	baseURL        string
+	httpClient     *http.Client
+	countTokensAPI bool
+	counts         tokenCountCache
+
+	safetySettings  []map[string]any
+	includeThoughts bool
 }
 
 // NewGeminiProvider creates a new Gemini provider
 func NewGeminiProvider(cfg *config.Config) (*GeminiProvider, error) {
-	if cfg.GeminiAPIKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY not configured")
+	var tokens *googleTokenSource
+	baseURL := geminiBaseURL
+	if cfg.VertexProject != "" {
+		ts, err := newGoogleTokenSource(cfg.VertexCredentials)
+		if err != nil {
+			return nil, err
+		}
+		tokens = ts
+		baseURL = vertexBaseURL(cfg.VertexProject, cfg.VertexLocation)
+	} else if cfg.GeminiAPIKey == "" {
+		return nil, fmt.Errorf("GEMINI_API_KEY or VERTEX_PROJECT not configured")
+	}
+
+	safety, err := parseGeminiSafetySettings(cfg.GeminiSafetySettings)
+	if err != nil {
+		return nil, err
 	}
 
 	models := cfg.ModelRegistries[types.ProviderGemini]
@@ -39,26 +74,162 @@ func NewGeminiProvider(cfg *config.Config) (*GeminiProvider, error) {
 	return &GeminiProvider{
 		BaseProvider: NewBaseProvider(types.ProviderGemini, models),
 		apiKey:       cfg.GeminiAPIKey,
-		baseURL:      geminiBaseURL,
+		tokens:       tokens,
+		baseURL:      baseURL,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Minute,
 		},
+		countTokensAPI:  cfg.GeminiCountTokensAPI,
+		safetySettings:  safety,
+		includeThoughts: cfg.GeminiIncludeThoughts,
 	}, nil
 }
 
+// vertexBaseURL returns the Vertex AI prefix for Google's publisher models;
+// the Gemini API's /models/{model}:method paths are appended to it as is
+func vertexBaseURL(project, location string) string {
+	if location == "" {
+		location = "us-central1"
+	}
+	host := location + "-aiplatform.googleapis.com"
+	if location == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	return fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google",
+		host, url.PathEscape(project), url.PathEscape(location))
+}
+
 func (p *GeminiProvider) IsConfigured() bool {
-	return p.apiKey != ""
+	return p.apiKey != "" || p.tokens != nil
+}
+
+// endpoint returns the URL of a model method such as generateContent
+func (p *GeminiProvider) endpoint(modelName, method string) string {
+	return fmt.Sprintf("%s/models/%s:%s", p.baseURL, url.PathEscape(modelName), method)
+}
+
+// authorize sends the API key in the x-goog-api-key header, keeping it out of
+// URLs that end up in errors and logs, or a Vertex AI bearer token
+func (p *GeminiProvider) authorize(ctx context.Context, httpReq *http.Request) error {
+	if p.tokens == nil {
+		httpReq.Header.Set("x-goog-api-key", p.apiKey)
+		return nil
+	}
+
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// CountTokens asks the countTokens endpoint when enabled, within the caller's
+// deadline and at most countTokensTimeout. Counts are cached by text, since
+// budgeting code counts the same files and turns repeatedly.
+func (p *GeminiProvider) CountTokens(ctx context.Context, text string, modelName string) (int, error) {
+	if !p.countTokensAPI {
+		return tokenizer.Estimate(text), nil
+	}
+
+	modelName = p.ResolveModelName(modelName)
+	key := sha256.Sum256([]byte(modelName + "\x00" + text))
+	if n, ok := p.counts.get(key); ok {
+		return n, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, countTokensTimeout)
+	defer cancel()
+
+	count, err := p.countTokensRemote(ctx, text, modelName)
+	if err != nil {
+		slog.Warn("countTokens request failed, using estimate", "model", modelName, "error", err)
+		return tokenizer.Estimate(text), nil
+	}
+	p.counts.put(key, count)
+	return count, nil
+}
+
+// tokenCountCache remembers countTokens results by model and text hash
+type tokenCountCache struct {
+	mu     sync.Mutex
+	counts map[[sha256.Size]byte]int
 }
 
-func (p *GeminiProvider) CountTokens(text string, modelName string) (int, error) {
-	// Rough estimate: 4 chars per token
-	return len(text) / 4, nil
+func (c *tokenCountCache) get(key [sha256.Size]byte) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.counts[key]
+	return n, ok
+}
+
+func (c *tokenCountCache) put(key [sha256.Size]byte, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil || len(c.counts) >= maxCachedCounts {
+		c.counts = make(map[[sha256.Size]byte]int)
+	}
+	c.counts[key] = n
+}
+
+// countTokensRemote asks the :countTokens endpoint for an exact count
+func (p *GeminiProvider) countTokensRemote(ctx context.Context, text, modelName string) (int, error) {
+	body := map[string]any{
+		"contents": []map[string]any{
+			{"role": "user", "parts": []map[string]any{{"text": text}}},
+		},
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(modelName, "countTokens"), bytes.NewReader(jsonBody))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if err := p.authorize(ctx, httpReq); err != nil {
+		return 0, err
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, ErrAPIError{Provider: types.ProviderGemini, StatusCode: resp.StatusCode, Message: string(respBody)}
+	}
+
+	var countResp struct {
+		TotalTokens int `json:"totalTokens"`
+	}
+	if err := json.Unmarshal(respBody, &countResp); err != nil {
+		return 0, fmt.Errorf("parsing response: %w", err)
+	}
+
+	return countResp.TotalTokens, nil
 }
 
 // GenerateContent calls the Gemini API
 func (p *GeminiProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*types.ModelResponse, error) {
 	modelName := p.ResolveModelName(req.Model)
 
+	if err := p.CheckAllowed(modelName); err != nil {
+		return nil, err
+	}
+	if err := p.CheckVision(modelName, req); err != nil {
+		return nil, err
+	}
+
 	// Build request body
 	body := map[string]any{
 		"contents": p.buildContents(req),
@@ -72,19 +243,56 @@ func (p *GeminiProvider) GenerateContent(ctx context.Context, req *GenerateReque
 	if req.MaxOutputTokens > 0 {
 		genConfig["maxOutputTokens"] = req.MaxOutputTokens
 	}
+	sampling := req.Sampling
+	if sampling.TopP != nil {
+		genConfig["topP"] = *sampling.TopP
+	}
+	if sampling.Seed != nil {
+		genConfig["seed"] = *sampling.Seed
+	}
+	if len(sampling.Stop) > 0 {
+		genConfig["stopSequences"] = sampling.Stop
+	}
+	if sampling.PresencePenalty != nil {
+		genConfig["presencePenalty"] = *sampling.PresencePenalty
+	}
+	if sampling.FrequencyPenalty != nil {
+		genConfig["frequencyPenalty"] = *sampling.FrequencyPenalty
+	}
+	if sampling.N > 1 {
+		genConfig["candidateCount"] = sampling.N
+	}
 
 	// Add thinking config for supported models
 	caps, _ := p.GetCapabilities(modelName)
-	if caps != nil && caps.SupportsExtendedThinking && req.ThinkingMode != "" {
-		genConfig["thinkingConfig"] = map[string]any{
-			"thinkingBudget": p.getThinkingBudget(req.ThinkingMode, req.ThinkingBudget),
+	if caps != nil && caps.SupportsExtendedThinking {
+		thinking := map[string]any{}
+		if req.ThinkingMode != "" {
+			thinking["thinkingBudget"] = p.getThinkingBudget(req.ThinkingMode, req.ThinkingBudget)
 		}
+		if p.includeThoughts {
+			thinking["includeThoughts"] = true
+		}
+		if len(thinking) > 0 {
+			genConfig["thinkingConfig"] = thinking
+		}
+	}
+
+	if req.ResponseSchema != nil {
+		genConfig["responseMimeType"] = "application/json"
+		genConfig["responseSchema"] = geminiSchema(req.ResponseSchema.Schema)
 	}
 
 	if len(genConfig) > 0 {
 		body["generationConfig"] = genConfig
 	}
 
+	applyGeminiTools(body, req)
+
+	if len(p.safetySettings) > 0 {
+		body["safetySettings"] = p.safetySettings
+	}
+
 	// Add system instruction
 	if req.SystemPrompt != "" {
 		body["systemInstruction"] = map[string]any{
@@ -94,19 +302,22 @@ func (p *GeminiProvider) GenerateContent(ctx context.Context, req *GenerateReque
 		}
 	}
 
-	// Make request
-	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", p.baseURL, modelName, p.apiKey)
+	applyProviderOptions(body, req.ProviderOptions)
 
+	// Make request
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(modelName, "generateContent"), bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	if err := p.authorize(ctx, httpReq); err != nil {
+		return nil, err
+	}
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
@@ -120,7 +331,7 @@ func (p *GeminiProvider) GenerateContent(ctx context.Context, req *GenerateReque
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, ErrAPIError{Provider: types.ProviderGemini, StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	// Parse response
@@ -176,7 +387,7 @@ func (p *GeminiProvider) buildContents(req *GenerateRequest) []map[string]any {
 		"parts": parts,
 	})
 
-	return contents
+	return append(contents, geminiToolContents(req.ToolTurns)...)
 }
 
 func (p *GeminiProvider) getThinkingBudget(mode types.ThinkingMode, custom int) int {
@@ -184,57 +395,94 @@ func (p *GeminiProvider) getThinkingBudget(mode types.ThinkingMode, custom int)
 		return custom
 	}
 
-	switch mode {
-	case types.ThinkingMinimal:
-		return 1024
-	case types.ThinkingLow:
-		return 4096
-	case types.ThinkingMedium:
-		return 8192
-	case types.ThinkingHigh:
-		return 16384
-	case types.ThinkingMax:
-		return 32768
-	default:
-		return 8192
+	if budget := defaultThinkingBudget(mode); budget > 0 {
+		return budget
 	}
+	return 8192
 }
 
 func (p *GeminiProvider) parseResponse(model string, resp *geminiResponse) (*types.ModelResponse, error) {
+	if err := geminiBlocked(model, resp); err != nil {
+		return nil, err
+	}
 	if len(resp.Candidates) == 0 {
 		return nil, fmt.Errorf("no candidates in response")
 	}
 
 	candidate := resp.Candidates[0]
-	var content string
+	var content strings.Builder
+	var thoughts []string
 	for _, part := range candidate.Content.Parts {
-		if part.Text != "" {
-			content += part.Text
+		switch {
+		case part.Thought:
+			if part.Text != "" {
+				thoughts = append(thoughts, part.Text)
+			}
+		case part.Text != "":
+			content.WriteString(part.Text)
 		}
 	}
 
+	metadata := map[string]any{}
+	if len(thoughts) > 0 {
+		metadata["reasoning_summary"] = strings.Join(thoughts, "\n\n")
+	}
+	if ratings := notableRatings(candidate.SafetyRatings); len(ratings) > 0 {
+		metadata["safety_ratings"] = ratings
+	}
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+
+	// Gemini reports thinking separately from the candidates; completion
+	// tokens include both, as they do for OpenAI reasoning models
+	usage := resp.UsageMetadata
+
 	return &types.ModelResponse{
-		Content:      content,
+		Content:      content.String(),
 		Model:        model,
 		Provider:     types.ProviderGemini,
 		FinishReason: candidate.FinishReason,
+		ToolCalls:    geminiToolCalls(candidate.Content.Parts),
+		Alternatives: geminiAlternatives(resp.Candidates[1:]),
 		TokensUsed: types.TokenUsage{
-			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
-			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
-			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+			ThinkingTokens:   usage.ThoughtsTokenCount,
+			CachedTokens:     usage.CachedContentTokenCount,
 		},
+		Metadata: metadata,
 	}, nil
 }
 
+// geminiAlternatives returns the answer text of extra candidates, which come
+// back when more than one was requested
+func geminiAlternatives(candidates []geminiCandidate) []string {
+	var alts []string
+	for _, c := range candidates {
+		var text strings.Builder
+		for _, part := range c.Content.Parts {
+			if !part.Thought {
+				text.WriteString(part.Text)
+			}
+		}
+		alts = append(alts, text.String())
+	}
+	return alts
+}
+
 // Gemini API response types
 type geminiResponse struct {
-	Candidates    []geminiCandidate `json:"candidates"`
-	UsageMetadata geminiUsage       `json:"usageMetadata"`
+	Candidates     []geminiCandidate    `json:"candidates"`
+	PromptFeedback geminiPromptFeedback `json:"promptFeedback"`
+	UsageMetadata  geminiUsage          `json:"usageMetadata"`
 }
 
 type geminiCandidate struct {
-	Content      geminiContent `json:"content"`
-	FinishReason string        `json:"finishReason"`
+	Content       geminiContent        `json:"content"`
+	FinishReason  string               `json:"finishReason"`
+	SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
 }
 
 type geminiContent struct {
@@ -243,13 +491,24 @@ type geminiContent struct {
 }
 
 type geminiPart struct {
-	Text string `json:"text"`
+	Text             string              `json:"text"`
+	Thought          bool                `json:"thought,omitempty"`
+	FunctionCall     *geminiFunctionCall `json:"functionCall,omitempty"`
+	ThoughtSignature string              `json:"thoughtSignature,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args"`
 }
 
 type geminiUsage struct {
-	PromptTokenCount     int `json:"promptTokenCount"`
-	CandidatesTokenCount int `json:"candidatesTokenCount"`
-	TotalTokenCount      int `json:"totalTokenCount"`
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
 }
 
 func defaultGeminiModels() []types.ModelCapabilities {
@@ -268,6 +527,8 @@ func defaultGeminiModels() []types.ModelCapabilities {
 			SupportsStreaming:        true,
 			SupportsVision:           true,
 			AllowCodeGeneration:      true,
+			SupportsJSONSchema:       true,
+			SupportsFunctionCalling:  true,
 		},
 		{
 			Provider:                 types.ProviderGemini,
@@ -283,6 +544,8 @@ func defaultGeminiModels() []types.ModelCapabilities {
 			SupportsStreaming:        true,
 			SupportsVision:           true,
 			AllowCodeGeneration:      true,
+			SupportsJSONSchema:       true,
+			SupportsFunctionCalling:  true,
 		},
 		{
 			Provider:                 types.ProviderGemini,
@@ -297,6 +560,18 @@ func defaultGeminiModels() []types.ModelCapabilities {
 			SupportsStreaming:        true,
 			SupportsVision:           true,
 			AllowCodeGeneration:      true,
+			SupportsJSONSchema:       true,
+			SupportsFunctionCalling:  true,
+		},
+		{
+			Provider:            types.ProviderGemini,
+			ModelName:           "gemini-embedding-001",
+			FriendlyName:        "Gemini Embedding",
+			IntelligenceScore:   50,
+			ContextWindow:       2048,
+			Embedding:           true,
+			EmbeddingDimensions: 3072,
+			InputPricePerMTok:   0.15,
 		},
 	}
 }
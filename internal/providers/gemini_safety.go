@@ -0,0 +1,151 @@
+package providers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Harm categories a bare GEMINI_SAFETY_SETTINGS threshold applies to
+var geminiHarmCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+	"HARM_CATEGORY_CIVIC_INTEGRITY",
+}
+
+var geminiThresholds = []string{
+	"BLOCK_NONE",
+	"BLOCK_ONLY_HIGH",
+	"BLOCK_MEDIUM_AND_ABOVE",
+	"BLOCK_LOW_AND_ABOVE",
+	"OFF",
+}
+
+// Finish reasons meaning the response was withheld rather than completed
+var geminiBlockedFinishReasons = []string{
+	"SAFETY",
+	"RECITATION",
+	"BLOCKLIST",
+	"PROHIBITED_CONTENT",
+	"SPII",
+	"IMAGE_SAFETY",
+}
+
+// parseGeminiSafetySettings parses CATEGORY=THRESHOLD pairs separated by
+// commas. Categories may omit the HARM_CATEGORY_ prefix, and a bare threshold
+// applies to every category; later entries override earlier ones.
+func parseGeminiSafetySettings(spec string) ([]map[string]any, error) {
+	thresholds := map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.ToUpper(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		category, threshold, found := strings.Cut(entry, "=")
+		if !found {
+			category, threshold = "", category
+		}
+		threshold = strings.TrimSpace(threshold)
+		if !slices.Contains(geminiThresholds, threshold) {
+			return nil, fmt.Errorf("GEMINI_SAFETY_SETTINGS: unknown threshold %q (want one of %s)", threshold, strings.Join(geminiThresholds, ", "))
+		}
+
+		if category == "" {
+			for _, c := range geminiHarmCategories {
+				thresholds[c] = threshold
+			}
+			continue
+		}
+		category = strings.TrimSpace(category)
+		if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+			category = "HARM_CATEGORY_" + category
+		}
+		if !slices.Contains(geminiHarmCategories, category) {
+			return nil, fmt.Errorf("GEMINI_SAFETY_SETTINGS: unknown category %q", category)
+		}
+		thresholds[category] = threshold
+	}
+
+	var settings []map[string]any
+	for _, c := range geminiHarmCategories {
+		if threshold, ok := thresholds[c]; ok {
+			settings = append(settings, map[string]any{"category": c, "threshold": threshold})
+		}
+	}
+	return settings, nil
+}
+
+// geminiBlocked returns ErrContentBlocked when the prompt was blocked or the
+// candidate stopped for a safety or recitation reason
+func geminiBlocked(model string, resp *geminiResponse) error {
+	if reason := resp.PromptFeedback.BlockReason; reason != "" {
+		return ErrContentBlocked{
+			Provider:   types.ProviderGemini,
+			Model:      model,
+			Stage:      "prompt",
+			Reason:     reason,
+			Categories: blockedCategories(resp.PromptFeedback.SafetyRatings),
+		}
+	}
+
+	if len(resp.Candidates) == 0 {
+		return nil
+	}
+	candidate := resp.Candidates[0]
+	if !slices.Contains(geminiBlockedFinishReasons, candidate.FinishReason) {
+		return nil
+	}
+	return ErrContentBlocked{
+		Provider:   types.ProviderGemini,
+		Model:      model,
+		Stage:      "response",
+		Reason:     candidate.FinishReason,
+		Categories: blockedCategories(candidate.SafetyRatings),
+	}
+}
+
+// blockedCategories lists the categories Gemini blocked, falling back to
+// those rated above LOW when none is marked blocked
+func blockedCategories(ratings []geminiSafetyRating) []string {
+	var blocked, flagged []string
+	for _, r := range ratings {
+		if r.Blocked {
+			blocked = append(blocked, r.Category)
+		}
+		if r.Probability == "MEDIUM" || r.Probability == "HIGH" {
+			flagged = append(flagged, r.Category)
+		}
+	}
+	if len(blocked) > 0 {
+		return blocked
+	}
+	return flagged
+}
+
+// notableRatings drops NEGLIGIBLE ratings so metadata only carries the
+// categories that came close to a block
+func notableRatings(ratings []geminiSafetyRating) []geminiSafetyRating {
+	var out []geminiSafetyRating
+	for _, r := range ratings {
+		if r.Blocked || (r.Probability != "" && r.Probability != "NEGLIGIBLE") {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+type geminiPromptFeedback struct {
+	BlockReason   string               `json:"blockReason"`
+	SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+}
+
+type geminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
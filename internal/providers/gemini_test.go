@@ -0,0 +1,248 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// newGeminiServer answers every request with body
+func newGeminiServer(t *testing.T, cfg *config.Config, body string) *GeminiProvider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg.GeminiAPIKey = "key"
+	p, err := NewGeminiProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.baseURL = server.URL
+	return p
+}
+
+func TestGeminiProvider_APIKeyHeader(t *testing.T) {
+	var query, header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, header = r.URL.RawQuery, r.Header.Get("x-goog-api-key")
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"ok"}]},"finishReason":"STOP"}]}`))
+	}))
+	defer server.Close()
+
+	p, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "gemini-secret", GeminiCountTokensAPI: true})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.baseURL = server.URL
+
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "flash"}); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if query != "" || header != "gemini-secret" {
+		t.Errorf("expected the key in the header only, got query %q and header %q", query, header)
+	}
+
+	query, header = "x", ""
+	p.countTokensRemote(context.Background(), "hi", "gemini-2.5-flash")
+	if query != "" || header != "gemini-secret" {
+		t.Errorf("countTokens should authenticate the same way, got query %q and header %q", query, header)
+	}
+
+	// Transport errors quote the URL, which no longer carries the key
+	server.Close()
+	_, err = p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "flash"})
+	if err == nil || strings.Contains(err.Error(), "gemini-secret") {
+		t.Errorf("expected a transport error without the key, got %v", err)
+	}
+}
+
+func TestGeminiProvider_Blocked(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		wantStage      string
+		wantReason     string
+		wantCategories []string
+	}{
+		{
+			name: "prompt",
+			body: `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[
+				{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE"},
+				{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}}`,
+			wantStage:      "prompt",
+			wantReason:     "SAFETY",
+			wantCategories: []string{"HARM_CATEGORY_DANGEROUS_CONTENT"},
+		},
+		{
+			name:       "prompt without ratings",
+			body:       `{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"}}`,
+			wantStage:  "prompt",
+			wantReason: "PROHIBITED_CONTENT",
+		},
+		{
+			name: "response safety",
+			body: `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[
+				{"category":"HARM_CATEGORY_HATE_SPEECH","probability":"MEDIUM"}]}]}`,
+			wantStage:      "response",
+			wantReason:     "SAFETY",
+			wantCategories: []string{"HARM_CATEGORY_HATE_SPEECH"},
+		},
+		{
+			name:       "recitation",
+			body:       `{"candidates":[{"content":{"parts":[{"text":"Four score and"}]},"finishReason":"RECITATION"}]}`,
+			wantStage:  "response",
+			wantReason: "RECITATION",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newGeminiServer(t, &config.Config{}, tt.body)
+
+			_, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "flash"})
+			var blocked ErrContentBlocked
+			if !errors.As(err, &blocked) {
+				t.Fatalf("expected ErrContentBlocked, got %v", err)
+			}
+			if blocked.Stage != tt.wantStage || blocked.Reason != tt.wantReason || !slices.Equal(blocked.Categories, tt.wantCategories) {
+				t.Errorf("got %+v", blocked)
+			}
+			if blocked.Model != "gemini-2.5-flash" || !strings.Contains(err.Error(), tt.wantReason) {
+				t.Errorf("unexpected error %q", err)
+			}
+			if isProviderFailure(err) {
+				t.Error("a content block should not count against provider health")
+			}
+		})
+	}
+}
+
+func TestGeminiProvider_ThoughtsAndUsage(t *testing.T) {
+	p := newGeminiServer(t, &config.Config{GeminiIncludeThoughts: true}, `{
+		"candidates":[{"finishReason":"STOP",
+			"content":{"role":"model","parts":[
+				{"text":"Weighing both options.","thought":true},
+				{"text":"Pick B."}]},
+			"safetyRatings":[
+				{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE"},
+				{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"LOW"}]}],
+		"usageMetadata":{"promptTokenCount":50,"candidatesTokenCount":3,"thoughtsTokenCount":120,"totalTokenCount":173}}`)
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "A or B?", Model: "pro"})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	if resp.Content != "Pick B." {
+		t.Errorf("thought parts should not be part of the answer, got %q", resp.Content)
+	}
+	if resp.Metadata["reasoning_summary"] != "Weighing both options." {
+		t.Errorf("expected the thought summary in metadata, got %v", resp.Metadata)
+	}
+	want := types.TokenUsage{PromptTokens: 50, CompletionTokens: 123, TotalTokens: 173, ThinkingTokens: 120}
+	if resp.TokensUsed != want {
+		t.Errorf("TokensUsed = %+v, want %+v", resp.TokensUsed, want)
+	}
+	ratings, _ := resp.Metadata["safety_ratings"].([]geminiSafetyRating)
+	if len(ratings) != 1 || ratings[0].Category != "HARM_CATEGORY_DANGEROUS_CONTENT" {
+		t.Errorf("expected only the non-negligible rating, got %v", resp.Metadata["safety_ratings"])
+	}
+}
+
+func TestGeminiProvider_CountTokens(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"totalTokens": 42}`))
+	}))
+	defer server.Close()
+
+	p, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "key", GeminiCountTokensAPI: true})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.baseURL = server.URL
+
+	for i := 0; i < 2; i++ {
+		if n, _ := p.CountTokens(context.Background(), "some text", "flash"); n != 42 {
+			t.Errorf("expected the remote count, got %d", n)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the second count to be cached, got %d calls", calls)
+	}
+
+	// A cancelled caller gets the estimate without a request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n, _ := p.CountTokens(ctx, "other text", "flash"); n == 42 || calls != 1 {
+		t.Errorf("expected an estimate for a cancelled context, got %d after %d calls", n, calls)
+	}
+}
+
+func TestParseGeminiSafetySettings(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]string
+		wantErr string
+	}{
+		{spec: "", want: map[string]string{}},
+		{
+			spec: "harassment=block_none, HARM_CATEGORY_HATE_SPEECH=BLOCK_ONLY_HIGH",
+			want: map[string]string{"HARM_CATEGORY_HARASSMENT": "BLOCK_NONE", "HARM_CATEGORY_HATE_SPEECH": "BLOCK_ONLY_HIGH"},
+		},
+		{
+			spec: "BLOCK_ONLY_HIGH,DANGEROUS_CONTENT=BLOCK_NONE",
+			want: map[string]string{
+				"HARM_CATEGORY_HARASSMENT":        "BLOCK_ONLY_HIGH",
+				"HARM_CATEGORY_HATE_SPEECH":       "BLOCK_ONLY_HIGH",
+				"HARM_CATEGORY_SEXUALLY_EXPLICIT": "BLOCK_ONLY_HIGH",
+				"HARM_CATEGORY_DANGEROUS_CONTENT": "BLOCK_NONE",
+				"HARM_CATEGORY_CIVIC_INTEGRITY":   "BLOCK_ONLY_HIGH",
+			},
+		},
+		{spec: "harassment=never", wantErr: "unknown threshold"},
+		{spec: "violence=BLOCK_NONE", wantErr: "unknown category"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			settings, err := parseGeminiSafetySettings(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected %q error, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := map[string]string{}
+			for _, s := range settings {
+				got[s["category"].(string)] = s["threshold"].(string)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for c, threshold := range tt.want {
+				if got[c] != threshold {
+					t.Errorf("%s = %q, want %q", c, got[c], threshold)
+				}
+			}
+		})
+	}
+
+	if _, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "key", GeminiSafetySettings: "nope"}); err == nil {
+		t.Error("NewGeminiProvider should reject invalid safety settings")
+	}
+}
@@ -0,0 +1,224 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/redact"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// CircuitState is the state of a provider's circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // calls flow normally
+	CircuitOpen     CircuitState = "open"      // calls fail fast until the cooldown ends
+	CircuitHalfOpen CircuitState = "half-open" // one probe call decides whether to close
+)
+
+// ErrProviderUnavailable indicates a provider's circuit is open
+type ErrProviderUnavailable struct {
+	Provider   types.ProviderType
+	RetryAfter time.Duration
+	LastError  string
+}
+
+func (e ErrProviderUnavailable) Error() string {
+	return fmt.Sprintf("provider %s is unavailable after repeated failures (last error: %s); retry after %s",
+		e.Provider, e.LastError, e.RetryAfter.Round(time.Second))
+}
+
+// Retryable reports that the call may succeed once the provider recovers
+func (e ErrProviderUnavailable) Retryable() bool {
+	return true
+}
+
+// ProviderHealth is a snapshot of a provider's health
+type ProviderHealth struct {
+	Provider            types.ProviderType
+	State               CircuitState
+	ConsecutiveFailures int
+	Calls               int
+	Failures            int
+	LastLatency         time.Duration
+	AvgLatency          time.Duration // moving average of successful calls
+	LastError           string
+	LastErrorAt         time.Time
+	RetryAt             time.Time // when an open circuit allows a probe
+}
+
+// Healthy reports whether the provider is taking calls normally
+func (h ProviderHealth) Healthy() bool {
+	return h.State == CircuitClosed
+}
+
+type providerHealth struct {
+	ProviderHealth
+	probing bool // a half-open probe is in flight
+}
+
+// healthTracker runs a circuit breaker per provider. After threshold
+// consecutive failures the circuit opens and calls fail fast; once the
+// cooldown passes a single probe call is let through, and its outcome closes
+// or reopens the circuit.
+type healthTracker struct {
+	threshold int
+	cooldown  time.Duration
+	states    map[types.ProviderType]*providerHealth
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+func newHealthTracker(threshold int, cooldown time.Duration) *healthTracker {
+	return &healthTracker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		states:    make(map[types.ProviderType]*providerHealth),
+		now:       time.Now,
+	}
+}
+
+// state returns the provider's entry. Callers must hold h.mu.
+func (h *healthTracker) state(pt types.ProviderType) *providerHealth {
+	s, ok := h.states[pt]
+	if !ok {
+		s = &providerHealth{ProviderHealth: ProviderHealth{Provider: pt, State: CircuitClosed}}
+		h.states[pt] = s
+	}
+	return s
+}
+
+// allow reports whether a call may go to the provider, moving an open
+// circuit whose cooldown has passed to half-open for a probe
+func (h *healthTracker) allow(pt types.ProviderType) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.state(pt)
+	switch s.State {
+	case CircuitOpen:
+		if h.now().Before(s.RetryAt) {
+			return ErrProviderUnavailable{Provider: pt, RetryAfter: s.RetryAt.Sub(h.now()), LastError: s.LastError}
+		}
+		s.State = CircuitHalfOpen
+		s.probing = true
+	case CircuitHalfOpen:
+		if s.probing {
+			return ErrProviderUnavailable{Provider: pt, LastError: s.LastError}
+		}
+		s.probing = true
+	}
+	return nil
+}
+
+// record updates the provider's health with a call's outcome
+func (h *healthTracker) record(pt types.ProviderType, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.state(pt)
+
+	// A call the caller abandoned says nothing about the provider
+	if errors.Is(err, context.Canceled) {
+		s.probing = false
+		return
+	}
+
+	s.Calls++
+	s.LastLatency = latency
+
+	if err == nil || !isProviderFailure(err) {
+		s.ConsecutiveFailures = 0
+		s.State = CircuitClosed
+		s.probing = false
+		if err == nil {
+			if s.AvgLatency == 0 {
+				s.AvgLatency = latency
+			} else {
+				s.AvgLatency = (s.AvgLatency*4 + latency) / 5
+			}
+		}
+		return
+	}
+
+	s.Failures++
+	s.ConsecutiveFailures++
+	s.LastError = redact.String(err.Error())
+	s.LastErrorAt = h.now()
+
+	if s.State == CircuitHalfOpen || (h.threshold > 0 && s.ConsecutiveFailures >= h.threshold) {
+		s.State = CircuitOpen
+		s.RetryAt = h.now().Add(h.cooldown)
+	}
+	s.probing = false
+}
+
+// available reports whether auto-selection should consider the provider: its
+// circuit is closed, or open with the cooldown over and no probe in flight
+func (h *healthTracker) available(pt types.ProviderType) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.states[pt]
+	if !ok {
+		return true
+	}
+	switch s.State {
+	case CircuitOpen:
+		return !h.now().Before(s.RetryAt)
+	case CircuitHalfOpen:
+		return !s.probing
+	}
+	return true
+}
+
+// snapshot returns a copy of the provider's health
+func (h *healthTracker) snapshot(pt types.ProviderType) ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state(pt).ProviderHealth
+}
+
+// isProviderFailure reports whether an error says the provider is unhealthy,
+// as opposed to a bad request or a limit enforced locally
+func isProviderFailure(err error) bool {
+	var apiErr ErrAPIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode >= 500,
+			apiErr.StatusCode == http.StatusRequestTimeout,
+			apiErr.StatusCode == http.StatusTooManyRequests:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var (
+		invalid    ErrInvalidRequest
+		restricted ErrModelRestricted
+		vision     ErrVisionNotSupported
+		notFound   ErrModelNotFound
+		budget     ErrBudgetExceeded
+		limited    ErrRateLimited
+		blocked    ErrContentBlocked
+	)
+	switch {
+	case errors.As(err, &invalid),
+		errors.As(err, &restricted),
+		errors.As(err, &vision),
+		errors.As(err, &notFound),
+		errors.As(err, &budget),
+		errors.As(err, &limited),
+		errors.As(err, &blocked):
+		return false
+	}
+
+	// Transport errors, timeouts and malformed responses
+	return true
+}
@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/redact"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestHealthTracker_Circuit(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := newHealthTracker(3, 30*time.Second)
+	h.now = clock.now
+
+	pt := types.ProviderOpenAI
+	outage := ErrAPIError{Provider: pt, StatusCode: 503, Message: "overloaded"}
+
+	for i := 0; i < 3; i++ {
+		if err := h.allow(pt); err != nil {
+			t.Fatalf("call %d should be allowed: %v", i+1, err)
+		}
+		h.record(pt, time.Second, outage)
+	}
+
+	if h.snapshot(pt).State != CircuitOpen || h.available(pt) {
+		t.Fatalf("expected open circuit after 3 failures, got %+v", h.snapshot(pt))
+	}
+	var unavailable ErrProviderUnavailable
+	if err := h.allow(pt); !errors.As(err, &unavailable) || unavailable.RetryAfter != 30*time.Second {
+		t.Fatalf("expected fail-fast with 30s retry, got %v", err)
+	}
+
+	// After the cooldown one probe goes through; a failed probe reopens
+	clock.advance(30 * time.Second)
+	if err := h.allow(pt); err != nil {
+		t.Fatalf("expected probe to be allowed: %v", err)
+	}
+	if err := h.allow(pt); err == nil {
+		t.Fatal("expected a second call during the probe to fail fast")
+	}
+	h.record(pt, time.Second, outage)
+	if h.snapshot(pt).State != CircuitOpen {
+		t.Fatalf("expected failed probe to reopen the circuit, got %s", h.snapshot(pt).State)
+	}
+
+	// A successful probe closes it
+	clock.advance(30 * time.Second)
+	h.allow(pt)
+	h.record(pt, 200*time.Millisecond, nil)
+	snap := h.snapshot(pt)
+	if snap.State != CircuitClosed || snap.ConsecutiveFailures != 0 || snap.Failures != 4 {
+		t.Errorf("expected closed circuit after a good probe, got %+v", snap)
+	}
+}
+
+func TestIsProviderFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{ErrAPIError{StatusCode: 500}, true},
+		{ErrAPIError{StatusCode: 429}, true},
+		{ErrAPIError{StatusCode: 400}, false},
+		{ErrAPIError{StatusCode: 401}, false},
+		{fmt.Errorf("making request: %w", context.DeadlineExceeded), true},
+		{errors.New("connection refused"), true},
+		{ErrInvalidRequest{}, false},
+		{ErrRateLimited{}, false},
+		{ErrBudgetExceeded{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := isProviderFailure(tt.err); got != tt.want {
+			t.Errorf("isProviderFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_SkipsUnhealthyProviders(t *testing.T) {
+	var primaryCalls int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	var fallbackCalls int
+	fallback := newPricedServer(t, "ok", &fallbackCalls)
+
+	r := NewRegistry(&config.Config{CircuitFailureThreshold: 2, CircuitCooldown: time.Minute})
+	r.providers[types.ProviderCustom] = &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderCustom, "key", primary.URL, []types.ModelCapabilities{
+			{Provider: types.ProviderCustom, ModelName: "primary", IntelligenceScore: 90},
+		}, 0),
+		registry: r,
+	}
+	r.providers[types.ProviderOpenRouter] = &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderOpenRouter, "key", fallback.URL, []types.ModelCapabilities{
+			{Provider: types.ProviderOpenRouter, ModelName: "fallback", IntelligenceScore: 50},
+		}, 0),
+		registry: r,
+	}
+
+	call := func() (*types.ModelCapabilities, error) {
+		caps, p, err := r.SelectBestModel(ModelRequirements{})
+		if err != nil {
+			t.Fatalf("SelectBestModel failed: %v", err)
+		}
+		_, err = p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: caps.ModelName})
+		return caps, err
+	}
+
+	for i := 0; i < 2; i++ {
+		if caps, err := call(); caps.ModelName != "primary" || err == nil {
+			t.Fatalf("expected failing call to primary, got %s, %v", caps.ModelName, err)
+		}
+	}
+
+	caps, err := call()
+	if caps.ModelName != "fallback" || err != nil {
+		t.Errorf("expected selection to skip the open circuit, got %s, %v", caps.ModelName, err)
+	}
+	if primaryCalls != 2 {
+		t.Errorf("expected no further calls to the failing provider, got %d", primaryCalls)
+	}
+
+	health := r.ProviderHealth(types.ProviderCustom)
+	if health.Healthy() || health.LastError == "" {
+		t.Errorf("expected unhealthy primary with last error, got %+v", health)
+	}
+}
+
+func TestManagedProvider_RedactsErrors(t *testing.T) {
+	const key = "live-secret-key-123"
+	redact.SetSecrets(key)
+	t.Cleanup(func() { redact.SetSecrets() })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, fmt.Sprintf(`{"error":"upstream rejected %s"}`, r.Header.Get("Authorization")), http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	r := NewRegistry(&config.Config{CircuitFailureThreshold: 5})
+	p := &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderCustom, key, server.URL, []types.ModelCapabilities{
+			{Provider: types.ProviderCustom, ModelName: "local"},
+		}, 0),
+		registry: r,
+	}
+	r.providers[types.ProviderCustom] = p
+
+	_, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "local"})
+	if err == nil || strings.Contains(err.Error(), key) || !strings.Contains(err.Error(), redact.Mask) {
+		t.Fatalf("expected a redacted error, got %v", err)
+	}
+	var apiErr ErrAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("redaction should keep the API error reachable, got %v", err)
+	}
+	if last := r.ProviderHealth(types.ProviderCustom).LastError; strings.Contains(last, key) {
+		t.Errorf("health leaks the key: %q", last)
+	}
+}
@@ -0,0 +1,386 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/redact"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// managedProvider wraps a registered provider with the request pipeline
+// shared by every provider. The registry hands out managed providers, so
+// tools get the same behavior whichever backend serves a model.
+type managedProvider struct {
+	Provider
+	strict   bool
+	cache    *ResponseCache // nil when caching is disabled
+	registry *Registry      // budgets, costs, rate limits, health and downgrade targets; nil in tests
+}
+
+// Unwrap returns the underlying provider
+func (m *managedProvider) Unwrap() Provider {
+	return m.Provider
+}
+
+// model strips this provider's qualifier from a "provider:model" name
+func (m *managedProvider) model(name string) string {
+	if model, ok := strings.CutPrefix(name, string(m.GetProviderType())+":"); ok {
+		return model
+	}
+	return name
+}
+
+func (m *managedProvider) GetCapabilities(modelName string) (*types.ModelCapabilities, error) {
+	return m.Provider.GetCapabilities(m.model(modelName))
+}
+
+func (m *managedProvider) SupportsModel(modelName string) bool {
+	return m.Provider.SupportsModel(m.model(modelName))
+}
+
+func (m *managedProvider) CountTokens(ctx context.Context, text string, modelName string) (int, error) {
+	return m.Provider.CountTokens(ctx, text, m.model(modelName))
+}
+
+// checkOptions rejects provider options not on this provider's allowlist
+func (m *managedProvider) checkOptions(opts map[string]any, model string) error {
+	if len(opts) == 0 {
+		return nil
+	}
+	var allowed []string
+	if m.registry != nil {
+		allowed = m.registry.cfg.AllowedProviderOptions(m.GetProviderType())
+	}
+	return checkProviderOptions(opts, allowed, model, m.GetProviderType())
+}
+
+// GenerateContent normalizes the request against the model's capabilities,
+// serves it from the response cache when possible, enforces spending
+// budgets, rate limits and the circuit breaker, prices the call, validates
+// structured output, and reports adjustments, cache hits and downgrades in
+// the metadata. Errors are redacted so configured keys never reach callers.
+func (m *managedProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*types.ModelResponse, error) {
+	if model := m.model(req.Model); model != req.Model {
+		unqualified := *req
+		unqualified.Model = model
+		req = &unqualified
+	}
+	resp, err := m.generate(ctx, req, true)
+	return resp, redact.Error(err)
+}
+
+// generate handles structured output around the rest of the pipeline. Models
+// without native schema support get the schema in the prompt; the reply is
+// validated either way, and an invalid prompted reply gets one repair turn.
+func (m *managedProvider) generate(ctx context.Context, req *GenerateRequest, checkBudget bool) (*types.ModelResponse, error) {
+	if req.ResponseSchema == nil {
+		return m.call(ctx, req, checkBudget)
+	}
+
+	caps, err := m.GetCapabilities(req.Model)
+	if err != nil {
+		return m.call(ctx, req, checkBudget)
+	}
+
+	attempt := *req
+	mode := StructuredNative
+	if !caps.SupportsJSONSchema {
+		mode = StructuredPrompt
+		attempt.Prompt += schemaInstructions(req.ResponseSchema)
+		attempt.ResponseSchema = nil
+	}
+
+	resp, err := m.call(ctx, &attempt, checkBudget)
+	if err != nil {
+		return nil, err
+	}
+	// A round of function calls comes before the structured answer
+	if len(resp.ToolCalls) > 0 {
+		return resp, nil
+	}
+
+	data, verr := validateStructured(resp, req.ResponseSchema)
+	if verr != nil && mode == StructuredPrompt {
+		slog.Debug("structured response invalid, asking for a repair", "model", caps.ModelName, "error", verr)
+
+		repair := attempt
+		repair.ConversationHistory = append(slices.Clone(attempt.ConversationHistory),
+			types.ConversationTurn{Role: "user", Content: attempt.Prompt},
+			types.ConversationTurn{Role: "assistant", Content: resp.Content},
+		)
+		repair.Prompt = fmt.Sprintf("That reply does not match the schema (%s). Reply again with only the corrected JSON document.", verr)
+
+		spent := resp.CostUSD
+		resp, err = m.call(ctx, &repair, checkBudget)
+		if err != nil {
+			return nil, err
+		}
+		resp.CostUSD += spent
+		data, verr = validateStructured(resp, req.ResponseSchema)
+	}
+	if verr != nil {
+		return nil, ErrSchemaMismatch{Model: caps.ModelName, Reason: verr.Error()}
+	}
+
+	resp.Content = data
+	setMetadata(resp, "structured_output", mode)
+	return resp, nil
+}
+
+// validateStructured extracts the JSON document from a reply and checks it
+// against the schema
+func validateStructured(resp *types.ModelResponse, schema *JSONSchema) (string, error) {
+	data, err := ExtractJSON(resp.Content)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateJSON(data, schema.Schema); err != nil {
+		return "", err
+	}
+	return data, nil
+}
+
+// call runs one request through normalization, the cache, budgets, rate
+// limits and the circuit breaker to the provider
+func (m *managedProvider) call(ctx context.Context, req *GenerateRequest, checkBudget bool) (*types.ModelResponse, error) {
+	caps, err := m.GetCapabilities(req.Model)
+	unlisted := err != nil
+	if unlisted {
+		caps = unlistedCapabilities(m.GetProviderType(), req.Model)
+	}
+
+	if caps.Embedding {
+		return nil, ErrModelKind{Model: caps.ModelName, Provider: m.GetProviderType(), Embedding: true}
+	}
+
+	// Unlisted models aren't shaped, since their limits are unknown
+	normalized, adjustments := req, []string(nil)
+	if !unlisted {
+		normalized, adjustments, err = NormalizeRequest(req, caps, m.strict)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkOptions(normalized.ProviderOptions, caps.ModelName); err != nil {
+		return nil, err
+	}
+
+	var cacheKey string
+	if m.cache != nil {
+		cacheKey = CacheKey(m.GetProviderType(), caps.ModelName, normalized)
+		// no_cache skips the lookup but still refreshes the stored entry
+		if !req.NoCache {
+			if cached, ok := m.cache.Get(cacheKey); ok {
+				// Cache hits are free and don't count against budgets
+				cached.CostUSD = 0
+				setMetadata(cached, "cache_hit", true)
+				toolUsageFrom(ctx).add(cached.TokensUsed.TotalTokens, 0, true, true)
+				return cached, nil
+			}
+		}
+	}
+
+	if checkBudget && m.registry != nil {
+		if err := m.registry.costs.CheckBudget(m.registry.cfg.Budgets, toolName(ctx), req.ThreadID); err != nil {
+			if m.registry.cfg.Budgets.Action != config.BudgetDowngrade {
+				return nil, err
+			}
+			return m.downgrade(ctx, req, caps, err)
+		}
+	}
+
+	var reservation *rateReservation
+	if m.registry != nil {
+		reservation, err = m.registry.limiter.Acquire(ctx, m.GetProviderType(), caps, func() int {
+			return m.estimateTokens(ctx, normalized, caps.ModelName)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := m.registry.health.allow(m.GetProviderType()); err != nil {
+			reservation.Cancel()
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := m.Provider.GenerateContent(ctx, normalized)
+	if m.registry != nil {
+		m.registry.health.record(m.GetProviderType(), time.Since(start), err)
+	}
+	if err != nil {
+		reservation.Refund()
+		return nil, err
+	}
+	reservation.Settle(resp.TokensUsed.TotalTokens)
+
+	resp.CostUSD = caps.Cost(resp.TokensUsed)
+	if m.registry != nil {
+		m.registry.costs.Record(toolName(ctx), req.ThreadID, caps.ModelName, resp.CostUSD)
+	}
+	toolUsageFrom(ctx).add(resp.TokensUsed.TotalTokens, resp.CostUSD, caps.HasPricing(), false)
+
+	if len(adjustments) > 0 {
+		setMetadata(resp, "request_adjustments", adjustments)
+	}
+
+	if m.cache != nil {
+		if err := m.cache.Put(cacheKey, resp); err != nil {
+			slog.Warn("failed to cache response", "model", caps.ModelName, "error", err)
+		}
+		setMetadata(resp, "cache_hit", false)
+	}
+
+	return resp, nil
+}
+
+// Embed runs an embedding call through budgets, rate limits and the circuit
+// breaker, and prices it by input tokens. Budgets reached are reported
+// rather than downgraded, since vectors from another model aren't
+// comparable.
+func (m *managedProvider) Embed(ctx context.Context, texts []string, model string) (*EmbedResponse, error) {
+	resp, err := m.embed(ctx, texts, model)
+	return resp, redact.Error(err)
+}
+
+func (m *managedProvider) embed(ctx context.Context, texts []string, model string) (*EmbedResponse, error) {
+	model = m.model(model)
+	e, ok := m.Provider.(Embedder)
+	if !ok {
+		return nil, ErrEmbeddingsNotSupported{Provider: m.GetProviderType()}
+	}
+
+	caps, err := m.GetCapabilities(model)
+	if err != nil {
+		caps = unlistedCapabilities(m.GetProviderType(), model)
+	}
+
+	var reservation *rateReservation
+	if m.registry != nil {
+		if err := m.registry.costs.CheckBudget(m.registry.cfg.Budgets, toolName(ctx), ""); err != nil {
+			return nil, err
+		}
+		reservation, err = m.registry.limiter.Acquire(ctx, m.GetProviderType(), caps, func() int {
+			return m.estimateTokens(ctx, &GenerateRequest{Prompt: strings.Join(texts, "\n")}, caps.ModelName)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := m.registry.health.allow(m.GetProviderType()); err != nil {
+			reservation.Cancel()
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := e.Embed(ctx, texts, caps.ModelName)
+	if m.registry != nil {
+		m.registry.health.record(m.GetProviderType(), time.Since(start), err)
+	}
+	if err != nil {
+		reservation.Refund()
+		return nil, err
+	}
+	reservation.Settle(resp.TokensUsed.TotalTokens)
+
+	resp.CostUSD = caps.Cost(resp.TokensUsed)
+	if m.registry != nil {
+		m.registry.costs.Record(toolName(ctx), "", caps.ModelName, resp.CostUSD)
+	}
+	toolUsageFrom(ctx).add(resp.TokensUsed.TotalTokens, resp.CostUSD, caps.HasPricing(), false)
+
+	return resp, nil
+}
+
+// unlistedCapabilities stands in for a model outside the provider's catalog,
+// such as a provider-qualified name the provider hasn't listed yet, so the
+// call still goes through budgets, rate limits and cost accounting. It has
+// no pricing, so the call is recorded as unpriced.
+func unlistedCapabilities(pt types.ProviderType, model string) *types.ModelCapabilities {
+	caps := inferCapabilities(pt, openAIModelEntry{ID: model})
+	caps.Discovered = false
+	return &caps
+}
+
+// downgrade serves a request that hit a budget limit with a cheaper model
+func (m *managedProvider) downgrade(ctx context.Context, req *GenerateRequest, caps *types.ModelCapabilities, reason error) (*types.ModelResponse, error) {
+	target, p, err := m.registry.downgradeModel(caps, len(req.Images) > 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w; cannot downgrade: %v", reason, err)
+	}
+
+	slog.Info("budget reached, downgrading model", "from", caps.ModelName, "to", target.ModelName, "reason", reason)
+
+	down := *req
+	down.Model = target.ModelName
+
+	var resp *types.ModelResponse
+	if mp, ok := p.(*managedProvider); ok {
+		resp, err = mp.generate(ctx, &down, false)
+	} else {
+		resp, err = p.GenerateContent(ctx, &down)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	setMetadata(resp, "budget_downgrade", fmt.Sprintf("%s -> %s (%s)", caps.ModelName, target.ModelName, reason))
+	return resp, nil
+}
+
+// estimateTokens counts a request's input with the provider's counter and
+// adds the requested output, which TPM limits also count
+func (m *managedProvider) estimateTokens(ctx context.Context, req *GenerateRequest, model string) int {
+	var sb strings.Builder
+	sb.WriteString(req.SystemPrompt)
+	for _, turn := range req.ConversationHistory {
+		sb.WriteString("\n")
+		sb.WriteString(turn.Content)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(req.Prompt)
+	for _, ex := range req.ToolTurns {
+		sb.WriteString("\n")
+		sb.WriteString(ex.Content)
+		for _, c := range ex.Calls {
+			sb.WriteString("\n")
+			sb.WriteString(c.Arguments)
+		}
+		for _, r := range ex.Results {
+			sb.WriteString("\n")
+			sb.WriteString(r.Content)
+		}
+	}
+
+	n, err := m.CountTokens(ctx, sb.String(), model)
+	if err != nil {
+		n = tokenizer.Estimate(sb.String())
+	}
+	return n + req.MaxOutputTokens
+}
+
+func setMetadata(resp *types.ModelResponse, key string, value any) {
+	if resp.Metadata == nil {
+		resp.Metadata = make(map[string]any)
+	}
+	resp.Metadata[key] = value
+}
+
+// unwrapProvider returns the provider beneath any registry wrapper, for
+// optional interfaces such as ModelDiscoverer
+func unwrapProvider(p Provider) Provider {
+	for {
+		w, ok := p.(interface{ Unwrap() Provider })
+		if !ok {
+			return p
+		}
+		p = w.Unwrap()
+	}
+}
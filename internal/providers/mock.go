@@ -0,0 +1,346 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+// MockFixtures is the fixtures file for the mock provider. Responses are
+// tried in order and the first match answers; models default to mock-pro and
+// mock-flash when none are listed.
+type MockFixtures struct {
+	Models    []types.ModelCapabilities `json:"models,omitempty"`
+	Responses []MockResponse            `json:"responses"`
+}
+
+// MockResponse is a scripted reply. The regexes are matched against the
+// resolved model name, the prompt, the system prompt and the response
+// schema name; empty ones match anything.
+type MockResponse struct {
+	Model  string `json:"model,omitempty"`
+	Prompt string `json:"prompt,omitempty"`
+	System string `json:"system,omitempty"`
+	Schema string `json:"schema,omitempty"`
+	Times  int    `json:"times,omitempty"` // answers at most this many requests; 0 is unlimited
+
+	Content      string            `json:"content,omitempty"`
+	ToolCalls    []types.ToolCall  `json:"tool_calls,omitempty"`
+	FinishReason string            `json:"finish_reason,omitempty"`
+	Usage        *types.TokenUsage `json:"usage,omitempty"` // estimated from the text when unset
+	LatencyMS    int               `json:"latency_ms,omitempty"`
+	Error        *MockError        `json:"error,omitempty"`
+}
+
+// MockError makes a fixture fail like an API error
+type MockError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// MockRequest records a request the mock provider received
+type MockRequest struct {
+	Time            time.Time `json:"time"`
+	Model           string    `json:"model"`
+	SystemPrompt    string    `json:"system_prompt,omitempty"`
+	Prompt          string    `json:"prompt"`
+	HistoryTurns    int       `json:"history_turns,omitempty"`
+	ThreadID        string    `json:"thread_id,omitempty"`
+	Images          int       `json:"images,omitempty"`
+	Tools           []string  `json:"tools,omitempty"`
+	ToolTurns       int       `json:"tool_turns,omitempty"`
+	Schema          string    `json:"schema,omitempty"`
+	ProviderOptions []string  `json:"provider_options,omitempty"` // keys only
+	Fixture         int       `json:"fixture"`                    // index of the matching response, -1 when none matched
+}
+
+type mockFixture struct {
+	MockResponse
+	model, prompt, system, schema *regexp.Regexp
+}
+
+// MockProvider serves scripted responses from a fixtures file so tools and
+// workflows can run without API keys. It records every request, in memory
+// and optionally to a JSON lines file.
+type MockProvider struct {
+	*BaseProvider
+	fixtures   []mockFixture
+	uses       []int
+	requests   []MockRequest
+	recordFile string
+	mu         sync.Mutex
+}
+
+// NewMockProvider creates a mock provider from MOCK_FIXTURES_FILE
+func NewMockProvider(cfg *config.Config) (*MockProvider, error) {
+	data, err := os.ReadFile(cfg.MockFixturesFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading mock fixtures: %w", err)
+	}
+
+	var fixtures MockFixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("parsing mock fixtures %s: %w", cfg.MockFixturesFile, err)
+	}
+
+	p, err := NewMockProviderFromFixtures(&fixtures)
+	if err != nil {
+		return nil, err
+	}
+	p.recordFile = cfg.MockRecordFile
+	return p, nil
+}
+
+// NewMockProviderFromFixtures creates a mock provider from parsed fixtures
+func NewMockProviderFromFixtures(f *MockFixtures) (*MockProvider, error) {
+	models := f.Models
+	if len(models) == 0 {
+		models = defaultMockModels()
+	}
+	for i := range models {
+		models[i].Provider = types.ProviderMock
+	}
+
+	p := &MockProvider{
+		BaseProvider: NewBaseProvider(types.ProviderMock, models),
+		uses:         make([]int, len(f.Responses)),
+	}
+
+	for i, r := range f.Responses {
+		fx := mockFixture{MockResponse: r}
+		for _, field := range []struct {
+			pattern string
+			re      **regexp.Regexp
+		}{
+			{r.Model, &fx.model},
+			{r.Prompt, &fx.prompt},
+			{r.System, &fx.system},
+			{r.Schema, &fx.schema},
+		} {
+			if field.pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile(field.pattern)
+			if err != nil {
+				return nil, fmt.Errorf("mock response %d: invalid pattern %q: %w", i, field.pattern, err)
+			}
+			*field.re = re
+		}
+		p.fixtures = append(p.fixtures, fx)
+	}
+
+	return p, nil
+}
+
+func (p *MockProvider) IsConfigured() bool {
+	return true
+}
+
+func (p *MockProvider) CountTokens(ctx context.Context, text string, modelName string) (int, error) {
+	return tokenizer.Estimate(text), nil
+}
+
+// GenerateContent answers with the first matching fixture
+func (p *MockProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*types.ModelResponse, error) {
+	modelName := p.ResolveModelName(req.Model)
+
+	if err := p.CheckAllowed(modelName); err != nil {
+		return nil, err
+	}
+	if err := p.CheckVision(modelName, req); err != nil {
+		return nil, err
+	}
+
+	fx, index := p.match(modelName, req)
+	p.record(modelName, req, index)
+
+	if fx == nil {
+		return nil, ErrAPIError{
+			Provider:   types.ProviderMock,
+			StatusCode: 404,
+			Message:    fmt.Sprintf("no mock response matches model %q and prompt %q", modelName, utils.Truncate(req.Prompt, 80)),
+		}
+	}
+
+	if fx.LatencyMS > 0 {
+		select {
+		case <-time.After(time.Duration(fx.LatencyMS) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("making request: %w", ctx.Err())
+		}
+	}
+
+	if fx.Error != nil {
+		return nil, ErrAPIError{Provider: types.ProviderMock, StatusCode: fx.Error.Status, Message: fx.Error.Message}
+	}
+
+	finishReason := fx.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+		if len(fx.ToolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+	}
+
+	return &types.ModelResponse{
+		Content:      fx.Content,
+		Model:        modelName,
+		Provider:     types.ProviderMock,
+		FinishReason: finishReason,
+		TokensUsed:   mockUsage(fx, req),
+		ToolCalls:    fx.ToolCalls,
+		Metadata:     map[string]any{"mock_fixture": index},
+	}, nil
+}
+
+// match returns the first fixture matching the request and counts its use
+func (p *MockProvider) match(model string, req *GenerateRequest) (*mockFixture, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.fixtures {
+		fx := &p.fixtures[i]
+		if fx.Times > 0 && p.uses[i] >= fx.Times {
+			continue
+		}
+		if (fx.model != nil && !fx.model.MatchString(model)) ||
+			(fx.prompt != nil && !fx.prompt.MatchString(req.Prompt)) ||
+			(fx.system != nil && !fx.system.MatchString(req.SystemPrompt)) ||
+			(fx.schema != nil && (req.ResponseSchema == nil || !fx.schema.MatchString(schemaName(req.ResponseSchema)))) {
+			continue
+		}
+		p.uses[i]++
+		return fx, i
+	}
+	return nil, -1
+}
+
+// record stores the request and appends it to the record file
+func (p *MockProvider) record(model string, req *GenerateRequest, fixture int) {
+	entry := MockRequest{
+		Time:         time.Now(),
+		Model:        model,
+		SystemPrompt: req.SystemPrompt,
+		Prompt:       req.Prompt,
+		HistoryTurns: len(req.ConversationHistory),
+		ThreadID:     req.ThreadID,
+		Images:       len(req.Images),
+		ToolTurns:    len(req.ToolTurns),
+		Fixture:      fixture,
+	}
+	for _, fn := range req.Tools {
+		entry.Tools = append(entry.Tools, fn.Name)
+	}
+	if req.ResponseSchema != nil {
+		entry.Schema = schemaName(req.ResponseSchema)
+	}
+	entry.ProviderOptions = slices.Sorted(maps.Keys(req.ProviderOptions))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, entry)
+
+	if p.recordFile == "" {
+		return
+	}
+	line, _ := json.Marshal(entry)
+	f, err := os.OpenFile(p.recordFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		slog.Warn("failed to record mock request", "file", p.recordFile, "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		slog.Warn("failed to record mock request", "file", p.recordFile, "error", err)
+	}
+}
+
+// Requests returns the requests received so far, oldest first
+func (p *MockProvider) Requests() []MockRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]MockRequest(nil), p.requests...)
+}
+
+// Reset clears recorded requests and fixture use counts
+func (p *MockProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = nil
+	p.uses = make([]int, len(p.fixtures))
+}
+
+// mockUsage returns the fixture's usage, or estimates it from the text
+func mockUsage(fx *mockFixture, req *GenerateRequest) types.TokenUsage {
+	if fx.Usage != nil {
+		usage := *fx.Usage
+		if usage.TotalTokens == 0 {
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		}
+		return usage
+	}
+
+	var sb strings.Builder
+	sb.WriteString(req.SystemPrompt)
+	for _, turn := range req.ConversationHistory {
+		sb.WriteString(turn.Content)
+	}
+	sb.WriteString(req.Prompt)
+	for _, ex := range req.ToolTurns {
+		for _, r := range ex.Results {
+			sb.WriteString(r.Content)
+		}
+	}
+
+	prompt := tokenizer.Estimate(sb.String())
+	completion := tokenizer.Estimate(fx.Content)
+	return types.TokenUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
+
+func defaultMockModels() []types.ModelCapabilities {
+	return []types.ModelCapabilities{
+		{
+			ModelName:                "mock-pro",
+			FriendlyName:             "Mock Pro",
+			IntelligenceScore:        90,
+			Aliases:                  []string{"mock"},
+			ContextWindow:            200000,
+			MaxOutputTokens:          32768,
+			MaxThinkingTokens:        16384,
+			SupportsExtendedThinking: true,
+			SupportsSystemPrompts:    true,
+			SupportsStreaming:        true,
+			SupportsVision:           true,
+			AllowCodeGeneration:      true,
+			SupportsJSONSchema:       true,
+			SupportsFunctionCalling:  true,
+		},
+		{
+			ModelName:             "mock-flash",
+			FriendlyName:          "Mock Flash",
+			IntelligenceScore:     60,
+			ContextWindow:         100000,
+			MaxOutputTokens:       8192,
+			SupportsSystemPrompts: true,
+			SupportsStreaming:     true,
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+const mockFixturesJSON = `{
+  "responses": [
+    {"model": "^mock-flash$", "content": "flash says hi"},
+    {"prompt": "(?i)deploy", "times": 1, "error": {"status": 503, "message": "overloaded"}},
+    {"prompt": "slow", "latency_ms": 200, "content": "eventually"},
+    {"schema": "verdict", "content": "{\"score\": 4, \"severity\": \"low\"}"},
+    {"prompt": "usage", "content": "counted", "usage": {"prompt_tokens": 7, "completion_tokens": 3}},
+    {"content": "default answer"}
+  ]
+}`
+
+// newMockRegistry initializes a registry whose only provider is the mock,
+// configured the way users enable it
+func newMockRegistry(t *testing.T, fixtures string) (*Registry, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fixtures.json")
+	if err := os.WriteFile(path, []byte(fixtures), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	record := filepath.Join(dir, "requests.jsonl")
+	r := NewRegistry(&config.Config{
+		MockFixturesFile: path,
+		MockRecordFile:   record,
+		CostLedgerFile:   filepath.Join(dir, "costs.jsonl"),
+	})
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if r.MockProvider() == nil {
+		t.Fatal("expected the mock provider to be registered")
+	}
+	return r, record
+}
+
+func TestMockProvider_Fixtures(t *testing.T) {
+	r, record := newMockRegistry(t, mockFixturesJSON)
+	ctx := context.Background()
+
+	generate := func(model, prompt string) (*types.ModelResponse, error) {
+		p, err := r.GetProviderForModel(model)
+		if err != nil {
+			t.Fatalf("GetProviderForModel(%s) failed: %v", model, err)
+		}
+		return p.GenerateContent(ctx, &GenerateRequest{Prompt: prompt, Model: model})
+	}
+
+	if resp, _ := generate("mock-flash", "anything"); resp.Content != "flash says hi" {
+		t.Errorf("expected the model fixture, got %q", resp.Content)
+	}
+
+	// The error fixture answers once, then falls through to the default
+	_, err := generate("mock", "Deploy it")
+	var apiErr ErrAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+		t.Fatalf("expected a 503 API error, got %v", err)
+	}
+	if resp, _ := generate("mock", "Deploy it"); resp.Content != "default answer" {
+		t.Errorf("expected the exhausted fixture to be skipped, got %q", resp.Content)
+	}
+
+	resp, _ := generate("mock-pro", "usage please")
+	if resp.TokensUsed != (types.TokenUsage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}) {
+		t.Errorf("expected scripted usage, got %+v", resp.TokensUsed)
+	}
+	resp, _ = generate("mock-pro", "estimate the tokens")
+	if resp.Model != "mock-pro" || resp.TokensUsed.PromptTokens == 0 || resp.TokensUsed.CompletionTokens == 0 {
+		t.Errorf("expected estimated usage, got %+v", resp)
+	}
+
+	requests := r.MockProvider().Requests()
+	if len(requests) != 5 || requests[0].Fixture != 0 || requests[1].Fixture != 1 || requests[2].Fixture != 5 {
+		t.Fatalf("unexpected recorded requests %+v", requests)
+	}
+	data, err := os.ReadFile(record)
+	if err != nil || strings.Count(string(data), "\n") != 5 || !strings.Contains(string(data), `"prompt":"Deploy it"`) {
+		t.Errorf("expected 5 JSON lines in the record file, got %q, %v", data, err)
+	}
+}
+
+func TestMockProvider_LatencyAndSchema(t *testing.T) {
+	r, _ := newMockRegistry(t, mockFixturesJSON)
+	p, _ := r.GetProviderForModel("mock-pro")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.GenerateContent(ctx, &GenerateRequest{Prompt: "slow", Model: "mock-pro"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the simulated latency to honor the deadline, got %v", err)
+	}
+	if time.Since(start) > 150*time.Millisecond {
+		t.Error("cancelled call waited out the full latency")
+	}
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "judge", Model: "mock-pro", ResponseSchema: testSchema})
+	if err != nil {
+		t.Fatalf("structured call failed: %v", err)
+	}
+	if v, err := DecodeResponse[verdict](resp); err != nil || v.Score != 4 {
+		t.Errorf("DecodeResponse() = %+v, %v", v, err)
+	}
+}
+
+func TestMockProvider_NoMatch(t *testing.T) {
+	r, _ := newMockRegistry(t, `{"responses": [{"prompt": "^hello$", "content": "hi"}]}`)
+	p, _ := r.GetProviderForModel("mock-pro")
+
+	_, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "goodbye", Model: "mock-pro"})
+	if err == nil || !strings.Contains(err.Error(), "no mock response matches") {
+		t.Fatalf("expected a no-match error, got %v", err)
+	}
+	if requests := r.MockProvider().Requests(); len(requests) != 1 || requests[0].Fixture != -1 {
+		t.Errorf("unmatched requests should still be recorded, got %+v", requests)
+	}
+	if !r.ProviderHealth(types.ProviderMock).Healthy() {
+		t.Error("a missing fixture should not count against provider health")
+	}
+}
+
+func TestNewMockProvider_InvalidPattern(t *testing.T) {
+	_, err := NewMockProviderFromFixtures(&MockFixtures{Responses: []MockResponse{{Prompt: "("}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("expected an invalid pattern error, got %v", err)
+	}
+}
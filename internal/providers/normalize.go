@@ -0,0 +1,258 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Request validation modes (REQUEST_VALIDATION)
+const (
+	ValidationClamp  = "clamp"  // adjust out-of-range values and report them
+	ValidationStrict = "strict" // reject out-of-range values
+)
+
+// ErrInvalidRequest indicates a request parameter is outside the model's limits
+type ErrInvalidRequest struct {
+	Model  string
+	Field  string
+	Reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return fmt.Sprintf("invalid %s for model %q: %s", e.Field, e.Model, e.Reason)
+}
+
+// defaultThinkingBudget maps a thinking mode to a token budget for models
+// that take an explicit budget
+func defaultThinkingBudget(mode types.ThinkingMode) int {
+	switch mode {
+	case types.ThinkingMinimal:
+		return 1024
+	case types.ThinkingLow:
+		return 4096
+	case types.ThinkingMedium:
+		return 8192
+	case types.ThinkingHigh:
+		return 16384
+	case types.ThinkingMax:
+		return 32768
+	default:
+		return 0
+	}
+}
+
+// ReplyReserve is the context room a request leaves for the model's reply:
+// the requested output limit, or up to 8k tokens of the model's, plus the
+// thinking budget on models with extended thinking
+func ReplyReserve(req *GenerateRequest, caps *types.ModelCapabilities) int {
+	output := req.MaxOutputTokens
+	if output <= 0 {
+		output = replyReserve
+		if caps.MaxOutputTokens > 0 {
+			output = min(output, caps.MaxOutputTokens)
+		}
+	}
+
+	thinking := 0
+	if caps.SupportsExtendedThinking {
+		thinking = req.ThinkingBudget
+		if thinking <= 0 {
+			thinking = defaultThinkingBudget(req.ThinkingMode)
+		}
+		if caps.MaxThinkingTokens > 0 {
+			thinking = min(thinking, caps.MaxThinkingTokens)
+		}
+	}
+
+	return output + thinking
+}
+
+// NormalizeRequest shapes a request to a model's declared capabilities. It
+// returns a copy with the model's defaults filled in, temperature, sampling
+// and token limits brought into range, the system prompt folded into the
+// prompt when the model has no system role, and the thinking budget capped.
+// Each change is described in the returned adjustments. In strict mode
+// out-of-range values are rejected instead.
+func NormalizeRequest(req *GenerateRequest, caps *types.ModelCapabilities, strict bool) (*GenerateRequest, []string, error) {
+	out := *req
+	var adjustments []string
+
+	reject := func(field, reason string) error {
+		return ErrInvalidRequest{Model: caps.ModelName, Field: field, Reason: reason}
+	}
+
+	// Model defaults fill what the request leaves unset
+	if d := caps.Defaults; d != nil {
+		out.Sampling = out.Sampling.WithDefaults(d.SamplingParams)
+		out.ProviderOptions = mergeProviderOptions(d.ProviderOptions, out.ProviderOptions)
+	}
+
+	// Sampling ranges shared by the OpenAI and Gemini APIs
+	if p := out.Sampling.TopP; p != nil && (*p <= 0 || *p > 1) {
+		if strict {
+			return nil, nil, reject("top_p", fmt.Sprintf("%.2g is outside (0, 1]", *p))
+		}
+		adjustments = append(adjustments, fmt.Sprintf("top_p %.2g -> provider default", *p))
+		out.Sampling.TopP = nil
+	}
+	for _, penalty := range []struct {
+		field string
+		value **float64
+	}{
+		{"presence_penalty", &out.Sampling.PresencePenalty},
+		{"frequency_penalty", &out.Sampling.FrequencyPenalty},
+	} {
+		if v := *penalty.value; v != nil && (*v < -2 || *v > 2) {
+			clamped := max(-2, min(2, *v))
+			if strict {
+				return nil, nil, reject(penalty.field, fmt.Sprintf("%.2g is outside [-2, 2]", *v))
+			}
+			adjustments = append(adjustments, fmt.Sprintf("%s %.2g -> %.2g", penalty.field, *v, clamped))
+			*penalty.value = &clamped
+		}
+	}
+	if out.Sampling.N < 0 {
+		if strict {
+			return nil, nil, reject("n", "must not be negative")
+		}
+		adjustments = append(adjustments, fmt.Sprintf("n %d -> 1", out.Sampling.N))
+		out.Sampling.N = 0
+	}
+
+	// OpenAI reasoning models reject temperature, top_p, stop sequences and
+	// penalties, which the providers leave out
+	if isOpenAIReasoningModel(caps.ModelName) {
+		var dropped []string
+		if out.Temperature > 0 {
+			dropped = append(dropped, "temperature")
+			out.Temperature = 0
+		}
+		if out.Sampling.TopP != nil {
+			dropped = append(dropped, "top_p")
+			out.Sampling.TopP = nil
+		}
+		if len(out.Sampling.Stop) > 0 {
+			dropped = append(dropped, "stop")
+			out.Sampling.Stop = nil
+		}
+		if out.Sampling.PresencePenalty != nil {
+			dropped = append(dropped, "presence_penalty")
+			out.Sampling.PresencePenalty = nil
+		}
+		if out.Sampling.FrequencyPenalty != nil {
+			dropped = append(dropped, "frequency_penalty")
+			out.Sampling.FrequencyPenalty = nil
+		}
+		if len(dropped) > 0 {
+			adjustments = append(adjustments, strings.Join(dropped, ", ")+" dropped (not supported by OpenAI reasoning models)")
+		}
+	}
+
+	// The Responses API has no seed, stop sequences, penalties or n
+	if caps.API == types.APIResponses {
+		var dropped []string
+		if out.Sampling.Seed != nil {
+			dropped = append(dropped, "seed")
+			out.Sampling.Seed = nil
+		}
+		if len(out.Sampling.Stop) > 0 {
+			dropped = append(dropped, "stop")
+			out.Sampling.Stop = nil
+		}
+		if out.Sampling.PresencePenalty != nil {
+			dropped = append(dropped, "presence_penalty")
+			out.Sampling.PresencePenalty = nil
+		}
+		if out.Sampling.FrequencyPenalty != nil {
+			dropped = append(dropped, "frequency_penalty")
+			out.Sampling.FrequencyPenalty = nil
+		}
+		if out.Sampling.N > 1 {
+			dropped = append(dropped, "n")
+			out.Sampling.N = 0
+		}
+		if len(dropped) > 0 {
+			adjustments = append(adjustments, strings.Join(dropped, ", ")+" dropped (not supported by the Responses API)")
+		}
+	}
+
+	// Temperature: zero means "provider default" throughout, so only explicit
+	// values are checked
+	if out.Temperature < 0 {
+		if strict {
+			return nil, nil, reject("temperature", "must not be negative")
+		}
+		adjustments = append(adjustments, fmt.Sprintf("temperature %.2g -> provider default", out.Temperature))
+		out.Temperature = 0
+	}
+	if out.Temperature > 0 {
+		if caps.MinTemperature != nil && out.Temperature < *caps.MinTemperature {
+			if strict {
+				return nil, nil, reject("temperature", fmt.Sprintf("%.2g is below the minimum %.2g", out.Temperature, *caps.MinTemperature))
+			}
+			adjustments = append(adjustments, fmt.Sprintf("temperature %.2g -> %.2g (model minimum)", out.Temperature, *caps.MinTemperature))
+			out.Temperature = *caps.MinTemperature
+		}
+		if caps.MaxTemperature != nil && out.Temperature > *caps.MaxTemperature {
+			if strict {
+				return nil, nil, reject("temperature", fmt.Sprintf("%.2g is above the maximum %.2g", out.Temperature, *caps.MaxTemperature))
+			}
+			adjustments = append(adjustments, fmt.Sprintf("temperature %.2g -> %.2g (model maximum)", out.Temperature, *caps.MaxTemperature))
+			out.Temperature = *caps.MaxTemperature
+		}
+	}
+
+	// Output tokens
+	if out.MaxOutputTokens < 0 {
+		if strict {
+			return nil, nil, reject("max_output_tokens", "must not be negative")
+		}
+		adjustments = append(adjustments, fmt.Sprintf("max_output_tokens %d -> provider default", out.MaxOutputTokens))
+		out.MaxOutputTokens = 0
+	}
+	if caps.MaxOutputTokens > 0 && out.MaxOutputTokens > caps.MaxOutputTokens {
+		if strict {
+			return nil, nil, reject("max_output_tokens", fmt.Sprintf("%d exceeds the model limit %d", out.MaxOutputTokens, caps.MaxOutputTokens))
+		}
+		adjustments = append(adjustments, fmt.Sprintf("max_output_tokens %d -> %d (model limit)", out.MaxOutputTokens, caps.MaxOutputTokens))
+		out.MaxOutputTokens = caps.MaxOutputTokens
+	}
+
+	// Thinking budget
+	if !caps.SupportsExtendedThinking {
+		if out.ThinkingBudget > 0 {
+			adjustments = append(adjustments, "thinking budget dropped (model has no extended thinking)")
+			out.ThinkingBudget = 0
+		}
+	} else if caps.MaxThinkingTokens > 0 {
+		budget := out.ThinkingBudget
+		if budget == 0 {
+			budget = defaultThinkingBudget(out.ThinkingMode)
+		}
+		if budget > caps.MaxThinkingTokens {
+			if strict && out.ThinkingBudget > 0 {
+				return nil, nil, reject("thinking_budget", fmt.Sprintf("%d exceeds the model limit %d", budget, caps.MaxThinkingTokens))
+			}
+			adjustments = append(adjustments, fmt.Sprintf("thinking budget %d -> %d (model limit)", budget, caps.MaxThinkingTokens))
+			out.ThinkingBudget = caps.MaxThinkingTokens
+		}
+	}
+
+	// Function calling
+	if len(out.Tools) > 0 && !caps.SupportsFunctionCalling {
+		adjustments = append(adjustments, "tools dropped (model has no function calling)")
+		out.Tools = nil
+		out.ToolChoice = ""
+	}
+
+	// System prompt
+	if out.SystemPrompt != "" && !caps.SupportsSystemPrompts {
+		out.Prompt = out.SystemPrompt + "\n\n" + out.Prompt
+		out.SystemPrompt = ""
+		adjustments = append(adjustments, "system prompt folded into user message (model has no system role)")
+	}
+
+	return &out, adjustments, nil
+}
@@ -0,0 +1,269 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestNormalizeRequest(t *testing.T) {
+	fixedTemp := &types.ModelCapabilities{
+		ModelName:             "fixed-temp",
+		MinTemperature:        floatPtr(1),
+		MaxTemperature:        floatPtr(1),
+		MaxOutputTokens:       1000,
+		SupportsSystemPrompts: true,
+	}
+	thinking := &types.ModelCapabilities{
+		ModelName:                "gemini-2.5-flash-lite",
+		MaxOutputTokens:          8192,
+		MaxThinkingTokens:        8192,
+		SupportsExtendedThinking: true,
+		SupportsSystemPrompts:    true,
+	}
+	noSystem := &types.ModelCapabilities{
+		ModelName:       "gemma-2",
+		MaxOutputTokens: 4096,
+	}
+	responses := &types.ModelCapabilities{
+		ModelName:             "responses-only",
+		API:                   types.APIResponses,
+		SupportsSystemPrompts: true,
+	}
+	reasoning := &types.ModelCapabilities{
+		ModelName:             "o3",
+		SupportsSystemPrompts: true,
+	}
+	seed := 7
+
+	tests := []struct {
+		name        string
+		caps        *types.ModelCapabilities
+		req         GenerateRequest
+		check       func(t *testing.T, out *GenerateRequest)
+		adjustments int
+	}{
+		{
+			name: "temperature clamped to fixed value",
+			caps: fixedTemp,
+			req:  GenerateRequest{Prompt: "p", Temperature: 0.7},
+			check: func(t *testing.T, out *GenerateRequest) {
+				if out.Temperature != 1 {
+					t.Errorf("expected temperature 1, got %v", out.Temperature)
+				}
+			},
+			adjustments: 1,
+		},
+		{
+			name: "unset temperature untouched",
+			caps: fixedTemp,
+			req:  GenerateRequest{Prompt: "p"},
+			check: func(t *testing.T, out *GenerateRequest) {
+				if out.Temperature != 0 {
+					t.Errorf("expected temperature 0, got %v", out.Temperature)
+				}
+			},
+		},
+		{
+			name: "output tokens capped",
+			caps: fixedTemp,
+			req:  GenerateRequest{Prompt: "p", MaxOutputTokens: 50000},
+			check: func(t *testing.T, out *GenerateRequest) {
+				if out.MaxOutputTokens != 1000 {
+					t.Errorf("expected 1000, got %d", out.MaxOutputTokens)
+				}
+			},
+			adjustments: 1,
+		},
+		{
+			name: "thinking mode budget capped",
+			caps: thinking,
+			req:  GenerateRequest{Prompt: "p", ThinkingMode: types.ThinkingMax},
+			check: func(t *testing.T, out *GenerateRequest) {
+				if out.ThinkingBudget != 8192 {
+					t.Errorf("expected budget 8192, got %d", out.ThinkingBudget)
+				}
+			},
+			adjustments: 1,
+		},
+		{
+			name: "thinking budget within limit",
+			caps: thinking,
+			req:  GenerateRequest{Prompt: "p", ThinkingMode: types.ThinkingLow},
+			check: func(t *testing.T, out *GenerateRequest) {
+				if out.ThinkingBudget != 0 {
+					t.Errorf("expected budget left to the provider, got %d", out.ThinkingBudget)
+				}
+			},
+		},
+		{
+			name: "system prompt folded",
+			caps: noSystem,
+			req:  GenerateRequest{Prompt: "question", SystemPrompt: "be brief"},
+			check: func(t *testing.T, out *GenerateRequest) {
+				if out.SystemPrompt != "" || out.Prompt != "be brief\n\nquestion" {
+					t.Errorf("unexpected fold: system=%q prompt=%q", out.SystemPrompt, out.Prompt)
+				}
+			},
+			adjustments: 1,
+		},
+		{
+			name: "sampling reasoning models reject dropped",
+			caps: reasoning,
+			req: GenerateRequest{Prompt: "p", Temperature: 0.7, Sampling: types.SamplingParams{
+				TopP: floatPtr(0.5), Seed: &seed, Stop: []string{"END"}, PresencePenalty: floatPtr(1),
+			}},
+			check: func(t *testing.T, out *GenerateRequest) {
+				s := out.Sampling
+				if out.Temperature != 0 || s.TopP != nil || s.Stop != nil || s.PresencePenalty != nil {
+					t.Errorf("expected unsupported sampling dropped, got temperature %v, %+v", out.Temperature, s)
+				}
+				if s.Seed == nil {
+					t.Error("expected seed kept")
+				}
+			},
+			adjustments: 1,
+		},
+		{
+			name: "sampling the Responses API lacks dropped",
+			caps: responses,
+			req: GenerateRequest{Prompt: "p", Sampling: types.SamplingParams{
+				TopP: floatPtr(0.5), Seed: &seed, Stop: []string{"END"}, FrequencyPenalty: floatPtr(1), N: 2,
+			}},
+			check: func(t *testing.T, out *GenerateRequest) {
+				s := out.Sampling
+				if s.Seed != nil || s.Stop != nil || s.FrequencyPenalty != nil || s.N != 0 {
+					t.Errorf("expected unsupported sampling dropped, got %+v", s)
+				}
+				if s.TopP == nil {
+					t.Error("expected top_p kept")
+				}
+			},
+			adjustments: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			out, adjustments, err := NormalizeRequest(&req, tt.caps, false)
+			if err != nil {
+				t.Fatalf("NormalizeRequest failed: %v", err)
+			}
+			tt.check(t, out)
+			if len(adjustments) != tt.adjustments {
+				t.Errorf("expected %d adjustments, got %v", tt.adjustments, adjustments)
+			}
+			if req.Temperature != tt.req.Temperature || req.Prompt != tt.req.Prompt || req.MaxOutputTokens != tt.req.MaxOutputTokens {
+				t.Error("input request was modified")
+			}
+		})
+	}
+}
+
+func TestNormalizeRequest_Strict(t *testing.T) {
+	caps := &types.ModelCapabilities{ModelName: "fixed-temp", MaxTemperature: floatPtr(1), MaxOutputTokens: 1000}
+
+	_, _, err := NormalizeRequest(&GenerateRequest{Temperature: 1.5}, caps, true)
+	var invalid ErrInvalidRequest
+	if !errors.As(err, &invalid) || invalid.Field != "temperature" {
+		t.Errorf("expected temperature rejection, got %v", err)
+	}
+
+	_, _, err = NormalizeRequest(&GenerateRequest{MaxOutputTokens: 2000}, caps, true)
+	if !errors.As(err, &invalid) || invalid.Field != "max_output_tokens" {
+		t.Errorf("expected max_output_tokens rejection, got %v", err)
+	}
+}
+
+func TestReplyReserve(t *testing.T) {
+	plain := &types.ModelCapabilities{MaxOutputTokens: 4096}
+	thinking := &types.ModelCapabilities{MaxOutputTokens: 64000, SupportsExtendedThinking: true, MaxThinkingTokens: 10000}
+
+	tests := []struct {
+		name string
+		req  GenerateRequest
+		caps *types.ModelCapabilities
+		want int
+	}{
+		{"model output limit below the default", GenerateRequest{}, plain, 4096},
+		{"requested output", GenerateRequest{MaxOutputTokens: 2000}, plain, 2000},
+		{"default output with thinking mode", GenerateRequest{ThinkingMode: types.ThinkingMedium}, thinking, 8192 + 8192},
+		{"thinking capped at the model limit", GenerateRequest{ThinkingMode: types.ThinkingMax}, thinking, 8192 + 10000},
+		{"thinking ignored without support", GenerateRequest{ThinkingBudget: 5000}, plain, 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReplyReserve(&tt.req, tt.caps); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestManagedProvider_ReportsAdjustments(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Content: "ok"}}},
+		})
+	}))
+	defer server.Close()
+
+	inner := NewOpenAICompatProvider(types.ProviderCustom, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "local", MaxOutputTokens: 2048, MaxTemperature: floatPtr(1)},
+	}, 0)
+	p := &managedProvider{Provider: inner}
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:          "hi",
+		SystemPrompt:    "sys",
+		Model:           "local",
+		Temperature:     1.2,
+		MaxOutputTokens: 8000,
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	if captured["max_tokens"] != float64(2048) || captured["temperature"] != float64(1) {
+		t.Errorf("expected clamped values in request, got %v", captured)
+	}
+	messages := captured["messages"].([]any)
+	if len(messages) != 1 || !strings.HasPrefix(messages[0].(map[string]any)["content"].(string), "sys\n\nhi") {
+		t.Errorf("expected system prompt folded into the user message, got %v", messages)
+	}
+
+	adjustments, _ := resp.Metadata["request_adjustments"].([]string)
+	if len(adjustments) != 3 {
+		t.Errorf("expected 3 adjustments in metadata, got %v", resp.Metadata)
+	}
+}
+
+func TestRegistry_WrapsProviders(t *testing.T) {
+	r := NewRegistry(&config.Config{OpenAIAPIKey: "test-key"})
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	p, ok := r.GetProvider(types.ProviderOpenAI)
+	if !ok {
+		t.Fatal("expected OpenAI provider")
+	}
+	if _, ok := p.(*managedProvider); !ok {
+		t.Errorf("expected registry to hand out managed providers, got %T", p)
+	}
+	if _, ok := unwrapProvider(p).(ModelDiscoverer); !ok {
+		t.Error("expected unwrapped provider to support discovery")
+	}
+}
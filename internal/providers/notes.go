@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// CallNotes collects what a tool result should say about how its model
+// calls were served: why models were auto-selected and what context was
+// trimmed to fit them
+type CallNotes struct {
+	routing []string // explanations of auto-selected models
+	trimmed []string // reports of context trimmed to fit a model
+	mu      sync.Mutex
+}
+
+type callNotesKey struct{}
+
+// WithCallNotes returns a context that collects notes on the model calls
+// made with it
+func WithCallNotes(ctx context.Context) (context.Context, *CallNotes) {
+	notes := &CallNotes{}
+	return context.WithValue(ctx, callNotesKey{}, notes), notes
+}
+
+// callNotesFrom returns the collector in ctx, or nil
+func callNotesFrom(ctx context.Context) *CallNotes {
+	notes, _ := ctx.Value(callNotesKey{}).(*CallNotes)
+	return notes
+}
+
+// noteRouting records why a model was auto-selected
+func (n *CallNotes) noteRouting(explanation string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.routing = append(n.routing, explanation)
+}
+
+// Routing returns the explanations of models auto-selected during the call
+func (n *CallNotes) Routing() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.routing)
+}
+
+// NoteContextTrimmed records that a request was trimmed to fit a model's
+// context window, for listing with the tool result
+func NoteContextTrimmed(ctx context.Context, report string) {
+	n := callNotesFrom(ctx)
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.trimmed = append(n.trimmed, report)
+}
+
+// ContextTrimmed returns the reports of context trimmed during the call
+func (n *CallNotes) ContextTrimmed() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.trimmed)
+}
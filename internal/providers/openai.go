@@ -25,15 +25,16 @@ func NewOpenAIProvider(cfg *config.Config) (*OpenAIProvider, error) {
 		models = defaultOpenAIModels()
 	}
 
-	return &OpenAIProvider{
-		OpenAICompatProvider: NewOpenAICompatProvider(
-			types.ProviderOpenAI,
-			cfg.OpenAIAPIKey,
-			openAIBaseURL,
-			models,
-			5*time.Minute,
-		),
-	}, nil
+	compat := NewOpenAICompatProvider(
+		types.ProviderOpenAI,
+		cfg.OpenAIAPIKey,
+		openAIBaseURL,
+		models,
+		5*time.Minute,
+	)
+	compat.storeResponses = cfg.OpenAIResponsesStore
+
+	return &OpenAIProvider{OpenAICompatProvider: compat}, nil
 }
 
 func defaultOpenAIModels() []types.ModelCapabilities {
@@ -51,6 +52,8 @@ func defaultOpenAIModels() []types.ModelCapabilities {
 			SupportsStreaming:        true,
 			SupportsVision:           true,
 			AllowCodeGeneration:      true,
+			SupportsJSONSchema:       true,
+			SupportsFunctionCalling:  true,
 		},
 		{
 			Provider:                 types.ProviderOpenAI,
@@ -65,6 +68,8 @@ func defaultOpenAIModels() []types.ModelCapabilities {
 			SupportsStreaming:        true,
 			SupportsVision:           true,
 			AllowCodeGeneration:      true,
+			SupportsJSONSchema:       true,
+			SupportsFunctionCalling:  true,
 		},
 		{
 			Provider:                 types.ProviderOpenAI,
@@ -79,6 +84,18 @@ func defaultOpenAIModels() []types.ModelCapabilities {
 			SupportsStreaming:        true,
 			SupportsVision:           true,
 			AllowCodeGeneration:      true,
+			SupportsJSONSchema:       true,
+			SupportsFunctionCalling:  true,
+		},
+		{
+			Provider:            types.ProviderOpenAI,
+			ModelName:           "text-embedding-3-small",
+			FriendlyName:        "Text Embedding 3 Small",
+			IntelligenceScore:   50,
+			ContextWindow:       8191,
+			Embedding:           true,
+			EmbeddingDimensions: 1536,
+			InputPricePerMTok:   0.02,
 		},
 	}
 }
@@ -6,17 +6,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	    "net/http"
-	    "time"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/types"
-	)
-	// OpenAICompatProvider is a base for OpenAI-compatible APIs
+	"net/http"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+// OpenAICompatProvider is a base for OpenAI-compatible APIs
 type OpenAICompatProvider struct {
 	*BaseProvider
 	apiKey     string
 	baseURL    string
+	authHeader string // empty sends no credentials
+	authPrefix string
 	httpClient *http.Client
+	responses  *responseChain
+
+	// storeResponses stores Responses API calls so they can be chained;
+	// a "store" provider option overrides it per request or model
+	storeResponses bool
 }
 
 // NewOpenAICompatProvider creates a new OpenAI-compatible provider
@@ -31,25 +41,46 @@ func NewOpenAICompatProvider(
 		BaseProvider: NewBaseProvider(pt, models),
 		apiKey:       apiKey,
 		baseURL:      baseURL,
+		authHeader:   "Authorization",
+		authPrefix:   "Bearer ",
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
+		responses: newResponseChain(),
 	}
 }
 
 func (p *OpenAICompatProvider) IsConfigured() bool {
-	return p.apiKey != ""
+	return p.apiKey != "" || p.authHeader == ""
+}
+
+// setAuth adds the API key using the provider's auth header style
+func (p *OpenAICompatProvider) setAuth(httpReq *http.Request) {
+	if p.authHeader != "" {
+		httpReq.Header.Set(p.authHeader, p.authPrefix+p.apiKey)
+	}
 }
 
-func (p *OpenAICompatProvider) CountTokens(text string, modelName string) (int, error) {
-	// Rough estimate: 4 chars per token
-	return len(text) / 4, nil
+func (p *OpenAICompatProvider) CountTokens(ctx context.Context, text string, modelName string) (int, error) {
+	return tokenizer.CountTokens(text, p.ResolveModelName(modelName)), nil
 }
 
 // GenerateContent calls an OpenAI-compatible API
 func (p *OpenAICompatProvider) GenerateContent(ctx context.Context, req *GenerateRequest) (*types.ModelResponse, error) {
 	modelName := p.ResolveModelName(req.Model)
 
+	if err := p.CheckAllowed(modelName); err != nil {
+		return nil, err
+	}
+	if err := p.CheckVision(modelName, req); err != nil {
+		return nil, err
+	}
+
+	caps, _ := p.GetCapabilities(modelName)
+	if caps != nil && caps.API == types.APIResponses {
+		return p.generateResponse(ctx, modelName, caps, req)
+	}
+
 	// Build messages
 	messages := p.buildMessages(req)
 
@@ -59,12 +90,10 @@ func (p *OpenAICompatProvider) GenerateContent(ctx context.Context, req *Generat
 		"messages": messages,
 	}
 
-	if req.Temperature > 0 {
-		body["temperature"] = req.Temperature
-	}
-	if req.MaxOutputTokens > 0 {
-		body["max_tokens"] = req.MaxOutputTokens
-	}
+	applySamplingParams(body, modelName, caps, req)
+	applyResponseFormat(body, req)
+	applyTools(body, req)
+	applyProviderOptions(body, req.ProviderOptions)
 
 	// Make request
 	url := p.baseURL + "/chat/completions"
@@ -79,7 +108,7 @@ func (p *OpenAICompatProvider) GenerateContent(ctx context.Context, req *Generat
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	p.setAuth(httpReq)
 
 	resp, err := p.httpClient.Do(httpReq)
 	if err != nil {
@@ -93,7 +122,7 @@ func (p *OpenAICompatProvider) GenerateContent(ctx context.Context, req *Generat
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(respBody))
+		return nil, ErrAPIError{Provider: p.providerType, StatusCode: resp.StatusCode, Message: string(respBody)}
 	}
 
 	// Parse response
@@ -127,10 +156,38 @@ func (p *OpenAICompatProvider) buildMessages(req *GenerateRequest) []map[string]
 	// Add current prompt
 	messages = append(messages, map[string]any{
 		"role":    "user",
-		"content": req.Prompt,
+		"content": buildUserContent(req.Prompt, req.Images),
 	})
 
-	return messages
+	return appendToolMessages(messages, req.ToolTurns)
+}
+
+// buildUserContent returns the prompt as plain text, or as a multi-part content
+// array with image_url data URIs when images are attached
+func buildUserContent(prompt string, images []string) any {
+	if len(images) == 0 {
+		return prompt
+	}
+
+	// Supports file paths, data URIs, and base64
+	processedImages := utils.ProcessImages(images)
+	if len(processedImages) == 0 {
+		return prompt
+	}
+
+	parts := []map[string]any{
+		{"type": "text", "text": prompt},
+	}
+	for _, imgData := range processedImages {
+		parts = append(parts, map[string]any{
+			"type": "image_url",
+			"image_url": map[string]any{
+				"url": imgData.DataURI(),
+			},
+		})
+	}
+
+	return parts
 }
 
 func (p *OpenAICompatProvider) parseResponse(model string, resp *openAIResponse) (*types.ModelResponse, error) {
@@ -145,10 +202,14 @@ func (p *OpenAICompatProvider) parseResponse(model string, resp *openAIResponse)
 		Model:        model,
 		Provider:     p.providerType,
 		FinishReason: choice.FinishReason,
+		ToolCalls:    parseToolCalls(choice.Message.ToolCalls),
+		Alternatives: resp.alternatives(),
 		TokensUsed: types.TokenUsage{
 			PromptTokens:     resp.Usage.PromptTokens,
 			CompletionTokens: resp.Usage.CompletionTokens,
 			TotalTokens:      resp.Usage.TotalTokens,
+			ThinkingTokens:   resp.Usage.CompletionTokensDetails.ReasoningTokens,
+			CachedTokens:     resp.Usage.PromptTokensDetails.CachedTokens,
 		},
 	}, nil
 }
@@ -160,6 +221,16 @@ type openAIResponse struct {
 	Usage   openAIUsage    `json:"usage"`
 }
 
+// alternatives returns the content of the choices after the first, which
+// come back when more than one completion was requested
+func (r *openAIResponse) alternatives() []string {
+	var alts []string
+	for _, c := range r.Choices[1:] {
+		alts = append(alts, c.Message.Content)
+	}
+	return alts
+}
+
 type openAIChoice struct {
 	Index        int           `json:"index"`
 	Message      openAIMessage `json:"message"`
@@ -167,12 +238,30 @@ type openAIChoice struct {
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type openAIUsage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
 }
@@ -0,0 +1,198 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// 1x1 PNG as a data URI
+const testImageURI = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAIAAACQd1PeAAAADElEQVR4nGP4z8AAAAMBAQDJ/pLvAAAAAElFTkSuQmCC"
+
+func TestOpenAICompatProvider_ImageContent(t *testing.T) {
+	var capturedBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&capturedBody)
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Role: "assistant", Content: "a red pixel"}}},
+		})
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "vision-model", SupportsVision: true},
+	}, time.Minute)
+
+	_, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt: "What is this?",
+		Model:  "vision-model",
+		Images: []string{testImageURI},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	messages := capturedBody["messages"].([]any)
+	last := messages[len(messages)-1].(map[string]any)
+	parts, ok := last["content"].([]any)
+	if !ok {
+		t.Fatalf("expected multi-part content, got %T", last["content"])
+	}
+	if len(parts) != 2 {
+		t.Fatalf("expected text and image parts, got %d", len(parts))
+	}
+
+	text := parts[0].(map[string]any)
+	if text["type"] != "text" || text["text"] != "What is this?" {
+		t.Errorf("unexpected text part: %v", text)
+	}
+
+	image := parts[1].(map[string]any)
+	if image["type"] != "image_url" {
+		t.Errorf("unexpected image part type: %v", image["type"])
+	}
+	if url := image["image_url"].(map[string]any)["url"]; url != testImageURI {
+		t.Errorf("unexpected image url: %v", url)
+	}
+}
+
+func TestOpenAICompatProvider_TextOnlyContent(t *testing.T) {
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", "http://unused", nil, time.Minute)
+
+	messages := p.buildMessages(&GenerateRequest{Prompt: "hello"})
+	if content, ok := messages[0]["content"].(string); !ok || content != "hello" {
+		t.Errorf("expected plain string content without images, got %v", messages[0]["content"])
+	}
+}
+
+func TestOpenAICompatProvider_RejectsImagesWithoutVision(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach the API")
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderCustom, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "text-only"},
+	}, time.Minute)
+
+	_, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt: "What is this?",
+		Model:  "text-only",
+		Images: []string{testImageURI},
+	})
+
+	var visionErr ErrVisionNotSupported
+	if !errors.As(err, &visionErr) {
+		t.Fatalf("expected ErrVisionNotSupported, got %v", err)
+	}
+	if visionErr.Model != "text-only" {
+		t.Errorf("unexpected model in error: %s", visionErr.Model)
+	}
+}
+
+func TestOpenAICompatProvider_ReasoningModel(t *testing.T) {
+	var capturedBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&capturedBody)
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "done"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 50, "total_tokens": 60,
+			          "completion_tokens_details": {"reasoning_tokens": 42}}
+		}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "o3", SupportsExtendedThinking: true},
+	}, time.Minute)
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:          "think",
+		Model:           "o3",
+		Temperature:     0.3,
+		MaxOutputTokens: 2000,
+		ThinkingMode:    types.ThinkingMax,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if capturedBody["reasoning_effort"] != "high" {
+		t.Errorf("expected reasoning_effort high, got %v", capturedBody["reasoning_effort"])
+	}
+	if _, ok := capturedBody["temperature"]; ok {
+		t.Error("temperature should be dropped for reasoning models")
+	}
+	if _, ok := capturedBody["max_tokens"]; ok {
+		t.Error("max_tokens should not be sent to reasoning models")
+	}
+	if capturedBody["max_completion_tokens"] != float64(2000) {
+		t.Errorf("expected max_completion_tokens 2000, got %v", capturedBody["max_completion_tokens"])
+	}
+	if resp.TokensUsed.ThinkingTokens != 42 {
+		t.Errorf("expected 42 thinking tokens, got %d", resp.TokensUsed.ThinkingTokens)
+	}
+}
+
+func TestApplySamplingParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		caps  *types.ModelCapabilities
+		req   GenerateRequest
+		want  map[string]any
+	}{
+		{
+			name:  "standard model keeps sampling params",
+			model: "gpt-4.1",
+			caps:  &types.ModelCapabilities{},
+			req:   GenerateRequest{Temperature: 0.5, MaxOutputTokens: 100, ThinkingMode: types.ThinkingHigh},
+			want:  map[string]any{"temperature": 0.5, "max_tokens": 100},
+		},
+		{
+			name:  "gpt-5 minimal effort",
+			model: "gpt-5.1",
+			caps:  &types.ModelCapabilities{SupportsExtendedThinking: true},
+			req:   GenerateRequest{Temperature: 0.7, ThinkingMode: types.ThinkingMinimal},
+			want:  map[string]any{"reasoning_effort": "minimal"},
+		},
+		{
+			name:  "o-series has no minimal effort",
+			model: "openai/o4-mini",
+			caps:  &types.ModelCapabilities{SupportsExtendedThinking: true},
+			req:   GenerateRequest{ThinkingMode: types.ThinkingMinimal},
+			want:  map[string]any{"reasoning_effort": "low"},
+		},
+		{
+			name:  "no thinking mode sends no effort",
+			model: "o3",
+			caps:  &types.ModelCapabilities{SupportsExtendedThinking: true},
+			req:   GenerateRequest{MaxOutputTokens: 10},
+			want:  map[string]any{"max_completion_tokens": 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]any{}
+			applySamplingParams(body, tt.model, tt.caps, &tt.req)
+
+			if len(body) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, body)
+			}
+			for k, v := range tt.want {
+				if body[k] != v {
+					t.Errorf("%s: expected %v, got %v", k, v, body[k])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+// chainTTL bounds how long a thread's last response ID is kept
+const chainTTL = 24 * time.Hour
+
+// responseChain remembers the last Responses API response per thread so
+// follow-up calls can send previous_response_id instead of the full history
+type responseChain struct {
+	mu      sync.Mutex
+	entries map[string]chainEntry
+}
+
+type chainEntry struct {
+	responseID string
+	model      string
+	turns      int // history length expected on the next call in this thread
+	updated    time.Time
+}
+
+func newResponseChain() *responseChain {
+	return &responseChain{entries: make(map[string]chainEntry)}
+}
+
+// previous returns the response ID to chain from, if the thread's history
+// hasn't changed since that response (no other model or tool added turns)
+func (c *responseChain) previous(threadID, model string, historyLen int) string {
+	if threadID == "" {
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[threadID]
+	if !ok || entry.model != model || entry.turns != historyLen {
+		return ""
+	}
+	return entry.responseID
+}
+
+// forget drops the thread's chain after a response that wasn't stored
+func (c *responseChain) forget(threadID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, threadID)
+}
+
+// record stores a response for the thread. The calling tool appends the user
+// and assistant turns afterwards, so the next call sees two more turns.
+func (c *responseChain) record(threadID, model, responseID string, historyLen int) {
+	if threadID == "" || responseID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, entry := range c.entries {
+		if now.Sub(entry.updated) > chainTTL {
+			delete(c.entries, id)
+		}
+	}
+
+	c.entries[threadID] = chainEntry{
+		responseID: responseID,
+		model:      model,
+		turns:      historyLen + 2,
+		updated:    now,
+	}
+}
+
+// generateResponse calls the /responses endpoint for models that aren't
+// served by chat completions
+func (p *OpenAICompatProvider) generateResponse(
+	ctx context.Context,
+	modelName string,
+	caps *types.ModelCapabilities,
+	req *GenerateRequest,
+) (*types.ModelResponse, error) {
+	// Only stored responses can be chained from. Tool rounds are sent in
+	// full, so only the first call of a request chains.
+	store := p.storeResponses
+	if v, ok := req.ProviderOptions["store"].(bool); ok {
+		store = v
+	}
+	var previousID string
+	if store && len(req.ToolTurns) == 0 {
+		previousID = p.responses.previous(req.ThreadID, modelName, len(req.ConversationHistory))
+	}
+
+	body := map[string]any{
+		"model": modelName,
+		"input": p.buildResponseInput(req, previousID != ""),
+		"store": store,
+	}
+
+	if previousID != "" {
+		body["previous_response_id"] = previousID
+	}
+	if req.SystemPrompt != "" {
+		body["instructions"] = req.SystemPrompt
+	}
+	if req.MaxOutputTokens > 0 {
+		body["max_output_tokens"] = req.MaxOutputTokens
+	}
+	if !isOpenAIReasoningModel(modelName) {
+		if req.Temperature > 0 {
+			body["temperature"] = req.Temperature
+		}
+		if req.Sampling.TopP != nil {
+			body["top_p"] = *req.Sampling.TopP
+		}
+	}
+	if req.ResponseSchema != nil {
+		body["text"] = map[string]any{
+			"format": map[string]any{
+				"type":   "json_schema",
+				"name":   schemaName(req.ResponseSchema),
+				"schema": req.ResponseSchema.Schema,
+				"strict": req.ResponseSchema.Strict,
+			},
+		}
+	}
+	applyResponsesTools(body, req)
+	if caps.SupportsExtendedThinking {
+		reasoning := map[string]any{"summary": "auto"}
+		if effort := reasoningEffort(req.ThinkingMode, modelName); effort != "" {
+			reasoning["effort"] = effort
+		}
+		body["reasoning"] = reasoning
+	}
+	applyProviderOptions(body, req.ProviderOptions)
+
+	url := p.baseURL + "/responses"
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	p.setAuth(httpReq)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrAPIError{Provider: p.providerType, StatusCode: resp.StatusCode, Message: string(respBody)}
+	}
+
+	var rResp responsesResponse
+	if err := json.Unmarshal(respBody, &rResp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+
+	result, err := p.parseResponsesResponse(modelName, &rResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if store {
+		p.responses.record(req.ThreadID, modelName, rResp.ID, len(req.ConversationHistory))
+	} else {
+		p.responses.forget(req.ThreadID)
+	}
+	if previousID != "" {
+		result.Metadata["previous_response_id"] = previousID
+	}
+
+	return result, nil
+}
+
+// buildResponseInput converts the request into Responses API input items.
+// When chaining from a previous response, the server already holds the
+// history and only the new user message is sent.
+func (p *OpenAICompatProvider) buildResponseInput(req *GenerateRequest, chained bool) []map[string]any {
+	var input []map[string]any
+
+	if !chained {
+		for _, turn := range req.ConversationHistory {
+			input = append(input, map[string]any{
+				"role":    turn.Role,
+				"content": turn.Content,
+			})
+		}
+	}
+
+	content := []map[string]any{
+		{"type": "input_text", "text": req.Prompt},
+	}
+	for _, imgData := range utils.ProcessImages(req.Images) {
+		content = append(content, map[string]any{
+			"type":      "input_image",
+			"image_url": imgData.DataURI(),
+		})
+	}
+
+	input = append(input, map[string]any{
+		"role":    "user",
+		"content": content,
+	})
+
+	return append(input, responsesToolItems(req.ToolTurns)...)
+}
+
+func (p *OpenAICompatProvider) parseResponsesResponse(model string, resp *responsesResponse) (*types.ModelResponse, error) {
+	if resp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+
+	var content strings.Builder
+	var summaries []string
+	var calls []types.ToolCall
+
+	for _, item := range resp.Output {
+		switch item.Type {
+		case "message":
+			for _, part := range item.Content {
+				if part.Type == "output_text" {
+					content.WriteString(part.Text)
+				}
+			}
+		case "function_call":
+			calls = append(calls, types.ToolCall{
+				ID:        item.CallID,
+				Name:      item.Name,
+				Arguments: item.Arguments,
+			})
+		case "reasoning":
+			for _, s := range item.Summary {
+				if s.Text != "" {
+					summaries = append(summaries, s.Text)
+				}
+			}
+		}
+	}
+
+	if content.Len() == 0 && len(calls) == 0 && resp.Status == "failed" {
+		return nil, fmt.Errorf("response %s failed", resp.ID)
+	}
+
+	finishReason := "stop"
+	if resp.Status == "incomplete" {
+		finishReason = "incomplete"
+		if resp.IncompleteDetails != nil && resp.IncompleteDetails.Reason != "" {
+			finishReason = resp.IncompleteDetails.Reason
+		}
+	}
+
+	metadata := map[string]any{
+		"response_id": resp.ID,
+		"api":         types.APIResponses,
+	}
+	if len(summaries) > 0 {
+		metadata["reasoning_summary"] = strings.Join(summaries, "\n\n")
+	}
+
+	return &types.ModelResponse{
+		Content:      content.String(),
+		Model:        model,
+		Provider:     p.providerType,
+		FinishReason: finishReason,
+		ToolCalls:    calls,
+		TokensUsed: types.TokenUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+			ThinkingTokens:   resp.Usage.OutputTokensDetails.ReasoningTokens,
+			CachedTokens:     resp.Usage.InputTokensDetails.CachedTokens,
+		},
+		Metadata: metadata,
+	}, nil
+}
+
+// Responses API response types
+type responsesResponse struct {
+	ID                string                `json:"id"`
+	Status            string                `json:"status"`
+	Output            []responsesOutputItem `json:"output"`
+	Usage             responsesUsage        `json:"usage"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type responsesOutputItem struct {
+	Type    string `json:"type"`
+	Role    string `json:"role,omitempty"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content,omitempty"`
+	Summary []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"summary,omitempty"`
+
+	// Function call items
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type responsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+
+	OutputTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
@@ -0,0 +1,213 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestOpenAICompatProvider_ResponsesAPI(t *testing.T) {
+	var paths []string
+	var bodies []map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, body)
+
+		id := "resp_1"
+		if len(bodies) > 1 {
+			id = "resp_2"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":     id,
+			"status": "completed",
+			"output": []map[string]any{
+				{"type": "reasoning", "summary": []map[string]any{{"type": "summary_text", "text": "Considered options."}}},
+				{"type": "message", "role": "assistant", "content": []map[string]any{{"type": "output_text", "text": "Answer"}}},
+			},
+			"usage": map[string]any{
+				"input_tokens": 20, "output_tokens": 30, "total_tokens": 50,
+				"output_tokens_details": map[string]any{"reasoning_tokens": 12},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "gpt-5.2-pro", API: types.APIResponses, SupportsExtendedThinking: true},
+	}, time.Minute)
+	p.storeResponses = true
+
+	history := []types.ConversationTurn{{Role: "user", Content: "earlier question"}, {Role: "assistant", Content: "earlier answer"}}
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:              "first",
+		SystemPrompt:        "be precise",
+		Model:               "gpt-5.2-pro",
+		Temperature:         0.5,
+		ThinkingMode:        types.ThinkingHigh,
+		ConversationHistory: history,
+		ThreadID:            "thread-1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if paths[0] != "/responses" {
+		t.Errorf("expected /responses endpoint, got %s", paths[0])
+	}
+	first := bodies[0]
+	if first["instructions"] != "be precise" {
+		t.Errorf("expected system prompt as instructions, got %v", first["instructions"])
+	}
+	if _, ok := first["temperature"]; ok {
+		t.Error("temperature should be dropped for reasoning models")
+	}
+	if effort := first["reasoning"].(map[string]any)["effort"]; effort != "high" {
+		t.Errorf("expected reasoning effort high, got %v", effort)
+	}
+	if first["store"] != true {
+		t.Errorf("expected the response stored for chaining, got %v", first["store"])
+	}
+	if _, ok := first["previous_response_id"]; ok {
+		t.Error("first call should not chain")
+	}
+	if n := len(first["input"].([]any)); n != 3 {
+		t.Errorf("expected history plus prompt (3 items), got %d", n)
+	}
+
+	if resp.Content != "Answer" || resp.FinishReason != "stop" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Metadata["reasoning_summary"] != "Considered options." {
+		t.Errorf("unexpected reasoning summary: %v", resp.Metadata["reasoning_summary"])
+	}
+	if resp.Metadata["response_id"] != "resp_1" {
+		t.Errorf("unexpected response id: %v", resp.Metadata["response_id"])
+	}
+	if resp.TokensUsed.PromptTokens != 20 || resp.TokensUsed.ThinkingTokens != 12 {
+		t.Errorf("unexpected usage: %+v", resp.TokensUsed)
+	}
+
+	// The tool records the user and assistant turns, then continues the thread
+	history = append(history,
+		types.ConversationTurn{Role: "user", Content: "first"},
+		types.ConversationTurn{Role: "assistant", Content: "Answer"},
+	)
+
+	_, err = p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:              "second",
+		Model:               "gpt-5.2-pro",
+		ConversationHistory: history,
+		ThreadID:            "thread-1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	second := bodies[1]
+	if second["previous_response_id"] != "resp_1" {
+		t.Errorf("expected chaining from resp_1, got %v", second["previous_response_id"])
+	}
+	if n := len(second["input"].([]any)); n != 1 {
+		t.Errorf("chained call should only send the new prompt, got %d items", n)
+	}
+
+	// A turn added by another tool breaks the chain and resends history
+	history = append(history, types.ConversationTurn{Role: "assistant", Content: "from another model"})
+
+	_, err = p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:              "third",
+		Model:               "gpt-5.2-pro",
+		ConversationHistory: history,
+		ThreadID:            "thread-1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	third := bodies[2]
+	if _, ok := third["previous_response_id"]; ok {
+		t.Error("expected chain to break after an external turn")
+	}
+	if n := len(third["input"].([]any)); n != len(history)+1 {
+		t.Errorf("expected full history to be resent, got %d items", n)
+	}
+}
+
+func TestOpenAICompatProvider_ResponsesStore(t *testing.T) {
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":     fmt.Sprintf("resp_%d", len(bodies)),
+			"status": "completed",
+			"output": []map[string]any{
+				{"type": "message", "role": "assistant", "content": []map[string]any{{"type": "output_text", "text": "ok"}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "gpt-5.2-pro", API: types.APIResponses},
+	}, time.Minute)
+
+	var history []types.ConversationTurn
+	call := func(opts map[string]any) map[string]any {
+		t.Helper()
+		if _, err := p.GenerateContent(context.Background(), &GenerateRequest{
+			Prompt: "q", Model: "gpt-5.2-pro", ConversationHistory: history, ThreadID: "thread-1", ProviderOptions: opts,
+		}); err != nil {
+			t.Fatalf("GenerateContent failed: %v", err)
+		}
+		history = append(history, types.ConversationTurn{Role: "user", Content: "q"}, types.ConversationTurn{Role: "assistant", Content: "ok"})
+		return bodies[len(bodies)-1]
+	}
+
+	// Not stored by default, so nothing to chain from
+	call(nil)
+	if second := call(nil); second["store"] != false || second["previous_response_id"] != nil {
+		t.Errorf("expected an unstored, unchained call, got store=%v previous=%v", second["store"], second["previous_response_id"])
+	}
+
+	// A store option opts in, and the next stored call chains from it
+	call(map[string]any{"store": true})
+	if next := call(map[string]any{"store": true}); next["previous_response_id"] != "resp_3" {
+		t.Errorf("expected chaining from the stored response, got %v", next["previous_response_id"])
+	}
+
+	// Turning storage off again breaks the chain
+	call(nil)
+	if next := call(map[string]any{"store": true}); next["previous_response_id"] != nil {
+		t.Errorf("expected no chaining after an unstored call, got %v", next["previous_response_id"])
+	}
+}
+
+func TestOpenAICompatProvider_ResponsesIncomplete(t *testing.T) {
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", "http://unused", nil, time.Minute)
+
+	resp, err := p.parseResponsesResponse("gpt-5.2-pro", &responsesResponse{
+		ID:     "resp_x",
+		Status: "incomplete",
+		IncompleteDetails: &struct {
+			Reason string `json:"reason"`
+		}{Reason: "max_output_tokens"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.FinishReason != "max_output_tokens" {
+		t.Errorf("expected finish reason max_output_tokens, got %s", resp.FinishReason)
+	}
+}
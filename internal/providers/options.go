@@ -0,0 +1,74 @@
+package providers
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// reservedProviderOptions are request body fields relay builds itself. No
+// allowlist lets provider options replace them.
+var reservedProviderOptions = []string{"model", "messages", "input", "contents", "tools"}
+
+// checkProviderOptions rejects reserved option keys and keys that aren't on
+// the provider's allowlist
+func checkProviderOptions(opts map[string]any, allowed []string, model string, pt types.ProviderType) error {
+	for _, key := range slices.Sorted(maps.Keys(opts)) {
+		if slices.Contains(reservedProviderOptions, key) {
+			return ErrInvalidRequest{Model: model, Field: "provider_options", Reason: fmt.Sprintf("%q is set by relay and cannot be overridden", key)}
+		}
+	}
+	if slices.Contains(allowed, config.AnyProviderOption) {
+		return nil
+	}
+	for _, key := range slices.Sorted(maps.Keys(opts)) {
+		if slices.Contains(allowed, key) {
+			continue
+		}
+		reason := fmt.Sprintf("%q is not allowed for %s", key, pt)
+		if len(allowed) > 0 {
+			reason += " (allowed: " + strings.Join(allowed, ", ") + ")"
+		}
+		return ErrInvalidRequest{Model: model, Field: "provider_options", Reason: reason + "; add it to allowed_provider_options in relay.json"}
+	}
+	return nil
+}
+
+// CheckProviderOptions returns the error a provider would give for opts:
+// reserved keys, or keys not on its allowlist
+func (r *Registry) CheckProviderOptions(pt types.ProviderType, opts map[string]any, model string) error {
+	return checkProviderOptions(opts, r.cfg.AllowedProviderOptions(pt), model, pt)
+}
+
+// mergeProviderOptions returns base overlaid with overrides, without
+// modifying either
+func mergeProviderOptions(base, overrides map[string]any) map[string]any {
+	if len(base) == 0 {
+		return overrides
+	}
+	merged := maps.Clone(base)
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+// applyProviderOptions merges provider options into a request body. An
+// option that is an object merges into an object field of the same name, so
+// {"generationConfig": {"topK": 40}} keeps the rest of Gemini's generation
+// config; anything else replaces the field.
+func applyProviderOptions(body map[string]any, opts map[string]any) {
+	for key, value := range opts {
+		existing, ok1 := body[key].(map[string]any)
+		extra, ok2 := value.(map[string]any)
+		if ok1 && ok2 {
+			merged := maps.Clone(existing)
+			maps.Copy(merged, extra)
+			body[key] = merged
+			continue
+		}
+		body[key] = value
+	}
+}
@@ -0,0 +1,149 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestManagedProvider_SamplingAndProviderOptions(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = nil
+		json.NewDecoder(r.Body).Decode(&captured)
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"first"}},{"index":1,"message":{"content":"second"}}]}`)
+	}))
+	defer server.Close()
+
+	seed := 7
+	r := NewRegistry(&config.Config{
+		ProviderOptionAllowlist: map[types.ProviderType][]string{types.ProviderOpenRouter: {"reasoning"}},
+	})
+	p := &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderOpenRouter, "key", server.URL, []types.ModelCapabilities{
+			{Provider: types.ProviderOpenRouter, ModelName: "llama", Defaults: &types.ModelDefaults{
+				SamplingParams:  types.SamplingParams{TopP: floatPtr(0.9), Seed: &seed},
+				ProviderOptions: map[string]any{"provider": map[string]any{"sort": "price"}, "top_k": 40},
+			}},
+			{Provider: types.ProviderOpenRouter, ModelName: "openai/o3"},
+		}, 0),
+		registry: r,
+	}
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt: "hi",
+		Model:  "llama",
+		Sampling: types.SamplingParams{
+			TopP:             floatPtr(0.5),
+			Stop:             []string{"END"},
+			FrequencyPenalty: floatPtr(3),
+			N:                2,
+		},
+		ProviderOptions: map[string]any{"top_k": 20, "reasoning": map[string]any{"effort": "low"}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	want := map[string]any{
+		"top_p":             0.5,        // request over model default
+		"seed":              float64(7), // model default
+		"frequency_penalty": float64(2), // clamped
+		"n":                 float64(2),
+		"top_k":             float64(20),
+	}
+	for key, value := range want {
+		if captured[key] != value {
+			t.Errorf("expected %s = %v, got %v", key, value, captured[key])
+		}
+	}
+	if stop, _ := captured["stop"].([]any); len(stop) != 1 {
+		t.Errorf("expected stop sequences, got %v", captured["stop"])
+	}
+	if provider, _ := captured["provider"].(map[string]any); provider["sort"] != "price" {
+		t.Errorf("expected the default provider routing, got %v", captured["provider"])
+	}
+	if len(resp.Alternatives) != 1 || resp.Alternatives[0] != "second" {
+		t.Errorf("expected the second choice as an alternative, got %v", resp.Alternatives)
+	}
+	if adj, _ := resp.Metadata["request_adjustments"].([]string); len(adj) != 1 || !strings.HasPrefix(adj[0], "frequency_penalty") {
+		t.Errorf("expected the clamp to be reported, got %v", resp.Metadata)
+	}
+
+	// Reasoning models don't take top_p or penalties
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt: "hi", Model: "openai/o3",
+		Sampling: types.SamplingParams{TopP: floatPtr(0.5), Seed: &seed},
+	}); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if _, ok := captured["top_p"]; ok || captured["seed"] != float64(7) {
+		t.Errorf("expected only seed for a reasoning model, got %v", captured)
+	}
+
+	var invalid ErrInvalidRequest
+	_, err = p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt: "hi", Model: "llama", ProviderOptions: map[string]any{"messages": []any{}},
+	})
+	if !errors.As(err, &invalid) || invalid.Field != "provider_options" || !strings.Contains(err.Error(), `"messages"`) {
+		t.Errorf("expected an option off the allowlist to be rejected, got %v", err)
+	}
+
+	// Reserved fields are rejected even when any option is allowed
+	if err := checkProviderOptions(map[string]any{"model": "other"}, []string{config.AnyProviderOption}, "llama", types.ProviderCustom); !errors.As(err, &invalid) {
+		t.Errorf("expected a reserved option to be rejected, got %v", err)
+	}
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt: "hi", Model: "llama", ProviderOptions: map[string]any{"models": []any{"openai/o3"}},
+	}); !errors.As(err, &invalid) {
+		t.Errorf("expected OpenRouter fallback models to be off the default allowlist, got %v", err)
+	}
+}
+
+func TestGeminiProvider_SamplingAndProviderOptions(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"a"}]}},{"content":{"parts":[{"text":"b"}]}}]}`)
+	}))
+	defer server.Close()
+
+	p, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "key"})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.baseURL = server.URL
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:          "hi",
+		Model:           "gemini-2.5-flash",
+		Temperature:     0.4,
+		Sampling:        types.SamplingParams{TopP: floatPtr(0.8), Stop: []string{"END"}, N: 2},
+		ProviderOptions: map[string]any{"generationConfig": map[string]any{"topK": 40}, "labels": map[string]any{"team": "infra"}},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	gen, _ := captured["generationConfig"].(map[string]any)
+	if gen["topP"] != 0.8 || gen["topK"] != float64(40) || gen["temperature"] != 0.4 || gen["candidateCount"] != float64(2) {
+		t.Errorf("expected sampling and options merged into generationConfig, got %v", gen)
+	}
+	if stop, _ := gen["stopSequences"].([]any); len(stop) != 1 {
+		t.Errorf("expected stop sequences, got %v", gen["stopSequences"])
+	}
+	if labels, _ := captured["labels"].(map[string]any); labels["team"] != "infra" {
+		t.Errorf("expected labels at the top level, got %v", captured["labels"])
+	}
+	if resp.Content != "a" || len(resp.Alternatives) != 1 || resp.Alternatives[0] != "b" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
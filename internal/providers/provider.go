@@ -1,11 +1,15 @@
 package providers
 
 import (
-	    "context"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/types"
-	)
-	// Provider is the interface for AI model providers
+	"context"
+	"sync"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Provider is the interface for AI model providers
 type Provider interface {
 	// GetProviderType returns the provider type
 	GetProviderType() types.ProviderType
@@ -20,7 +24,7 @@ type Provider interface {
 	GetCapabilities(modelName string) (*types.ModelCapabilities, error)
 
 	// CountTokens estimates token count for text
-	CountTokens(text string, modelName string) (int, error)
+	CountTokens(ctx context.Context, text string, modelName string) (int, error)
 
 	// SupportsModel checks if provider can handle this model
 	SupportsModel(modelName string) bool
@@ -29,6 +33,25 @@ type Provider interface {
 	IsConfigured() bool
 }
 
+// ModelDiscoverer is implemented by providers that can list models from their API
+type ModelDiscoverer interface {
+	// RefreshModels fetches the remote model list and merges unknown models,
+	// returning the number of models added
+	RefreshModels(ctx context.Context) (int, error)
+}
+
+// TokenCounter adapts a provider's CountTokens for budgeting code, falling
+// back to a local estimate if the provider can't count
+func TokenCounter(ctx context.Context, p Provider, modelName string) func(string) int {
+	return func(text string) int {
+		n, err := p.CountTokens(ctx, text, modelName)
+		if err != nil {
+			return tokenizer.Estimate(text)
+		}
+		return n
+	}
+}
+
 // GenerateRequest contains all parameters for generation
 type GenerateRequest struct {
 	Prompt          string
@@ -37,15 +60,36 @@ type GenerateRequest struct {
 	Temperature     float64
 	MaxOutputTokens int
 
+	// Sampling controls beyond temperature, and extra body fields merged
+	// into the provider's request. Options must be on the provider's
+	// allowlist (config.AllowedProviderOptions).
+	Sampling        types.SamplingParams
+	ProviderOptions map[string]any
+
 	// Extended thinking
 	ThinkingMode   types.ThinkingMode
 	ThinkingBudget int
 
 	// Conversation context
 	ConversationHistory []types.ConversationTurn
+	ThreadID            string
 
 	// Vision
 	Images []string
+
+	// NoCache bypasses the response cache lookup for this call
+	NoCache bool
+
+	// ResponseSchema requests a JSON response matching the schema; decode it
+	// with DecodeResponse
+	ResponseSchema *JSONSchema
+
+	// Function calling: the functions the model may call, the calls and
+	// results of earlier rounds (sent after the prompt), and ToolChoiceNone to
+	// forbid further calls
+	Tools      []FunctionDef
+	ToolTurns  []ToolExchange
+	ToolChoice string
 }
 
 // BaseProvider provides common functionality
@@ -53,6 +97,8 @@ type BaseProvider struct {
 	providerType types.ProviderType
 	models       map[string]types.ModelCapabilities
 	aliases      map[string]string // alias -> canonical name
+	policy       config.ModelPolicy
+	mu           sync.RWMutex
 }
 
 // NewBaseProvider creates a new base provider
@@ -78,6 +124,9 @@ func (p *BaseProvider) GetProviderType() types.ProviderType {
 }
 
 func (p *BaseProvider) ListModels() []types.ModelCapabilities {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	models := make([]types.ModelCapabilities, 0, len(p.models))
 	for _, m := range p.models {
 		models = append(models, m)
@@ -86,6 +135,9 @@ func (p *BaseProvider) ListModels() []types.ModelCapabilities {
 }
 
 func (p *BaseProvider) GetCapabilities(modelName string) (*types.ModelCapabilities, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	// Check direct match
 	if m, ok := p.models[modelName]; ok {
 		return &m, nil
@@ -102,13 +154,78 @@ func (p *BaseProvider) GetCapabilities(modelName string) (*types.ModelCapabiliti
 }
 
 func (p *BaseProvider) SupportsModel(modelName string) bool {
-	_, err := p.GetCapabilities(modelName)
-	return err == nil
+	if _, err := p.GetCapabilities(modelName); err != nil {
+		return false
+	}
+	return p.CheckAllowed(modelName) == nil
+}
+
+// SetModelPolicy sets the allow/deny lists enforced by this provider
+func (p *BaseProvider) SetModelPolicy(policy config.ModelPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+}
+
+// CheckAllowed returns ErrModelRestricted when the model policy blocks a
+// model. Models outside the catalog are checked by the requested name.
+func (p *BaseProvider) CheckAllowed(modelName string) error {
+	names := []string{modelName}
+	if caps, err := p.GetCapabilities(modelName); err == nil {
+		names = append([]string{caps.ModelName}, caps.Aliases...)
+	}
+
+	p.mu.RLock()
+	policy := p.policy
+	p.mu.RUnlock()
+
+	if ok, reason := policy.Evaluate(names...); !ok {
+		return ErrModelRestricted{Model: modelName, Provider: p.providerType, Reason: reason}
+	}
+	return nil
 }
 
 func (p *BaseProvider) ResolveModelName(modelName string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	if canonical, ok := p.aliases[modelName]; ok {
 		return canonical
 	}
 	return modelName
 }
+
+// CheckVision rejects image input for models that don't declare vision support
+func (p *BaseProvider) CheckVision(modelName string, req *GenerateRequest) error {
+	if len(req.Images) == 0 {
+		return nil
+	}
+	caps, err := p.GetCapabilities(modelName)
+	if err != nil || caps.SupportsVision {
+		return nil
+	}
+	return ErrVisionNotSupported{Model: modelName, Provider: p.providerType}
+}
+
+// MergeDiscoveredModels adds models that aren't already known by name or alias.
+// Curated entries always take priority over discovered ones.
+func (p *BaseProvider) MergeDiscoveredModels(models []types.ModelCapabilities) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	added := 0
+	for _, m := range models {
+		if _, ok := p.models[m.ModelName]; ok {
+			continue
+		}
+		if _, ok := p.aliases[m.ModelName]; ok {
+			continue
+		}
+		m.Provider = p.providerType
+		m.Discovered = true
+		p.models[m.ModelName] = m
+		added++
+	}
+
+	return added
+}
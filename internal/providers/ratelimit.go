@@ -0,0 +1,254 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// ErrRateLimited indicates a call would exceed a rate limit and could not
+// wait long enough for capacity. It is safe to retry after RetryAfter.
+type ErrRateLimited struct {
+	Provider   types.ProviderType
+	Model      string
+	Limit      string // rpm or tpm
+	RetryAfter time.Duration
+}
+
+func (e ErrRateLimited) Error() string {
+	return fmt.Sprintf("%s rate limit reached for %s (%s); retry after %s",
+		e.Provider, e.Model, e.Limit, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Retryable reports that the call may succeed if retried later
+func (e ErrRateLimited) Retryable() bool {
+	return true
+}
+
+// tokenBucket refills continuously at perMinute/60 per second up to
+// perMinute. Reservations may drive the balance negative; the deficit is the
+// time the caller must wait.
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // per second
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// reserve takes n tokens and returns how long until the balance recovers.
+// Requests larger than the bucket are capped so they can still run once it
+// is full.
+func (b *tokenBucket) reserve(n float64, now time.Time) (float64, time.Duration) {
+	b.refill(now)
+	n = min(n, b.capacity)
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return n, 0
+	}
+	return n, time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// RateLimiter enforces per-provider and per-model RPM and TPM limits with
+// token buckets
+type RateLimiter struct {
+	limits  map[types.ProviderType]config.ProviderRateLimits
+	maxWait time.Duration
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewRateLimiter creates a limiter. Calls wait up to maxWait, and never past
+// their context deadline, before failing with ErrRateLimited.
+func NewRateLimiter(limits map[types.ProviderType]config.ProviderRateLimits, maxWait time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limits:  limits,
+		maxWait: maxWait,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// rateReservation records what a call took from each bucket so the token
+// estimate can be corrected once actual usage is known
+type rateReservation struct {
+	limiter *RateLimiter
+	taken   map[*tokenBucket]float64
+	tpm     []*tokenBucket
+}
+
+// bucketLimit is one limit that applies to a call
+type bucketLimit struct {
+	key       string
+	kind      string // rpm or tpm
+	perMinute int
+}
+
+// applicable returns the limits that apply to a model, provider-wide first
+func (l *RateLimiter) applicable(pt types.ProviderType, caps *types.ModelCapabilities) []bucketLimit {
+	limits, ok := l.limits[pt]
+	if !ok {
+		return nil
+	}
+
+	var out []bucketLimit
+	add := func(scope string, rl config.RateLimit) {
+		if rl.RPM > 0 {
+			out = append(out, bucketLimit{scope + ":rpm", "rpm", rl.RPM})
+		}
+		if rl.TPM > 0 {
+			out = append(out, bucketLimit{scope + ":tpm", "tpm", rl.TPM})
+		}
+	}
+
+	add(string(pt), limits.RateLimit)
+	for _, name := range append([]string{caps.ModelName}, caps.Aliases...) {
+		if rl, ok := limits.Models[name]; ok {
+			add(string(pt)+"/"+caps.ModelName, rl)
+			break
+		}
+	}
+	return out
+}
+
+// Acquire reserves one request and the estimated tokens against every limit
+// that applies, waiting for capacity when the wait fits within maxWait and
+// the context deadline. estimate is only called when a TPM limit applies.
+// It returns nil when no limits apply.
+func (l *RateLimiter) Acquire(ctx context.Context, pt types.ProviderType, caps *types.ModelCapabilities, estimate func() int) (*rateReservation, error) {
+	limits := l.applicable(pt, caps)
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	tokens := 0
+	for _, bl := range limits {
+		if bl.kind == "tpm" {
+			tokens = estimate()
+			break
+		}
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	res := &rateReservation{limiter: l, taken: make(map[*tokenBucket]float64)}
+	var wait time.Duration
+	var waitKind string
+
+	for _, bl := range limits {
+		b, ok := l.buckets[bl.key]
+		if !ok {
+			b = newTokenBucket(bl.perMinute, now)
+			l.buckets[bl.key] = b
+		}
+
+		n := 1.0
+		if bl.kind == "tpm" {
+			n = float64(tokens)
+			res.tpm = append(res.tpm, b)
+		}
+
+		taken, w := b.reserve(n, now)
+		res.taken[b] = taken
+		if w > wait {
+			wait, waitKind = w, bl.kind
+		}
+	}
+
+	if wait == 0 {
+		l.mu.Unlock()
+		return res, nil
+	}
+
+	allowed := l.maxWait
+	if deadline, ok := ctx.Deadline(); ok {
+		allowed = min(allowed, time.Until(deadline))
+	}
+	if wait > allowed {
+		res.release()
+		l.mu.Unlock()
+		return nil, ErrRateLimited{Provider: pt, Model: caps.ModelName, Limit: waitKind, RetryAfter: wait}
+	}
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return res, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		res.release()
+		l.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// release returns everything the reservation took. Callers must hold the
+// limiter's lock.
+func (r *rateReservation) release() {
+	for b, n := range r.taken {
+		b.tokens = min(b.capacity, b.tokens+n)
+	}
+}
+
+// Cancel returns a reservation whose call was never made
+func (r *rateReservation) Cancel() {
+	if r == nil {
+		return
+	}
+
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+	r.release()
+}
+
+// Refund returns the tokens reserved for a call that failed. The request
+// itself still counts against RPM, since it reached the provider.
+func (r *rateReservation) Refund() {
+	if r == nil {
+		return
+	}
+
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+
+	for _, b := range r.tpm {
+		b.tokens = min(b.capacity, b.tokens+r.taken[b])
+	}
+}
+
+// Settle corrects the TPM buckets with the tokens the call actually used
+func (r *rateReservation) Settle(actualTokens int) {
+	if r == nil || actualTokens <= 0 {
+		return
+	}
+
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+
+	for _, b := range r.tpm {
+		b.tokens = min(b.capacity, b.tokens+r.taken[b]-float64(actualTokens))
+	}
+}
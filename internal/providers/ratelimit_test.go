@@ -0,0 +1,201 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// fakeClock lets tests move the limiter's clock by hand
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(limits config.ProviderRateLimits, maxWait time.Duration) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter(map[types.ProviderType]config.ProviderRateLimits{types.ProviderOpenAI: limits}, maxWait)
+	l.now = clock.now
+	return l, clock
+}
+
+func fixedEstimate(n int) func() int {
+	return func() int { return n }
+}
+
+func TestRateLimiter_RPM(t *testing.T) {
+	l, clock := newTestLimiter(config.ProviderRateLimits{RateLimit: config.RateLimit{RPM: 2}}, 0)
+	caps := &types.ModelCapabilities{ModelName: "gpt-4.1"}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(0)); err != nil {
+			t.Fatalf("call %d should fit the limit: %v", i+1, err)
+		}
+	}
+
+	_, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(0))
+	var rateErr ErrRateLimited
+	if !errors.As(err, &rateErr) || rateErr.Limit != "rpm" || !rateErr.Retryable() {
+		t.Fatalf("expected retryable rpm error, got %v", err)
+	}
+	if rateErr.RetryAfter != 30*time.Second {
+		t.Errorf("expected retry after 30s, got %s", rateErr.RetryAfter)
+	}
+
+	// The refused call took nothing, so half a minute frees one slot
+	clock.advance(30 * time.Second)
+	if _, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(0)); err != nil {
+		t.Errorf("expected capacity after refill: %v", err)
+	}
+}
+
+func TestRateLimiter_TPMSettle(t *testing.T) {
+	l, _ := newTestLimiter(config.ProviderRateLimits{
+		Models: map[string]config.RateLimit{"gpt-4.1": {TPM: 1000}},
+	}, 0)
+	caps := &types.ModelCapabilities{ModelName: "gpt-4.1"}
+	ctx := context.Background()
+
+	res, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(900))
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	if _, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(500)); err == nil {
+		t.Fatal("expected the estimate to exhaust the bucket")
+	}
+
+	// The call actually used far less than estimated
+	res.Settle(200)
+	if _, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(500)); err != nil {
+		t.Errorf("expected capacity after settling: %v", err)
+	}
+
+	// Other models aren't limited
+	if res, _ := l.Acquire(ctx, types.ProviderOpenAI, &types.ModelCapabilities{ModelName: "o3"}, fixedEstimate(5000)); res != nil {
+		t.Error("expected no reservation for an unlimited model")
+	}
+}
+
+func TestRateLimiter_Refund(t *testing.T) {
+	l, _ := newTestLimiter(config.ProviderRateLimits{
+		RateLimit: config.RateLimit{RPM: 2},
+		Models:    map[string]config.RateLimit{"gpt-4.1": {TPM: 1000}},
+	}, 0)
+	caps := &types.ModelCapabilities{ModelName: "gpt-4.1"}
+	ctx := context.Background()
+
+	res, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(900))
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// The call failed, so its tokens come back but its request slot doesn't
+	res.Refund()
+	if _, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(900)); err != nil {
+		t.Errorf("expected the tokens back after a failed call: %v", err)
+	}
+	var rateErr ErrRateLimited
+	if _, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(0)); !errors.As(err, &rateErr) || rateErr.Limit != "rpm" {
+		t.Errorf("expected the failed call to still count against rpm, got %v", err)
+	}
+}
+
+func TestRateLimiter_Waits(t *testing.T) {
+	l := NewRateLimiter(map[types.ProviderType]config.ProviderRateLimits{
+		types.ProviderOpenAI: {RateLimit: config.RateLimit{RPM: 600}},
+	}, time.Second)
+	caps := &types.ModelCapabilities{ModelName: "gpt-4.1"}
+
+	// Drain the bucket; the next slot opens 100ms later
+	for i := 0; i < 600; i++ {
+		l.Acquire(context.Background(), types.ProviderOpenAI, caps, fixedEstimate(0))
+	}
+
+	start := time.Now()
+	if _, err := l.Acquire(context.Background(), types.ProviderOpenAI, caps, fixedEstimate(0)); err != nil {
+		t.Fatalf("expected to wait for capacity, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected a wait, returned after %s", elapsed)
+	}
+
+	// A deadline shorter than the wait fails fast
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var rateErr ErrRateLimited
+	if _, err := l.Acquire(ctx, types.ProviderOpenAI, caps, fixedEstimate(0)); !errors.As(err, &rateErr) {
+		t.Errorf("expected rate limit error within the deadline, got %v", err)
+	}
+}
+
+func TestManagedProvider_RateLimited(t *testing.T) {
+	var calls int
+	server := newPricedServer(t, "ok", &calls)
+
+	r := NewRegistry(&config.Config{
+		RateLimits: map[types.ProviderType]config.ProviderRateLimits{
+			types.ProviderCustom: {RateLimit: config.RateLimit{RPM: 1}},
+		},
+	})
+	p := &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderCustom, "key", server.URL, []types.ModelCapabilities{
+			{ModelName: "local"},
+		}, 0),
+		registry: r,
+	}
+
+	req := &GenerateRequest{Prompt: "hi", Model: "local"}
+	if _, err := p.GenerateContent(context.Background(), req); err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+
+	var rateErr ErrRateLimited
+	if _, err := p.GenerateContent(context.Background(), req); !errors.As(err, &rateErr) {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("limited call should not reach the provider, got %d calls", calls)
+	}
+}
+
+func TestManagedProvider_RateLimitErrorRefunds(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	r := NewRegistry(&config.Config{
+		RateLimits: map[types.ProviderType]config.ProviderRateLimits{
+			types.ProviderCustom: {Models: map[string]config.RateLimit{"local": {TPM: 1000}}},
+		},
+	})
+	p := &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderCustom, "key", server.URL, []types.ModelCapabilities{
+			{ModelName: "local"},
+		}, 0),
+		registry: r,
+	}
+
+	// Each request reserves most of the bucket; without refunds the second
+	// would be refused before reaching the provider
+	req := &GenerateRequest{Prompt: "hi", Model: "local", MaxOutputTokens: 800}
+	for i := 0; i < 3; i++ {
+		var rateErr ErrRateLimited
+		if _, err := p.GenerateContent(context.Background(), req); err == nil || errors.As(err, &rateErr) {
+			t.Fatalf("call %d: expected the API error, got %v", i+1, err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected every call to reach the provider, got %d", calls)
+	}
+}
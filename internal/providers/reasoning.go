@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// isOpenAIReasoningModel reports whether a model belongs to OpenAI's reasoning
+// families (o-series, gpt-5). These take reasoning_effort and
+// max_completion_tokens, and reject temperature.
+func isOpenAIReasoningModel(modelName string) bool {
+	name := strings.ToLower(modelName)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// reasoningEffort maps a thinking mode to OpenAI's reasoning_effort values.
+// Only the gpt-5 family accepts "minimal"; o-series models start at "low".
+func reasoningEffort(mode types.ThinkingMode, modelName string) string {
+	switch mode {
+	case types.ThinkingMinimal:
+		if strings.Contains(strings.ToLower(modelName), "gpt-5") {
+			return "minimal"
+		}
+		return "low"
+	case types.ThinkingLow:
+		return "low"
+	case types.ThinkingMedium:
+		return "medium"
+	case types.ThinkingHigh, types.ThinkingMax:
+		return "high"
+	default:
+		return ""
+	}
+}
+
+// applySamplingParams sets sampling controls, output limit and reasoning
+// effort on a chat-completions body according to the model's family and
+// capabilities. Reasoning models reject temperature, top_p, stop and the
+// penalties, so those are left out for them.
+func applySamplingParams(body map[string]any, modelName string, caps *types.ModelCapabilities, req *GenerateRequest) {
+	reasoning := isOpenAIReasoningModel(modelName)
+	s := req.Sampling
+
+	if !reasoning {
+		if req.Temperature > 0 {
+			body["temperature"] = req.Temperature
+		}
+		if s.TopP != nil {
+			body["top_p"] = *s.TopP
+		}
+		if len(s.Stop) > 0 {
+			body["stop"] = s.Stop
+		}
+		if s.PresencePenalty != nil {
+			body["presence_penalty"] = *s.PresencePenalty
+		}
+		if s.FrequencyPenalty != nil {
+			body["frequency_penalty"] = *s.FrequencyPenalty
+		}
+	}
+	if s.Seed != nil {
+		body["seed"] = *s.Seed
+	}
+	if s.N > 1 {
+		body["n"] = s.N
+	}
+
+	if req.MaxOutputTokens > 0 {
+		if reasoning {
+			body["max_completion_tokens"] = req.MaxOutputTokens
+		} else {
+			body["max_tokens"] = req.MaxOutputTokens
+		}
+	}
+
+	if caps != nil && caps.SupportsExtendedThinking && req.ThinkingMode != "" {
+		if effort := reasoningEffort(req.ThinkingMode, modelName); effort != "" {
+			body["reasoning_effort"] = effort
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sort"
-	    "sync"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/types"
-	)
-	// Priority order for provider selection
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Default priority order for built-in provider selection. Config-defined
+// providers are slotted in, and PROVIDER_PRIORITY applied, by
+// Registry.Initialize.
 var ProviderPriority = []types.ProviderType{
 	types.ProviderGemini,
 	types.ProviderOpenAI,
@@ -18,12 +25,18 @@ var ProviderPriority = []types.ProviderType{
 	types.ProviderDIAL,
 	types.ProviderCustom,
 	types.ProviderOpenRouter, // Catch-all last
+	types.ProviderMock,       // only present when MOCK_FIXTURES_FILE is set
 }
 
 // Registry manages all providers
 type Registry struct {
 	cfg       *config.Config
 	providers map[types.ProviderType]Provider
+	priority  []types.ProviderType
+	cache     *ResponseCache
+	costs     *CostLedger
+	limiter   *RateLimiter
+	health    *healthTracker
 	mu        sync.RWMutex
 }
 
@@ -32,6 +45,10 @@ func NewRegistry(cfg *config.Config) *Registry {
 	return &Registry{
 		cfg:       cfg,
 		providers: make(map[types.ProviderType]Provider),
+		priority:  slices.Clone(ProviderPriority),
+		costs:     &CostLedger{days: make(map[string]*DailySpend), threads: make(map[string]float64), now: time.Now},
+		limiter:   NewRateLimiter(cfg.RateLimits, cfg.RateLimitMaxWait),
+		health:    newHealthTracker(cfg.CircuitFailureThreshold, cfg.CircuitCooldown),
 	}
 }
 
@@ -111,6 +128,60 @@ func (r *Registry) Initialize() error {
 		}
 	}
 
+	if r.cfg.HasProvider(types.ProviderMock) {
+		p, err := NewMockProvider(r.cfg)
+		if err != nil {
+			slog.Warn("failed to initialize mock provider", "error", err)
+		} else {
+			r.providers[types.ProviderMock] = p
+			slog.Info("initialized provider", "type", types.ProviderMock, "fixtures", r.cfg.MockFixturesFile)
+		}
+	}
+
+	for _, pc := range r.cfg.Providers {
+		p, err := NewConfiguredProvider(r.cfg, pc)
+		if err != nil {
+			slog.Warn("failed to initialize provider", "name", pc.Name, "error", err)
+			continue
+		}
+		if len(p.ListModels()) == 0 && !r.cfg.ModelDiscovery {
+			slog.Warn("provider has no models; set models_file or MODEL_DISCOVERY", "name", pc.Name)
+		}
+		r.providers[pc.ProviderType()] = p
+		r.insertPriority(pc)
+		slog.Info("initialized provider", "type", pc.ProviderType(), "base_url", pc.BaseURL)
+	}
+
+	r.applyPriority()
+
+	if r.cfg.ResponseCache {
+		cache, err := NewResponseCache(r.cfg.ResponseCacheDir, r.cfg.ResponseCacheTTL, int64(r.cfg.ResponseCacheMaxMB)<<20)
+		if err != nil {
+			slog.Warn("response cache disabled", "error", err)
+		} else {
+			r.cache = cache
+			slog.Info("response cache enabled", "dir", cache.dir, "ttl", r.cfg.ResponseCacheTTL)
+		}
+	}
+
+	r.costs = r.openCostLedger()
+
+	if r.cfg.HTTPCassetteFile != "" {
+		r.useCassette()
+	}
+
+	strict := r.cfg.RequestValidation == ValidationStrict
+	for pt, p := range r.providers {
+		if holder, ok := p.(policyHolder); ok {
+			holder.SetModelPolicy(r.cfg.PolicyFor(pt))
+		}
+		r.providers[pt] = &managedProvider{Provider: p, strict: strict, cache: r.cache, registry: r}
+	}
+
+	for _, c := range r.aliasCollisions() {
+		slog.Warn("model name collision", "name", c.Name, "resolves_to", c.Targets[0], "also", c.Targets[1:])
+	}
+
 	if len(r.providers) == 0 {
 		// For initial testing, we might return nil if no providers are set,
 		// but the main.go expects an error if initialization fails.
@@ -123,6 +194,119 @@ func (r *Registry) Initialize() error {
 	return nil
 }
 
+// useCassette routes every provider's HTTP calls through the configured
+// record/replay cassette
+func (r *Registry) useCassette() {
+	cassette, err := NewCassette(r.cfg.HTTPCassetteFile, r.cfg.HTTPCassetteMode)
+	if err != nil {
+		slog.Warn("HTTP cassette disabled", "error", err)
+		return
+	}
+	for _, p := range r.providers {
+		if holder, ok := p.(transportHolder); ok {
+			holder.SetTransport(cassette)
+		}
+	}
+	slog.Info("HTTP cassette enabled", "file", r.cfg.HTTPCassetteFile, "mode", r.cfg.HTTPCassetteMode)
+}
+
+// openCostLedger loads the persistent cost ledger, falling back to an
+// in-memory one so budgets still apply within this process
+func (r *Registry) openCostLedger() *CostLedger {
+	path := r.cfg.CostLedgerFile
+	if path == "" {
+		var err error
+		if path, err = defaultLedgerPath(); err != nil {
+			slog.Warn("cost ledger not persisted", "error", err)
+		}
+	}
+
+	ledger, err := NewCostLedger(path)
+	if err != nil {
+		slog.Warn("cost ledger not persisted", "error", err)
+		ledger, _ = NewCostLedger("")
+	}
+	return ledger
+}
+
+// Costs returns the cost ledger
+func (r *Registry) Costs() *CostLedger {
+	return r.costs
+}
+
+// MockProvider returns the registry's mock provider, or nil when it isn't
+// configured
+func (r *Registry) MockProvider() *MockProvider {
+	p, ok := r.GetProvider(types.ProviderMock)
+	if !ok {
+		return nil
+	}
+	mock, _ := unwrapProvider(p).(*MockProvider)
+	return mock
+}
+
+// Health returns the health of each registered provider in priority order
+func (r *Registry) Health() []ProviderHealth {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var health []ProviderHealth
+	for _, pt := range r.priority {
+		if _, ok := r.providers[pt]; ok {
+			health = append(health, r.health.snapshot(pt))
+		}
+	}
+	return health
+}
+
+// ProviderHealth returns the health of one provider
+func (r *Registry) ProviderHealth(pt types.ProviderType) ProviderHealth {
+	return r.health.snapshot(pt)
+}
+
+// policyHolder is implemented by providers embedding BaseProvider
+type policyHolder interface {
+	SetModelPolicy(policy config.ModelPolicy)
+	CheckAllowed(modelName string) error
+}
+
+// modelAllowed applies the provider's model policy to a catalog entry
+func (r *Registry) modelAllowed(pt types.ProviderType, m types.ModelCapabilities) (bool, string) {
+	return r.cfg.PolicyFor(pt).Evaluate(append([]string{m.ModelName}, m.Aliases...)...)
+}
+
+// insertPriority slots a config-defined provider into the selection order,
+// defaulting to just before the OpenRouter catch-all
+func (r *Registry) insertPriority(pc config.ProviderConfig) {
+	idx := slices.Index(r.priority, types.ProviderOpenRouter)
+	if idx < 0 {
+		idx = len(r.priority)
+	}
+	if pc.Priority != nil {
+		idx = min(max(*pc.Priority, 0), len(r.priority))
+	}
+	r.priority = slices.Insert(r.priority, idx, pc.ProviderType())
+}
+
+// applyPriority moves the providers listed in PROVIDER_PRIORITY to the front,
+// in the listed order
+func (r *Registry) applyPriority() {
+	if len(r.cfg.ProviderPriority) == 0 {
+		return
+	}
+	rest := slices.DeleteFunc(slices.Clone(r.priority), func(pt types.ProviderType) bool {
+		return slices.Contains(r.cfg.ProviderPriority, pt)
+	})
+	r.priority = append(slices.Clone(r.cfg.ProviderPriority), rest...)
+}
+
+// Priority returns the provider selection order
+func (r *Registry) Priority() []types.ProviderType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.priority)
+}
+
 // GetProvider returns a specific provider
 func (r *Registry) GetProvider(pt types.ProviderType) (Provider, bool) {
 	r.mu.RLock()
@@ -131,21 +315,115 @@ func (r *Registry) GetProvider(pt types.ProviderType) (Provider, bool) {
 	return p, ok
 }
 
-// GetProviderForModel finds the best provider for a model
+// splitQualified splits a "provider:model" name whose prefix is a known
+// provider. Other names, such as Ollama's "llama3.2:3b", are left alone.
+func (r *Registry) splitQualified(name string) (types.ProviderType, string, bool) {
+	prefix, model, ok := strings.Cut(name, ":")
+	if !ok || model == "" || !slices.Contains(r.priority, types.ProviderType(prefix)) {
+		return "", name, false
+	}
+	return types.ProviderType(prefix), model, true
+}
+
+// GetProviderForModel finds the best provider for a model. A
+// provider-qualified name such as "azure:gpt-4o" picks that provider. Models
+// outside the provider's catalog are only passed through when model
+// discovery is on and the provider can list them.
 func (r *Registry) GetProviderForModel(modelName string) (Provider, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	if pt, model, ok := r.splitQualified(modelName); ok {
+		p, ok := r.providers[pt]
+		if !ok {
+			return nil, ErrProviderNotConfigured{Provider: pt}
+		}
+		if _, err := p.GetCapabilities(model); err != nil {
+			if _, ok := unwrapProvider(p).(ModelDiscoverer); !ok || !r.cfg.ModelDiscovery {
+				return nil, ErrModelNotFound{Model: modelName}
+			}
+		}
+		if holder, ok := unwrapProvider(p).(policyHolder); ok {
+			if err := holder.CheckAllowed(model); err != nil {
+				return nil, err
+			}
+		}
+		return p, nil
+	}
+
 	// Check providers in priority order
-	for _, pt := range ProviderPriority {
+	for _, pt := range r.priority {
 		if p, ok := r.providers[pt]; ok && p.SupportsModel(modelName) {
 			return p, nil
 		}
 	}
 
+	// Report a policy block rather than "not found" when a provider knows the model
+	for _, pt := range r.priority {
+		p, ok := r.providers[pt]
+		if !ok {
+			continue
+		}
+		if _, err := p.GetCapabilities(modelName); err != nil {
+			continue
+		}
+		if holder, ok := unwrapProvider(p).(policyHolder); ok {
+			if err := holder.CheckAllowed(modelName); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return nil, ErrModelNotFound{Model: modelName}
 }
 
+// StartDiscovery refreshes discovered models at startup and then on the
+// configured interval. An interval of zero refreshes only once.
+func (r *Registry) StartDiscovery(ctx context.Context) {
+	r.refreshModels(ctx)
+
+	if r.cfg.ModelDiscoveryInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.cfg.ModelDiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.refreshModels(ctx)
+		}
+	}
+}
+
+// refreshModels asks every discovery-capable provider for its model list
+func (r *Registry) refreshModels(ctx context.Context) {
+	r.mu.RLock()
+	discoverers := make(map[types.ProviderType]ModelDiscoverer)
+	for pt, p := range r.providers {
+		if d, ok := unwrapProvider(p).(ModelDiscoverer); ok {
+			discoverers[pt] = d
+		}
+	}
+	r.mu.RUnlock()
+
+	for pt, d := range discoverers {
+		reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		added, err := d.RefreshModels(reqCtx)
+		cancel()
+		if err != nil {
+			slog.Warn("model discovery failed", "provider", pt, "error", err)
+			continue
+		}
+		if added > 0 {
+			slog.Info("discovered models", "provider", pt, "added", added)
+		}
+	}
+}
+
 // GetAllModels returns all available models across providers
 func (r *Registry) GetAllModels() []types.ModelCapabilities {
 	r.mu.RLock()
@@ -154,9 +432,12 @@ func (r *Registry) GetAllModels() []types.ModelCapabilities {
 	var models []types.ModelCapabilities
 	seen := make(map[string]bool)
 
-	for _, pt := range ProviderPriority {
+	for _, pt := range r.priority {
 		if p, ok := r.providers[pt]; ok {
 			for _, m := range p.ListModels() {
+				if ok, _ := r.modelAllowed(pt, m); !ok {
+					continue
+				}
 				if !seen[m.ModelName] {
 					models = append(models, m)
 					seen[m.ModelName] = true
@@ -173,38 +454,245 @@ func (r *Registry) GetAllModels() []types.ModelCapabilities {
 	return models
 }
 
-// SelectBestModel finds the best model for a task
-func (r *Registry) SelectBestModel(requirements ModelRequirements) (*types.ModelCapabilities, Provider, error) {
+// AliasCollision is a model name or alias that refers to different models.
+// Targets are "provider:model" names, the one the name resolves to first.
+type AliasCollision struct {
+	Name    string
+	Targets []string
+}
+
+// AliasCollisions returns the names that refer to more than one model, within
+// a provider or across providers. The same model served by several providers
+// is not a collision; PROVIDER_PRIORITY decides which serves it.
+func (r *Registry) AliasCollisions() []AliasCollision {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return r.aliasCollisions()
+}
 
-	var bestModel *types.ModelCapabilities
-	var bestProvider Provider
+func (r *Registry) aliasCollisions() []AliasCollision {
+	type target struct {
+		pt    types.ProviderType
+		model string
+	}
+	targets := make(map[string][]target)
 
-	for _, pt := range ProviderPriority {
+	for _, pt := range r.priority {
 		p, ok := r.providers[pt]
 		if !ok {
 			continue
 		}
+		models := p.ListModels()
+		sort.Slice(models, func(i, j int) bool { return models[i].ModelName < models[j].ModelName })
+		for _, m := range models {
+			if ok, _ := r.modelAllowed(pt, m); !ok {
+				continue
+			}
+			for _, name := range append([]string{m.ModelName}, m.Aliases...) {
+				targets[name] = append(targets[name], target{pt, m.ModelName})
+			}
+		}
+	}
+
+	var collisions []AliasCollision
+	for name, ts := range targets {
+		distinct := make(map[string]bool)
+		for _, t := range ts {
+			distinct[t.model] = true
+		}
+		if len(distinct) < 2 {
+			continue
+		}
+
+		// Lead with the model the name actually resolves to
+		first := ts[0]
+		if caps, err := r.providers[first.pt].GetCapabilities(name); err == nil {
+			first.model = caps.ModelName
+		}
+		c := AliasCollision{Name: name, Targets: []string{string(first.pt) + ":" + first.model}}
+		for _, t := range ts {
+			if q := string(t.pt) + ":" + t.model; !slices.Contains(c.Targets, q) {
+				c.Targets = append(c.Targets, q)
+			}
+		}
+		collisions = append(collisions, c)
+	}
+
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Name < collisions[j].Name })
+	return collisions
+}
 
+// HiddenModel is a catalog model blocked by a model policy
+type HiddenModel struct {
+	Model  types.ModelCapabilities
+	Reason string
+}
+
+// HiddenModels returns the models removed by model policies, with the reason
+func (r *Registry) HiddenModels() []HiddenModel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var hidden []HiddenModel
+	for _, pt := range r.priority {
+		p, ok := r.providers[pt]
+		if !ok {
+			continue
+		}
 		for _, m := range p.ListModels() {
-			if !meetsRequirements(m, requirements) {
+			if ok, reason := r.modelAllowed(pt, m); !ok {
+				hidden = append(hidden, HiddenModel{Model: m, Reason: reason})
+			}
+		}
+	}
+
+	sort.Slice(hidden, func(i, j int) bool {
+		if hidden[i].Model.Provider != hidden[j].Model.Provider {
+			return hidden[i].Model.Provider < hidden[j].Model.Provider
+		}
+		return hidden[i].Model.ModelName < hidden[j].Model.ModelName
+	})
+
+	return hidden
+}
+
+// SelectBestModel finds the best model for a task. Use Route for tool calls
+// so the tool's task profile applies and the decision is explained.
+func (r *Registry) SelectBestModel(requirements ModelRequirements) (*types.ModelCapabilities, Provider, error) {
+	d, err := r.route(requirements)
+	if err != nil {
+		return nil, nil, err
+	}
+	return d.Model, d.Provider, nil
+}
+
+// SelectEmbedder returns the embedder and catalog entry for an embedding
+// model. An empty model falls back to EMBEDDING_MODEL, then to the
+// highest-scoring embedding model of the first healthy provider, in
+// priority order, that supports embeddings.
+func (r *Registry) SelectEmbedder(model string) (Embedder, *types.ModelCapabilities, error) {
+	if model == "" {
+		model = r.cfg.EmbeddingModel
+	}
+
+	if model != "" {
+		p, err := r.GetProviderForModel(model)
+		if err != nil {
+			return nil, nil, err
+		}
+		caps, err := p.GetCapabilities(model)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !caps.Embedding {
+			return nil, nil, ErrModelKind{Model: caps.ModelName, Provider: caps.Provider}
+		}
+		e, ok := p.(Embedder)
+		if _, inner := unwrapProvider(p).(Embedder); !ok || !inner {
+			return nil, nil, ErrEmbeddingsNotSupported{Provider: p.GetProviderType()}
+		}
+		return e, caps, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, pt := range r.priority {
+		p, ok := r.providers[pt]
+		if !ok || !r.health.available(pt) {
+			continue
+		}
+		e, ok := p.(Embedder)
+		if _, inner := unwrapProvider(p).(Embedder); !ok || !inner {
+			continue
+		}
+
+		var best *types.ModelCapabilities
+		for _, m := range p.ListModels() {
+			if !m.Embedding {
 				continue
 			}
+			if ok, _ := r.modelAllowed(pt, m); !ok {
+				continue
+			}
+			if best == nil || m.IntelligenceScore > best.IntelligenceScore ||
+				(m.IntelligenceScore == best.IntelligenceScore && m.ModelName < best.ModelName) {
+				mCopy := m
+				best = &mCopy
+			}
+		}
+		if best != nil {
+			return e, best, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("no embedding model available; add one to a provider's model registry")
+}
+
+// downgradeModel picks the model to use once a budget is reached: the
+// configured downgrade_to model, or else the cheapest priced model that is
+// cheaper than the current one. Either way the model must handle images the
+// request carries, its provider must be healthy, and it must cost less than
+// the current model when both are priced.
+func (r *Registry) downgradeModel(current *types.ModelCapabilities, needsVision bool) (*types.ModelCapabilities, Provider, error) {
+	if name := r.cfg.Budgets.DowngradeTo; name != "" {
+		p, err := r.GetProviderForModel(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		caps, err := p.GetCapabilities(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case caps.ModelName == current.ModelName:
+			return nil, nil, fmt.Errorf("already using %s", caps.ModelName)
+		case needsVision && !caps.SupportsVision:
+			return nil, nil, fmt.Errorf("downgrade_to model %s does not support images", caps.ModelName)
+		case !r.health.available(p.GetProviderType()):
+			return nil, nil, fmt.Errorf("downgrade_to model %s is unavailable: %s circuit is open", caps.ModelName, p.GetProviderType())
+		case current.HasPricing() && caps.HasPricing() && blendedPrice(caps) >= blendedPrice(current):
+			return nil, nil, fmt.Errorf("downgrade_to model %s is not cheaper than %s", caps.ModelName, current.ModelName)
+		}
+		return caps, p, nil
+	}
 
-			if bestModel == nil || m.IntelligenceScore > bestModel.IntelligenceScore {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best *types.ModelCapabilities
+	var bestProvider Provider
+
+	for _, pt := range r.priority {
+		p, ok := r.providers[pt]
+		if !ok || !r.health.available(pt) {
+			continue
+		}
+		for _, m := range p.ListModels() {
+			if !m.HasPricing() || m.Embedding || m.ModelName == current.ModelName {
+				continue
+			}
+			if ok, _ := r.modelAllowed(pt, m); !ok {
+				continue
+			}
+			if needsVision && !m.SupportsVision {
+				continue
+			}
+			if current.HasPricing() && blendedPrice(&m) >= blendedPrice(current) {
+				continue
+			}
+			if best == nil || blendedPrice(&m) < blendedPrice(best) {
 				mCopy := m
-				bestModel = &mCopy
+				best = &mCopy
 				bestProvider = p
 			}
 		}
 	}
 
-	if bestModel == nil {
-		return nil, nil, fmt.Errorf("no model meets requirements")
+	if best == nil {
+		return nil, nil, fmt.Errorf("no cheaper priced model available")
 	}
-
-	return bestModel, bestProvider, nil
+	return best, bestProvider, nil
 }
 
 // ModelRequirements specifies what a model needs to support
@@ -214,9 +702,13 @@ type ModelRequirements struct {
 	NeedsVision         bool
 	NeedsCodeGeneration bool
 	MinContextWindow    int
+	CostWeight          float64 // 0 picks the most intelligent model, 1 the cheapest
 }
 
 func meetsRequirements(m types.ModelCapabilities, req ModelRequirements) bool {
+	if m.Embedding {
+		return false
+	}
 	if m.IntelligenceScore < req.MinIntelligence {
 		return false
 	}
@@ -0,0 +1,160 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestRegistry_ModelPolicy(t *testing.T) {
+	cfg := &config.Config{
+		OpenAIAPIKey: "test-key",
+		ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+			types.ProviderOpenAI: {
+				{Provider: types.ProviderOpenAI, ModelName: "gpt-5.2-pro", IntelligenceScore: 100},
+				{Provider: types.ProviderOpenAI, ModelName: "gpt-5.2", IntelligenceScore: 98, Aliases: []string{"gpt5"}},
+				{Provider: types.ProviderOpenAI, ModelName: "gpt-4o", IntelligenceScore: 80},
+			},
+		},
+		ModelPolicies: map[types.ProviderType]config.ModelPolicy{
+			types.ProviderOpenAI: {Allow: []string{"gpt-5*"}, Deny: []string{"*-pro"}},
+		},
+	}
+
+	r := NewRegistry(cfg)
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	models := r.GetAllModels()
+	if len(models) != 1 || models[0].ModelName != "gpt-5.2" {
+		t.Errorf("expected only gpt-5.2 to be listed, got %v", models)
+	}
+
+	best, _, err := r.SelectBestModel(ModelRequirements{})
+	if err != nil {
+		t.Fatalf("SelectBestModel failed: %v", err)
+	}
+	if best.ModelName != "gpt-5.2" {
+		t.Errorf("expected gpt-5.2 to be selected, got %s", best.ModelName)
+	}
+
+	if _, err := r.GetProviderForModel("gpt5"); err != nil {
+		t.Errorf("expected alias of allowed model to resolve, got %v", err)
+	}
+
+	var restricted ErrModelRestricted
+	if _, err := r.GetProviderForModel("gpt-5.2-pro"); !errors.As(err, &restricted) {
+		t.Errorf("expected ErrModelRestricted, got %v", err)
+	}
+
+	hidden := r.HiddenModels()
+	if len(hidden) != 2 {
+		t.Fatalf("expected 2 hidden models, got %d", len(hidden))
+	}
+	if hidden[0].Model.ModelName != "gpt-4o" || hidden[0].Reason == "" {
+		t.Errorf("unexpected hidden entry: %+v", hidden[0])
+	}
+
+	// Calling the provider directly is blocked as well
+	p, _ := r.GetProvider(types.ProviderOpenAI)
+	_, err = p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "gpt-4o"})
+	if !errors.As(err, &restricted) {
+		t.Errorf("expected direct call to be restricted, got %v", err)
+	}
+}
+
+func TestRegistry_ProviderQualifiedModels(t *testing.T) {
+	var hits []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		hits = append(hits, body.Model)
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	gpt4o := func(pt types.ProviderType, aliases ...string) types.ModelCapabilities {
+		return types.ModelCapabilities{Provider: pt, ModelName: "gpt-4o", IntelligenceScore: 80, Aliases: aliases}
+	}
+	cfg := &config.Config{
+		OpenAIAPIKey:     "key",
+		OpenRouterAPIKey: "key",
+		CustomAPIURL:     server.URL,
+		CostLedgerFile:   filepath.Join(t.TempDir(), "costs.jsonl"),
+		ProviderPriority: []types.ProviderType{types.ProviderOpenRouter},
+		ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+			types.ProviderOpenAI:     {gpt4o(types.ProviderOpenAI, "4o")},
+			types.ProviderOpenRouter: {gpt4o(types.ProviderOpenRouter), {Provider: types.ProviderOpenRouter, ModelName: "openai/gpt-4o-mini", Aliases: []string{"4o"}}},
+			types.ProviderCustom:     {{Provider: types.ProviderCustom, ModelName: "llama3.2:3b"}},
+		},
+	}
+	r := NewRegistry(cfg)
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if got := r.Priority(); got[0] != types.ProviderOpenRouter || got[1] != types.ProviderGemini {
+		t.Errorf("expected openrouter first, then the default order, got %v", got)
+	}
+
+	tests := []struct {
+		name string
+		want types.ProviderType
+	}{
+		{"gpt-4o", types.ProviderOpenRouter},
+		{"openai:gpt-4o", types.ProviderOpenAI},
+		{"llama3.2:3b", types.ProviderCustom},
+		{"custom:llama3.2:3b", types.ProviderCustom},
+	}
+	for _, tt := range tests {
+		p, err := r.GetProviderForModel(tt.name)
+		if err != nil || p.GetProviderType() != tt.want {
+			t.Errorf("GetProviderForModel(%q) = %v, %v; want %s", tt.name, p, err, tt.want)
+		}
+	}
+
+	// Models outside the catalog pass through only when they can be discovered
+	var notFound ErrModelNotFound
+	if _, err := r.GetProviderForModel("openai:gpt-4o-2024-08-06"); !errors.As(err, &notFound) {
+		t.Errorf("expected ErrModelNotFound, got %v", err)
+	}
+	cfg.ModelDiscovery = true
+	if p, err := r.GetProviderForModel("openrouter:anthropic/claude-sonnet-4.5"); err != nil || p.GetProviderType() != types.ProviderOpenRouter {
+		t.Errorf("expected discoverable model to pass through, got %v, %v", p, err)
+	}
+
+	var notConfigured ErrProviderNotConfigured
+	if _, err := r.GetProviderForModel("azure:gpt-4o"); !errors.As(err, &notConfigured) {
+		t.Errorf("expected ErrProviderNotConfigured, got %v", err)
+	}
+
+	// The qualifier is stripped before the request reaches the provider
+	p, _ := r.GetProvider(types.ProviderCustom)
+	if caps, err := p.GetCapabilities("custom:llama3.2:3b"); err != nil || caps.ModelName != "llama3.2:3b" {
+		t.Errorf("GetCapabilities = %v, %v", caps, err)
+	}
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "custom:llama3.2:3b"}); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0] != "llama3.2:3b" {
+		t.Errorf("expected the unqualified name to be sent, got %v", hits)
+	}
+
+	collisions := r.AliasCollisions()
+	if len(collisions) != 1 || collisions[0].Name != "4o" ||
+		!slices.Equal(collisions[0].Targets, []string{"openrouter:openai/gpt-4o-mini", "openai:gpt-4o"}) {
+		t.Errorf("unexpected collisions %+v", collisions)
+	}
+}
@@ -0,0 +1,209 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Routing score adjustments. Intelligence and price are blended by the cost
+// weight into a 0-1 score; latency and health then nudge it.
+const (
+	latencyWeight   = 0.1 // fastest observed provider vs slowest
+	failurePenalty  = 0.3 // times the provider's failure rate
+	recoveryPenalty = 0.2 // circuit not closed
+)
+
+// replyReserve is the room left for the reply when deriving the context
+// window a request needs
+const replyReserve = 8192
+
+// RequiredContext estimates the context window needed to send parts and get
+// a reply, for ModelRequirements.MinContextWindow
+func RequiredContext(parts ...string) int {
+	n := 0
+	for _, p := range parts {
+		n += tokenizer.Estimate(p)
+	}
+	return n + replyReserve
+}
+
+// RouteDecision is an automatically selected model and why it won
+type RouteDecision struct {
+	Model       *types.ModelCapabilities
+	Provider    Provider
+	Score       float64
+	Explanation string
+}
+
+// routeCandidate is a qualifying model with its score breakdown
+type routeCandidate struct {
+	model    types.ModelCapabilities
+	provider Provider
+	health   ProviderHealth
+	score    float64
+}
+
+// Route picks a model for an "auto" request. The requirements, derived from
+// the request, are merged with the task profile of the tool on ctx, and the
+// decision is explained in the call notes on ctx so it can be shown with the
+// result.
+func (r *Registry) Route(ctx context.Context, req ModelRequirements) (*RouteDecision, error) {
+	tool := toolName(ctx)
+	req = req.withProfile(r.cfg.TaskProfileFor(tool))
+
+	d, err := r.route(req)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("routed request", "tool", tool, "model", d.Model.ModelName, "provider", d.Model.Provider, "why", d.Explanation)
+	callNotesFrom(ctx).noteRouting(d.Explanation)
+	return d, nil
+}
+
+// withProfile adds a tool's task profile to request-derived requirements
+func (req ModelRequirements) withProfile(p config.TaskProfile) ModelRequirements {
+	req.MinIntelligence = max(req.MinIntelligence, p.MinIntelligence)
+	req.NeedsThinking = req.NeedsThinking || p.NeedsThinking
+	req.NeedsCodeGeneration = req.NeedsCodeGeneration || p.NeedsCodeGeneration
+	if req.CostWeight == 0 {
+		req.CostWeight = p.CostWeight
+	}
+	return req
+}
+
+// route scores every qualifying model on an available provider. Ties go to
+// the earlier provider in priority order.
+func (r *Registry) route(req ModelRequirements) (*RouteDecision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var candidates []*routeCandidate
+	var maxPrice float64
+	var maxLatency time.Duration
+
+	for _, pt := range r.priority {
+		p, ok := r.providers[pt]
+		if !ok || !r.health.available(pt) {
+			continue
+		}
+		health := r.health.snapshot(pt)
+
+		for _, m := range p.ListModels() {
+			if ok, _ := r.modelAllowed(pt, m); !ok {
+				continue
+			}
+			if !meetsRequirements(m, req) {
+				continue
+			}
+			candidates = append(candidates, &routeCandidate{model: m, provider: p, health: health})
+			maxPrice = max(maxPrice, blendedPrice(&m))
+			if health.Calls > 0 {
+				maxLatency = max(maxLatency, health.AvgLatency)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no model meets requirements")
+	}
+
+	var best, runnerUp *routeCandidate
+	for _, c := range candidates {
+		c.score = c.scoreWith(req.CostWeight, maxPrice, maxLatency)
+		switch {
+		case best == nil || c.score > best.score ||
+			(c.score == best.score && c.model.Provider == best.model.Provider && c.model.ModelName < best.model.ModelName):
+			runnerUp, best = best, c
+		case runnerUp == nil || c.score > runnerUp.score:
+			runnerUp = c
+		}
+	}
+
+	return &RouteDecision{
+		Model:       &best.model,
+		Provider:    best.provider,
+		Score:       best.score,
+		Explanation: explainRoute(req, best, runnerUp, len(candidates)),
+	}, nil
+}
+
+// scoreWith blends intelligence and price by costWeight, then adjusts for
+// latency and health. Prices and latencies are relative to the most
+// expensive and slowest candidates; unpriced models and providers without
+// observed calls score as average.
+func (c *routeCandidate) scoreWith(costWeight float64, maxPrice float64, maxLatency time.Duration) float64 {
+	quality := float64(c.model.IntelligenceScore) / 100
+
+	cheapness := 0.5
+	if c.model.HasPricing() && maxPrice > 0 {
+		cheapness = 1 - blendedPrice(&c.model)/maxPrice
+	}
+
+	speed := 0.5
+	if c.health.Calls > 0 && maxLatency > 0 {
+		speed = 1 - float64(c.health.AvgLatency)/float64(maxLatency)
+	}
+
+	score := (1-costWeight)*quality + costWeight*cheapness + latencyWeight*(speed-0.5)
+	if c.health.Calls > 0 {
+		score -= failurePenalty * float64(c.health.Failures) / float64(c.health.Calls)
+	}
+	if !c.health.Healthy() {
+		score -= recoveryPenalty
+	}
+	return score
+}
+
+// blendedPrice is a model's input plus output price per million tokens
+func blendedPrice(m *types.ModelCapabilities) float64 {
+	return m.InputPricePerMTok + m.OutputPricePerMTok
+}
+
+// explainRoute describes a routing decision in one line
+func explainRoute(req ModelRequirements, best, runnerUp *routeCandidate, candidates int) string {
+	var needs []string
+	if req.MinIntelligence > 0 {
+		needs = append(needs, fmt.Sprintf("intelligence >= %d", req.MinIntelligence))
+	}
+	if req.NeedsThinking {
+		needs = append(needs, "thinking")
+	}
+	if req.NeedsVision {
+		needs = append(needs, "vision")
+	}
+	if req.NeedsCodeGeneration {
+		needs = append(needs, "code generation")
+	}
+	if req.MinContextWindow > 0 {
+		needs = append(needs, fmt.Sprintf("context >= %dk", (req.MinContextWindow+999)/1000))
+	}
+	needs = append(needs, fmt.Sprintf("cost weight %.1f", req.CostWeight))
+
+	facts := []string{fmt.Sprintf("intelligence %d", best.model.IntelligenceScore)}
+	if best.model.HasPricing() {
+		facts = append(facts, fmt.Sprintf("$%.2f/M in+out", blendedPrice(&best.model)))
+	} else {
+		facts = append(facts, "unpriced")
+	}
+	if best.health.Calls > 0 {
+		facts = append(facts, fmt.Sprintf("avg latency %s", best.health.AvgLatency.Round(100*time.Millisecond)))
+		if best.health.Failures > 0 {
+			facts = append(facts, fmt.Sprintf("%d/%d calls failed", best.health.Failures, best.health.Calls))
+		}
+	}
+
+	s := fmt.Sprintf("%s:%s (score %.2f; %s) from %d candidates for %s",
+		best.model.Provider, best.model.ModelName, best.score, strings.Join(facts, ", "), candidates, strings.Join(needs, ", "))
+	if runnerUp != nil {
+		s += fmt.Sprintf("; runner-up %s:%s (%.2f)", runnerUp.model.Provider, runnerUp.model.ModelName, runnerUp.score)
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package providers
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+func TestRegistry_Route(t *testing.T) {
+	cfg := &config.Config{
+		CustomAPIURL:   "http://localhost:1",
+		CostLedgerFile: filepath.Join(t.TempDir(), "costs.jsonl"),
+		ModelRegistries: map[types.ProviderType][]types.ModelCapabilities{
+			types.ProviderCustom: {
+				{Provider: types.ProviderCustom, ModelName: "big", IntelligenceScore: 90, ContextWindow: 200000, InputPricePerMTok: 10, OutputPricePerMTok: 30},
+				{Provider: types.ProviderCustom, ModelName: "small", IntelligenceScore: 70, ContextWindow: 32000, InputPricePerMTok: 0.5, OutputPricePerMTok: 1.5},
+			},
+		},
+		TaskProfiles: map[string]config.TaskProfile{
+			"chat": {MinIntelligence: 85},
+		},
+	}
+	r := NewRegistry(cfg)
+	if err := r.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		tool string
+		req  ModelRequirements
+		want string
+	}{
+		{"no profile picks the smartest", "", ModelRequirements{}, "big"},
+		{"cost weight picks the cheaper", "", ModelRequirements{CostWeight: 1}, "small"},
+		{"default profile weighs cost", "apilookup", ModelRequirements{}, "small"},
+		{"configured profile overrides the default", "chat", ModelRequirements{CostWeight: 1}, "big"},
+		{"large request needs a larger window", "apilookup", ModelRequirements{MinContextWindow: 100000}, "big"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := WithToolUsage(context.Background(), tt.tool)
+			ctx, notes := WithCallNotes(ctx)
+			d, err := r.Route(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("Route failed: %v", err)
+			}
+			if d.Model.ModelName != tt.want {
+				t.Errorf("expected %s, got %s (%s)", tt.want, d.Model.ModelName, d.Explanation)
+			}
+			if why := notes.Routing(); len(why) != 1 || !strings.HasPrefix(why[0], "custom:"+tt.want) {
+				t.Errorf("expected the decision to be explained, got %q", why)
+			}
+		})
+	}
+
+	if _, err := r.Route(context.Background(), ModelRequirements{MinContextWindow: 500000}); err == nil {
+		t.Error("expected an error when no model fits")
+	}
+}
+
+func TestRegistry_RouteObservesHealth(t *testing.T) {
+	r := NewRegistry(&config.Config{})
+	r.providers[types.ProviderCustom] = &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderCustom, "key", "http://localhost:1", []types.ModelCapabilities{
+			{Provider: types.ProviderCustom, ModelName: "slow", IntelligenceScore: 81},
+		}, 0),
+		registry: r,
+	}
+	r.providers[types.ProviderOpenRouter] = &managedProvider{
+		Provider: NewOpenAICompatProvider(types.ProviderOpenRouter, "key", "http://localhost:1", []types.ModelCapabilities{
+			{Provider: types.ProviderOpenRouter, ModelName: "fast", IntelligenceScore: 80},
+		}, 0),
+		registry: r,
+	}
+
+	route := func() string {
+		d, err := r.Route(context.Background(), ModelRequirements{})
+		if err != nil {
+			t.Fatalf("Route failed: %v", err)
+		}
+		return d.Model.ModelName
+	}
+
+	if got := route(); got != "slow" {
+		t.Errorf("expected intelligence to decide without observations, got %s", got)
+	}
+
+	r.health.record(types.ProviderCustom, 5*time.Second, nil)
+	r.health.record(types.ProviderOpenRouter, 500*time.Millisecond, nil)
+	if got := route(); got != "fast" {
+		t.Errorf("expected observed latency to favor the faster provider, got %s", got)
+	}
+
+	r.health.record(types.ProviderCustom, 500*time.Millisecond, nil)
+	r.health.record(types.ProviderCustom, 500*time.Millisecond, nil)
+	r.health.record(types.ProviderOpenRouter, time.Second, ErrAPIError{Provider: types.ProviderOpenRouter, StatusCode: 503})
+	if got := route(); got != "slow" {
+		t.Errorf("expected failures to count against a provider, got %s", got)
+	}
+}
@@ -0,0 +1,316 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// Structured output modes reported in the structured_output metadata
+const (
+	StructuredNative = "native" // the provider constrained generation to the schema
+	StructuredPrompt = "prompt" // the schema was given in the prompt and the reply validated
+)
+
+// JSONSchema asks for a response that is a JSON document matching Schema.
+// Models with native support constrain generation to it; others get the
+// schema in the prompt and their reply is validated.
+type JSONSchema struct {
+	Name   string         // short identifier, e.g. "code_review"
+	Schema map[string]any // JSON Schema for the response
+
+	// Strict enables OpenAI's strict mode, which requires every property to
+	// be listed in required and additionalProperties to be false
+	Strict bool
+}
+
+// ErrSchemaMismatch indicates a structured response didn't match its schema
+type ErrSchemaMismatch struct {
+	Model  string
+	Reason string
+}
+
+func (e ErrSchemaMismatch) Error() string {
+	return fmt.Sprintf("response from %s does not match the schema: %s", e.Model, e.Reason)
+}
+
+// DecodeResponse unmarshals a structured response into T. It tolerates the
+// markdown fences and surrounding prose some models add.
+func DecodeResponse[T any](resp *types.ModelResponse) (T, error) {
+	var out T
+
+	data, err := ExtractJSON(resp.Content)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		return out, fmt.Errorf("decoding response: %w", err)
+	}
+	return out, nil
+}
+
+// ExtractJSON returns the JSON document in a model reply, stripping code
+// fences and any text before or after it
+func ExtractJSON(content string) (string, error) {
+	s := strings.TrimSpace(content)
+
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+		s = strings.TrimSpace(s)
+	}
+	if json.Valid([]byte(s)) {
+		return s, nil
+	}
+
+	// Fall back to the outermost object or array
+	start := strings.IndexAny(s, "{[")
+	if start >= 0 {
+		closer := "}"
+		if s[start] == '[' {
+			closer = "]"
+		}
+		if end := strings.LastIndex(s, closer); end > start {
+			if candidate := s[start : end+1]; json.Valid([]byte(candidate)) {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no JSON document found in response")
+}
+
+// ValidateJSON checks a JSON document against a schema. It supports the
+// subset of JSON Schema used for structured output: type, properties,
+// required, additionalProperties, items, enum, minimum/maximum,
+// minItems/maxItems and minLength/maxLength.
+func ValidateJSON(data string, schema map[string]any) error {
+	var v any
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return validateValue("$", v, schema)
+}
+
+func validateValue(path string, v any, schema map[string]any) error {
+	if t, ok := schema["type"]; ok && !matchesType(v, t) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, jsonType(v))
+	}
+
+	if enum := anyList(schema["enum"]); enum != nil && !slices.ContainsFunc(enum, func(e any) bool { return jsonEqual(e, v) }) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, enum)
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := val[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			propSchema, ok := props[k].(map[string]any)
+			if !ok {
+				if extra, ok := schema["additionalProperties"].(bool); ok && !extra {
+					return fmt.Errorf("%s: unexpected property %q", path, k)
+				}
+				continue
+			}
+			if err := validateValue(path+"."+k, val[k], propSchema); err != nil {
+				return err
+			}
+		}
+
+	case []any:
+		if n, ok := number(schema["minItems"]); ok && float64(len(val)) < n {
+			return fmt.Errorf("%s: expected at least %v items, got %d", path, n, len(val))
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(val)) > n {
+			return fmt.Errorf("%s: expected at most %v items, got %d", path, n, len(val))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range val {
+				if err := validateValue(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+					return err
+				}
+			}
+		}
+
+	case float64:
+		if n, ok := number(schema["minimum"]); ok && val < n {
+			return fmt.Errorf("%s: %v is below the minimum %v", path, val, n)
+		}
+		if n, ok := number(schema["maximum"]); ok && val > n {
+			return fmt.Errorf("%s: %v is above the maximum %v", path, val, n)
+		}
+
+	case string:
+		if n, ok := number(schema["minLength"]); ok && float64(len(val)) < n {
+			return fmt.Errorf("%s: shorter than %v characters", path, n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && float64(len(val)) > n {
+			return fmt.Errorf("%s: longer than %v characters", path, n)
+		}
+	}
+
+	return nil
+}
+
+// matchesType checks a decoded value against a type name or list of names
+func matchesType(v any, t any) bool {
+	for _, name := range stringList(t) {
+		actual := jsonType(v)
+		if actual == name || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+// stringList accepts a string, []string or []any of strings
+func stringList(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []string:
+		return val
+	case []any:
+		var out []string
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// anyList accepts []any or []string, as schemas built in Go often use the latter
+func anyList(v any) []any {
+	switch val := v.(type) {
+	case []any:
+		return val
+	case []string:
+		out := make([]any, len(val))
+		for i, s := range val {
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// schemaInstructions describes the schema for models without native
+// structured output
+func schemaInstructions(s *JSONSchema) string {
+	schema, _ := json.MarshalIndent(s.Schema, "", "  ")
+	return fmt.Sprintf("\n\nRespond with only a JSON document that matches this JSON Schema. "+
+		"Do not wrap it in markdown or add any other text.\n\n%s", schema)
+}
+
+// applyResponseFormat requests schema-constrained output from chat
+// completions endpoints
+func applyResponseFormat(body map[string]any, req *GenerateRequest) {
+	if req.ResponseSchema == nil {
+		return
+	}
+	body["response_format"] = map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   schemaName(req.ResponseSchema),
+			"schema": req.ResponseSchema.Schema,
+			"strict": req.ResponseSchema.Strict,
+		},
+	}
+}
+
+func schemaName(s *JSONSchema) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "response"
+}
+
+// geminiSchema converts a JSON Schema to Gemini's OpenAPI-style schema,
+// which uses upper-case type names and rejects keywords it doesn't know
+func geminiSchema(schema map[string]any) map[string]any {
+	out := make(map[string]any, len(schema))
+	for k, v := range schema {
+		switch k {
+		case "type":
+			if s, ok := v.(string); ok {
+				v = strings.ToUpper(s)
+			}
+		case "properties":
+			if props, ok := v.(map[string]any); ok {
+				converted := make(map[string]any, len(props))
+				for name, prop := range props {
+					if p, ok := prop.(map[string]any); ok {
+						converted[name] = geminiSchema(p)
+					}
+				}
+				v = converted
+			}
+		case "items":
+			if items, ok := v.(map[string]any); ok {
+				v = geminiSchema(items)
+			}
+		case "additionalProperties", "$schema", "$id", "title":
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
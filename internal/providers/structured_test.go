@@ -0,0 +1,223 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+var testSchema = &JSONSchema{
+	Name:   "verdict",
+	Strict: true,
+	Schema: map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"score", "severity"},
+		"properties": map[string]any{
+			"score":    map[string]any{"type": "integer", "minimum": 1, "maximum": 10},
+			"severity": map[string]any{"type": "string", "enum": []string{"low", "high"}},
+			"notes":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	},
+}
+
+type verdict struct {
+	Score    int    `json:"score"`
+	Severity string `json:"severity"`
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", `{"a":1}`, `{"a":1}`},
+		{"fenced", "```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"prose", "Here you go:\n{\"a\":1}\nHope that helps.", `{"a":1}`},
+		{"array", "Result: [1, 2]", `[1, 2]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractJSON(tt.content)
+			if err != nil || got != tt.want {
+				t.Errorf("ExtractJSON() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ExtractJSON("no json here"); err == nil {
+		t.Error("expected an error for a reply without JSON")
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		wantErr string
+	}{
+		{`{"score": 7, "severity": "low"}`, ""},
+		{`{"score": 7, "severity": "low", "notes": ["a"]}`, ""},
+		{`{"score": 7}`, `missing required property "severity"`},
+		{`{"score": 11, "severity": "low"}`, "above the maximum"},
+		{`{"score": 7.5, "severity": "low"}`, "expected integer"},
+		{`{"score": 7, "severity": "urgent"}`, "not one of"},
+		{`{"score": 7, "severity": "low", "extra": true}`, `unexpected property "extra"`},
+		{`{"score": 7, "severity": "low", "notes": [1]}`, "$.notes[0]: expected string"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateJSON(tt.data, testSchema.Schema)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("ValidateJSON(%s) unexpected error: %v", tt.data, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ValidateJSON(%s) = %v, want error containing %q", tt.data, err, tt.wantErr)
+		}
+	}
+}
+
+func TestManagedProvider_NativeSchema(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Content: `{"score": 8, "severity": "high"}`}}},
+		})
+	}))
+	defer server.Close()
+
+	p := &managedProvider{Provider: NewOpenAICompatProvider(types.ProviderOpenAI, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "gpt-4.1", SupportsJSONSchema: true},
+	}, 0)}
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "judge", Model: "gpt-4.1", ResponseSchema: testSchema})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	format, _ := captured["response_format"].(map[string]any)
+	schema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || schema["name"] != "verdict" || schema["strict"] != true {
+		t.Errorf("expected json_schema response_format, got %v", captured["response_format"])
+	}
+	if resp.Metadata["structured_output"] != StructuredNative {
+		t.Errorf("expected native structured output, got %v", resp.Metadata)
+	}
+
+	v, err := DecodeResponse[verdict](resp)
+	if err != nil || v.Score != 8 || v.Severity != "high" {
+		t.Errorf("DecodeResponse() = %+v, %v", v, err)
+	}
+}
+
+func TestManagedProvider_PromptSchemaRepair(t *testing.T) {
+	var bodies []map[string]any
+	replies := []string{
+		"Sure! ```json\n{\"score\": 12, \"severity\": \"high\"}\n```",
+		`{"score": 9, "severity": "high"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Content: replies[len(bodies)-1]}}},
+		})
+	}))
+	defer server.Close()
+
+	p := &managedProvider{Provider: NewOpenAICompatProvider(types.ProviderCustom, "", server.URL, []types.ModelCapabilities{
+		{ModelName: "local"},
+	}, 0)}
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "judge", Model: "local", ResponseSchema: testSchema})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("expected one repair call, got %d calls", len(bodies))
+	}
+	if _, ok := bodies[0]["response_format"]; ok {
+		t.Error("prompt fallback should not send response_format")
+	}
+	messages := bodies[0]["messages"].([]any)
+	if prompt := messages[len(messages)-1].(map[string]any)["content"].(string); !strings.Contains(prompt, `"severity"`) {
+		t.Errorf("expected the schema in the prompt, got %q", prompt)
+	}
+	repair := bodies[1]["messages"].([]any)
+	if last := repair[len(repair)-1].(map[string]any)["content"].(string); !strings.Contains(last, "above the maximum") {
+		t.Errorf("expected the validation error in the repair prompt, got %q", last)
+	}
+
+	if resp.Content != `{"score": 9, "severity": "high"}` || resp.Metadata["structured_output"] != StructuredPrompt {
+		t.Errorf("unexpected response %q, %v", resp.Content, resp.Metadata)
+	}
+}
+
+func TestManagedProvider_SchemaMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openAIResponse{
+			Choices: []openAIChoice{{Message: openAIMessage{Content: "I can't do JSON"}}},
+		})
+	}))
+	defer server.Close()
+
+	r := NewRegistry(&config.Config{})
+	p := &managedProvider{Provider: NewOpenAICompatProvider(types.ProviderCustom, "", server.URL, []types.ModelCapabilities{
+		{ModelName: "local"},
+	}, 0), registry: r}
+
+	_, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "judge", Model: "local", ResponseSchema: testSchema})
+	var mismatch ErrSchemaMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected schema mismatch, got %v", err)
+	}
+	if h := r.ProviderHealth(types.ProviderCustom); !h.Healthy() || h.Failures != 0 {
+		t.Errorf("a bad reply should not count against provider health, got %+v", h)
+	}
+}
+
+func TestGeminiProvider_ResponseSchema(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"{\"score\":3,\"severity\":\"low\"}"}]}}]}`))
+	}))
+	defer server.Close()
+
+	p, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "key"})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.baseURL = server.URL
+
+	if _, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "judge", Model: "flash", ResponseSchema: testSchema}); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	genConfig := captured["generationConfig"].(map[string]any)
+	schema, _ := genConfig["responseSchema"].(map[string]any)
+	if genConfig["responseMimeType"] != "application/json" || schema["type"] != "OBJECT" {
+		t.Errorf("expected JSON mime type and converted schema, got %v", genConfig)
+	}
+	if _, ok := schema["additionalProperties"]; ok {
+		t.Error("additionalProperties should be stripped for Gemini")
+	}
+	props := schema["properties"].(map[string]any)
+	if props["score"].(map[string]any)["type"] != "INTEGER" {
+		t.Errorf("expected nested types converted, got %v", props)
+	}
+}
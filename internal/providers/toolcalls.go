@@ -0,0 +1,271 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// ToolChoiceNone sends the tool definitions but forbids calling them, so the
+// model has to answer with what it already has
+const ToolChoiceNone = "none"
+
+// FunctionDef declares a function the model may call
+type FunctionDef struct {
+	Name        string
+	Description string
+	Parameters  map[string]any // JSON Schema for the arguments object
+}
+
+// ToolExchange is one round of function calling within a request: the
+// model's reply asking for calls, and the results sent back
+type ToolExchange struct {
+	Content string // text the model sent alongside the calls, if any
+	Calls   []types.ToolCall
+	Results []ToolResult
+}
+
+// ToolResult is the output of one tool call
+type ToolResult struct {
+	CallID  string
+	Name    string
+	Content string
+}
+
+// parameters returns the function's argument schema, defaulting to an
+// object without properties
+func (f FunctionDef) parameters() map[string]any {
+	if f.Parameters != nil {
+		return f.Parameters
+	}
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+// applyTools adds function definitions to a chat completions request
+func applyTools(body map[string]any, req *GenerateRequest) {
+	if len(req.Tools) == 0 {
+		return
+	}
+
+	tools := make([]map[string]any, len(req.Tools))
+	for i, fn := range req.Tools {
+		tools[i] = map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        fn.Name,
+				"description": fn.Description,
+				"parameters":  fn.parameters(),
+			},
+		}
+	}
+	body["tools"] = tools
+
+	if req.ToolChoice != "" {
+		body["tool_choice"] = req.ToolChoice
+	}
+}
+
+// appendToolMessages renders earlier tool rounds as chat completions
+// messages: an assistant message carrying the calls, then one tool message
+// per result
+func appendToolMessages(messages []map[string]any, turns []ToolExchange) []map[string]any {
+	for _, ex := range turns {
+		calls := make([]map[string]any, len(ex.Calls))
+		for i, c := range ex.Calls {
+			calls[i] = map[string]any{
+				"id":   c.ID,
+				"type": "function",
+				"function": map[string]any{
+					"name":      c.Name,
+					"arguments": c.Arguments,
+				},
+			}
+		}
+
+		msg := map[string]any{
+			"role":       "assistant",
+			"content":    nil,
+			"tool_calls": calls,
+		}
+		if ex.Content != "" {
+			msg["content"] = ex.Content
+		}
+		messages = append(messages, msg)
+
+		for _, r := range ex.Results {
+			messages = append(messages, map[string]any{
+				"role":         "tool",
+				"tool_call_id": r.CallID,
+				"content":      r.Content,
+			})
+		}
+	}
+	return messages
+}
+
+// parseToolCalls converts chat completions tool calls
+func parseToolCalls(calls []openAIToolCall) []types.ToolCall {
+	var out []types.ToolCall
+	for _, c := range calls {
+		if c.Type != "" && c.Type != "function" {
+			continue
+		}
+		out = append(out, types.ToolCall{
+			ID:        c.ID,
+			Name:      c.Function.Name,
+			Arguments: c.Function.Arguments,
+		})
+	}
+	return out
+}
+
+// applyResponsesTools adds function definitions to a Responses API request,
+// which declares them without the nested function object
+func applyResponsesTools(body map[string]any, req *GenerateRequest) {
+	if len(req.Tools) == 0 {
+		return
+	}
+
+	tools := make([]map[string]any, len(req.Tools))
+	for i, fn := range req.Tools {
+		tools[i] = map[string]any{
+			"type":        "function",
+			"name":        fn.Name,
+			"description": fn.Description,
+			"parameters":  fn.parameters(),
+		}
+	}
+	body["tools"] = tools
+
+	if req.ToolChoice != "" {
+		body["tool_choice"] = req.ToolChoice
+	}
+}
+
+// responsesToolItems renders earlier tool rounds as Responses API input items
+func responsesToolItems(turns []ToolExchange) []map[string]any {
+	var items []map[string]any
+	for _, ex := range turns {
+		if ex.Content != "" {
+			items = append(items, map[string]any{
+				"role":    "assistant",
+				"content": ex.Content,
+			})
+		}
+		for _, c := range ex.Calls {
+			items = append(items, map[string]any{
+				"type":      "function_call",
+				"call_id":   c.ID,
+				"name":      c.Name,
+				"arguments": c.Arguments,
+			})
+		}
+		for _, r := range ex.Results {
+			items = append(items, map[string]any{
+				"type":    "function_call_output",
+				"call_id": r.CallID,
+				"output":  r.Content,
+			})
+		}
+	}
+	return items
+}
+
+// applyGeminiTools adds function declarations to a Gemini request
+func applyGeminiTools(body map[string]any, req *GenerateRequest) {
+	if len(req.Tools) == 0 {
+		return
+	}
+
+	decls := make([]map[string]any, len(req.Tools))
+	for i, fn := range req.Tools {
+		decl := map[string]any{
+			"name":        fn.Name,
+			"description": fn.Description,
+		}
+		// Gemini rejects an OBJECT schema without properties, so functions
+		// without arguments omit the schema
+		if props, ok := fn.Parameters["properties"].(map[string]any); ok && len(props) > 0 {
+			decl["parameters"] = geminiSchema(fn.Parameters)
+		}
+		decls[i] = decl
+	}
+	body["tools"] = []map[string]any{
+		{"functionDeclarations": decls},
+	}
+
+	if req.ToolChoice == ToolChoiceNone {
+		body["toolConfig"] = map[string]any{
+			"functionCallingConfig": map[string]any{"mode": "NONE"},
+		}
+	}
+}
+
+// geminiToolContents renders earlier tool rounds as Gemini contents: a model
+// turn with the function calls, then a user turn with their responses
+func geminiToolContents(turns []ToolExchange) []map[string]any {
+	var contents []map[string]any
+	for _, ex := range turns {
+		var parts []map[string]any
+		if ex.Content != "" {
+			parts = append(parts, map[string]any{"text": ex.Content})
+		}
+		for _, c := range ex.Calls {
+			var args map[string]any
+			if err := json.Unmarshal([]byte(c.Arguments), &args); err != nil || args == nil {
+				args = map[string]any{}
+			}
+			part := map[string]any{
+				"functionCall": map[string]any{"name": c.Name, "args": args},
+			}
+			if c.Signature != "" {
+				part["thoughtSignature"] = c.Signature
+			}
+			parts = append(parts, part)
+		}
+		contents = append(contents, map[string]any{"role": "model", "parts": parts})
+
+		var responses []map[string]any
+		for _, r := range ex.Results {
+			responses = append(responses, map[string]any{
+				"functionResponse": map[string]any{
+					"name":     r.Name,
+					"response": map[string]any{"content": r.Content},
+				},
+			})
+		}
+		if len(responses) > 0 {
+			contents = append(contents, map[string]any{"role": "user", "parts": responses})
+		}
+	}
+	return contents
+}
+
+// geminiToolCalls collects the function calls in a Gemini candidate. Gemini
+// doesn't always return call IDs, so missing ones are numbered.
+func geminiToolCalls(parts []geminiPart) []types.ToolCall {
+	var calls []types.ToolCall
+	for _, part := range parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+
+		args := string(part.FunctionCall.Args)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", len(calls)+1)
+		}
+
+		calls = append(calls, types.ToolCall{
+			ID:        id,
+			Name:      part.FunctionCall.Name,
+			Arguments: args,
+			Signature: part.ThoughtSignature,
+		})
+	}
+	return calls
+}
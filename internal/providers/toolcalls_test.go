@@ -0,0 +1,229 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+var readFileDef = FunctionDef{
+	Name:        "read_file",
+	Description: "Read a file",
+	Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"path": map[string]any{"type": "string"}},
+		"required":   []string{"path"},
+	},
+}
+
+// toolRound is one completed round of calls, sent back with the next request
+var toolRound = ToolExchange{
+	Calls:   []types.ToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`, Signature: "sig"}},
+	Results: []ToolResult{{CallID: "call_1", Name: "read_file", Content: "package main"}},
+}
+
+func TestOpenAICompatProvider_ToolCalls(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"choices":[{"finish_reason":"tool_calls","message":{"role":"assistant","content":null,
+			"tool_calls":[{"id":"call_2","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"go.mod\"}"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", server.URL, []types.ModelCapabilities{{ModelName: "gpt-4.1"}}, 0)
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:    "what module is this?",
+		Model:     "gpt-4.1",
+		Tools:     []FunctionDef{readFileDef},
+		ToolTurns: []ToolExchange{toolRound},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	tools := captured["tools"].([]any)
+	fn := tools[0].(map[string]any)["function"].(map[string]any)
+	if fn["name"] != "read_file" || fn["parameters"] == nil {
+		t.Errorf("unexpected tools %v", tools)
+	}
+
+	messages := captured["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected user, assistant and tool messages, got %v", messages)
+	}
+	assistant := messages[1].(map[string]any)
+	call := assistant["tool_calls"].([]any)[0].(map[string]any)
+	if assistant["role"] != "assistant" || call["id"] != "call_1" || call["function"].(map[string]any)["arguments"] != `{"path":"main.go"}` {
+		t.Errorf("unexpected assistant message %v", assistant)
+	}
+	result := messages[2].(map[string]any)
+	if result["role"] != "tool" || result["tool_call_id"] != "call_1" || result["content"] != "package main" {
+		t.Errorf("unexpected tool message %v", result)
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "call_2" || resp.ToolCalls[0].Arguments != `{"path":"go.mod"}` {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+}
+
+func TestAzureProvider_ToolChoiceNone(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"done"}}]}`))
+	}))
+	defer server.Close()
+
+	p, err := NewAzureProvider(&config.Config{AzureAPIKey: "key", AzureEndpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewAzureProvider failed: %v", err)
+	}
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:     "answer now",
+		Model:      "gpt-4o",
+		Tools:      []FunctionDef{readFileDef},
+		ToolTurns:  []ToolExchange{toolRound},
+		ToolChoice: ToolChoiceNone,
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	if captured["tool_choice"] != "none" || captured["tools"] == nil {
+		t.Errorf("expected tools with tool_choice none, got %v", captured)
+	}
+	if messages := captured["messages"].([]any); messages[len(messages)-1].(map[string]any)["role"] != "tool" {
+		t.Errorf("expected tool results after the prompt, got %v", messages)
+	}
+	if resp.Content != "done" || len(resp.ToolCalls) != 0 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestOpenAICompatProvider_ResponsesToolCalls(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"id":"resp_1","status":"completed","output":[
+			{"type":"function_call","call_id":"call_2","name":"grep","arguments":"{\"pattern\":\"TODO\"}"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewOpenAICompatProvider(types.ProviderOpenAI, "key", server.URL, []types.ModelCapabilities{
+		{ModelName: "gpt-5-pro", API: types.APIResponses},
+	}, 0)
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:    "find TODOs",
+		Model:     "gpt-5-pro",
+		ThreadID:  "thread-1",
+		Tools:     []FunctionDef{readFileDef},
+		ToolTurns: []ToolExchange{toolRound},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	tool := captured["tools"].([]any)[0].(map[string]any)
+	if tool["type"] != "function" || tool["name"] != "read_file" {
+		t.Errorf("unexpected tools %v", captured["tools"])
+	}
+
+	input := captured["input"].([]any)
+	if len(input) != 3 {
+		t.Fatalf("expected prompt, function_call and output items, got %v", input)
+	}
+	if item := input[1].(map[string]any); item["type"] != "function_call" || item["call_id"] != "call_1" {
+		t.Errorf("unexpected call item %v", item)
+	}
+	if item := input[2].(map[string]any); item["type"] != "function_call_output" || item["output"] != "package main" {
+		t.Errorf("unexpected output item %v", item)
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "grep" || resp.ToolCalls[0].ID != "call_2" {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+}
+
+func TestGeminiProvider_ToolCalls(t *testing.T) {
+	var captured map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&captured)
+		w.Write([]byte(`{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[
+			{"functionCall":{"name":"list_dir","args":{"path":"internal"}},"thoughtSignature":"abc"},
+			{"functionCall":{"name":"list_dir"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	p, err := NewGeminiProvider(&config.Config{GeminiAPIKey: "key"})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	p.baseURL = server.URL
+
+	resp, err := p.GenerateContent(context.Background(), &GenerateRequest{
+		Prompt:    "look around",
+		Model:     "flash",
+		Tools:     []FunctionDef{readFileDef, {Name: "list_dir", Description: "List"}},
+		ToolTurns: []ToolExchange{toolRound},
+	})
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	decls := captured["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)
+	params := decls[0].(map[string]any)["parameters"].(map[string]any)
+	if params["type"] != "OBJECT" {
+		t.Errorf("expected a Gemini schema for parameters, got %v", params)
+	}
+	if _, ok := decls[1].(map[string]any)["parameters"]; ok {
+		t.Error("functions without arguments should omit parameters")
+	}
+
+	contents := captured["contents"].([]any)
+	if len(contents) != 3 {
+		t.Fatalf("expected prompt, model call and function response, got %v", contents)
+	}
+	modelPart := contents[1].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	if modelPart["thoughtSignature"] != "sig" || modelPart["functionCall"].(map[string]any)["args"].(map[string]any)["path"] != "main.go" {
+		t.Errorf("unexpected model part %v", modelPart)
+	}
+	response := contents[2].(map[string]any)["parts"].([]any)[0].(map[string]any)["functionResponse"].(map[string]any)
+	if response["name"] != "read_file" || response["response"].(map[string]any)["content"] != "package main" {
+		t.Errorf("unexpected function response %v", response)
+	}
+
+	if len(resp.ToolCalls) != 2 {
+		t.Fatalf("expected two tool calls, got %+v", resp.ToolCalls)
+	}
+	first, second := resp.ToolCalls[0], resp.ToolCalls[1]
+	if first.ID != "call_1" || first.Arguments != `{"path":"internal"}` || first.Signature != "abc" {
+		t.Errorf("unexpected first call %+v", first)
+	}
+	if second.ID != "call_2" || second.Arguments != "{}" {
+		t.Errorf("unexpected second call %+v", second)
+	}
+}
+
+func TestNormalizeRequest_DropsUnsupportedTools(t *testing.T) {
+	req := &GenerateRequest{Prompt: "hi", Tools: []FunctionDef{readFileDef}, ToolChoice: ToolChoiceNone}
+
+	out, adjustments, err := NormalizeRequest(req, &types.ModelCapabilities{ModelName: "old"}, true)
+	if err != nil {
+		t.Fatalf("NormalizeRequest failed: %v", err)
+	}
+	if out.Tools != nil || out.ToolChoice != "" || len(adjustments) != 1 {
+		t.Errorf("expected tools dropped with an adjustment, got %+v, %v", out, adjustments)
+	}
+
+	out, _, _ = NormalizeRequest(req, &types.ModelCapabilities{ModelName: "new", SupportsFunctionCalling: true}, true)
+	if len(out.Tools) != 1 {
+		t.Error("tools should be kept for models with function calling")
+	}
+}
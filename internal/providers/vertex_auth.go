@@ -0,0 +1,282 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+	gceMetadataURL    = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	jwtBearerGrant    = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	serviceAccountJWT = time.Hour
+)
+
+// googleCredentials is a service-account key or gcloud's authorized-user
+// application default credentials file
+type googleCredentials struct {
+	Type string `json:"type"` // service_account or authorized_user
+
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleTokenSource fetches and caches OAuth2 access tokens for Vertex AI
+// from a service-account key (a signed JWT exchanged for a token), gcloud
+// user credentials or the GCE metadata server
+type googleTokenSource struct {
+	creds *googleCredentials // nil uses the metadata server
+	key   *rsa.PrivateKey
+
+	// Endpoints, overridable for tests
+	tokenURL    string
+	metadataURL string
+
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// newGoogleTokenSource loads credentials from path, or finds Application
+// Default Credentials when path is empty: GOOGLE_APPLICATION_CREDENTIALS,
+// then gcloud's well-known file, then the metadata server
+func newGoogleTokenSource(path string) (*googleTokenSource, error) {
+	s := &googleTokenSource{
+		tokenURL:    googleTokenURL,
+		metadataURL: gceMetadataURL,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if path == "" {
+		path = defaultCredentialsPath()
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading Google credentials: %w", err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parsing Google credentials %s: %w", path, err)
+	}
+
+	switch creds.Type {
+	case "service_account":
+		if creds.ClientEmail == "" || creds.PrivateKey == "" {
+			return nil, fmt.Errorf("service account %s has no client_email or private_key", path)
+		}
+		if s.key, err = parseRSAPrivateKey(creds.PrivateKey); err != nil {
+			return nil, fmt.Errorf("service account %s: %w", path, err)
+		}
+		if creds.TokenURI != "" {
+			s.tokenURL = creds.TokenURI
+		}
+	case "authorized_user":
+		if creds.ClientID == "" || creds.ClientSecret == "" || creds.RefreshToken == "" {
+			return nil, fmt.Errorf("user credentials %s need client_id, client_secret and refresh_token", path)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Google credentials type %q in %s", creds.Type, path)
+	}
+
+	s.creds = &creds
+	return s, nil
+}
+
+// defaultCredentialsPath returns the ADC file to use, or "" for the
+// metadata server
+func defaultCredentialsPath() string {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return path
+	}
+
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			dir = filepath.Join(appData, "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gcloud")
+		}
+	}
+	if dir == "" {
+		return ""
+	}
+
+	path := filepath.Join(dir, "application_default_credentials.json")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// Token returns a cached token, fetching a new one when it is close to expiry
+func (s *googleTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expiry) > tokenRefreshSkew {
+		return s.token, nil
+	}
+
+	var httpReq *http.Request
+	var err error
+	switch {
+	case s.creds == nil:
+		httpReq, err = http.NewRequestWithContext(ctx, "GET", s.metadataURL+"?scopes="+url.QueryEscape(cloudPlatformScope), nil)
+		if err == nil {
+			httpReq.Header.Set("Metadata-Flavor", "Google")
+		}
+	case s.creds.Type == "service_account":
+		httpReq, err = s.jwtRequest(ctx)
+	default:
+		httpReq, err = s.formRequest(ctx, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {s.creds.ClientID},
+			"client_secret": {s.creds.ClientSecret},
+			"refresh_token": {s.creds.RefreshToken},
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("acquiring Google access token: %w", err)
+	}
+
+	tok, err := s.do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("acquiring Google access token: %w", err)
+	}
+
+	s.token = tok.AccessToken
+	s.expiry = tok.expiry()
+	return s.token, nil
+}
+
+// jwtRequest signs a service-account assertion and builds the token request
+func (s *googleTokenSource) jwtRequest(ctx context.Context) (*http.Request, error) {
+	now := time.Now()
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if s.creds.PrivateKeyID != "" {
+		header["kid"] = s.creds.PrivateKeyID
+	}
+	claims := map[string]any{
+		"iss":   s.creds.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   s.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(serviceAccountJWT).Unix(),
+	}
+
+	assertion, err := signJWT(s.key, header, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.formRequest(ctx, url.Values{"grant_type": {jwtBearerGrant}, "assertion": {assertion}})
+}
+
+func (s *googleTokenSource) formRequest(ctx context.Context, form url.Values) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return httpReq, nil
+}
+
+// do sends a token request. Google's token responses have the same shape
+// as Entra ID's, so the Azure token type is reused.
+func (s *googleTokenSource) do(httpReq *http.Request) (*entraToken, error) {
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint error %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var tok entraToken
+	if err := json.Unmarshal(respBody, &tok); err != nil {
+		return nil, fmt.Errorf("parsing token: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access_token")
+	}
+
+	return &tok, nil
+}
+
+// signJWT returns header.claims.signature signed with RS256
+func signJWT(key *rsa.PrivateKey, header map[string]string, claims map[string]any) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing JWT: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+// parseRSAPrivateKey decodes a PEM PKCS#8 or PKCS#1 RSA key
+func parseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("private_key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private_key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private_key: %w", err)
+	}
+	return key, nil
+}
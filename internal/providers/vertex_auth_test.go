@@ -0,0 +1,224 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+)
+
+var testRSAKey = sync.OnceValue(func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// writeCredentials writes a Google credentials file and returns its path
+func writeCredentials(t *testing.T, creds map[string]string) string {
+	t.Helper()
+	data, _ := json.Marshal(creds)
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeServiceAccount writes a service-account key for testRSAKey
+func writeServiceAccount(t *testing.T, tokenURI string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(testRSAKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeCredentials(t, map[string]string{
+		"type":           "service_account",
+		"client_email":   "relay@example-project.iam.gserviceaccount.com",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      tokenURI,
+	})
+}
+
+// verifyJWT checks an RS256 assertion against testRSAKey and returns its claims
+func verifyJWT(t *testing.T, assertion string) map[string]any {
+	t.Helper()
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT %q", assertion)
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&testRSAKey().PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatalf("JWT signature invalid: %v", err)
+	}
+
+	var header, claims map[string]any
+	h, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	c, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(h, &header)
+	json.Unmarshal(c, &claims)
+	if header["alg"] != "RS256" || header["kid"] != "key-1" {
+		t.Errorf("unexpected JWT header %v", header)
+	}
+	return claims
+}
+
+func TestGeminiProvider_VertexServiceAccount(t *testing.T) {
+	var tokenRequests int
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		r.ParseForm()
+		if r.Form.Get("grant_type") != jwtBearerGrant {
+			t.Errorf("unexpected grant_type %q", r.Form.Get("grant_type"))
+		}
+		claims := verifyJWT(t, r.Form.Get("assertion"))
+		if claims["iss"] != "relay@example-project.iam.gserviceaccount.com" || claims["scope"] != cloudPlatformScope || claims["aud"] != "http://"+r.Host+"/token" {
+			t.Errorf("unexpected JWT claims %v", claims)
+		}
+		fmt.Fprint(w, `{"access_token":"vertex-token","expires_in":3599,"token_type":"Bearer"}`)
+	}))
+	defer tokenServer.Close()
+
+	var paths, auths []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.String())
+		auths = append(auths, r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"hello from vertex"}]},"finishReason":"STOP"}]}`)
+	}))
+	defer apiServer.Close()
+
+	p, err := NewGeminiProvider(&config.Config{
+		VertexProject:     "example-project",
+		VertexLocation:    "europe-west4",
+		VertexCredentials: writeServiceAccount(t, tokenServer.URL+"/token"),
+	})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider failed: %v", err)
+	}
+	if !p.IsConfigured() {
+		t.Error("Vertex mode should count as configured without an API key")
+	}
+	if want := "https://europe-west4-aiplatform.googleapis.com/v1/projects/example-project/locations/europe-west4/publishers/google"; p.baseURL != want {
+		t.Errorf("baseURL = %q, want %q", p.baseURL, want)
+	}
+	p.baseURL = apiServer.URL + "/v1/projects/example-project/locations/europe-west4/publishers/google"
+
+	for range 2 {
+		resp, err := p.GenerateContent(context.Background(), &GenerateRequest{Prompt: "hi", Model: "flash"})
+		if err != nil {
+			t.Fatalf("GenerateContent failed: %v", err)
+		}
+		if resp.Content != "hello from vertex" {
+			t.Errorf("unexpected content %q", resp.Content)
+		}
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("expected the access token to be cached, got %d token requests", tokenRequests)
+	}
+	if paths[0] != "/v1/projects/example-project/locations/europe-west4/publishers/google/models/gemini-2.5-flash:generateContent" {
+		t.Errorf("unexpected Vertex path %q", paths[0])
+	}
+	if auths[1] != "Bearer vertex-token" {
+		t.Errorf("expected a bearer token, got %q", auths[1])
+	}
+}
+
+func TestGoogleTokenSource_ADC(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+		fmt.Fprint(w, `{"access_token":"adc-token","expires_in":3599}`)
+	}))
+	defer server.Close()
+
+	t.Run("authorized user", func(t *testing.T) {
+		t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeCredentials(t, map[string]string{
+			"type":          "authorized_user",
+			"client_id":     "client",
+			"client_secret": "secret",
+			"refresh_token": "refresh",
+		}))
+
+		ts, err := newGoogleTokenSource("")
+		if err != nil {
+			t.Fatalf("newGoogleTokenSource failed: %v", err)
+		}
+		ts.tokenURL = server.URL
+
+		if token, err := ts.Token(context.Background()); err != nil || token != "adc-token" {
+			t.Fatalf("Token() = %q, %v", token, err)
+		}
+		if got.Form.Get("grant_type") != "refresh_token" || got.Form.Get("refresh_token") != "refresh" {
+			t.Errorf("unexpected refresh request %v", got.Form)
+		}
+	})
+
+	t.Run("metadata server", func(t *testing.T) {
+		t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+		t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+
+		ts, err := newGoogleTokenSource("")
+		if err != nil {
+			t.Fatalf("newGoogleTokenSource failed: %v", err)
+		}
+		ts.metadataURL = server.URL
+
+		if token, err := ts.Token(context.Background()); err != nil || token != "adc-token" {
+			t.Fatalf("Token() = %q, %v", token, err)
+		}
+		if got.Method != "GET" || got.Header.Get("Metadata-Flavor") != "Google" || got.URL.Query().Get("scopes") != cloudPlatformScope {
+			t.Errorf("unexpected metadata request %s %s %v", got.Method, got.URL, got.Header)
+		}
+	})
+}
+
+func TestNewGoogleTokenSource_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   map[string]string
+		wantErr string
+	}{
+		{"unknown type", map[string]string{"type": "external_account"}, "unsupported Google credentials type"},
+		{"no key", map[string]string{"type": "service_account", "client_email": "a@b"}, "no client_email or private_key"},
+		{"bad key", map[string]string{"type": "service_account", "client_email": "a@b", "private_key": "nope"}, "not PEM encoded"},
+		{"incomplete user", map[string]string{"type": "authorized_user", "client_id": "c"}, "need client_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newGoogleTokenSource(writeCredentials(t, tt.creds))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected %q error, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVertexBaseURL(t *testing.T) {
+	if got := vertexBaseURL("p", "global"); got != "https://aiplatform.googleapis.com/v1/projects/p/locations/global/publishers/google" {
+		t.Errorf("global endpoint = %q", got)
+	}
+	if got := vertexBaseURL("p", ""); !strings.HasPrefix(got, "https://us-central1-aiplatform.googleapis.com/") {
+		t.Errorf("default location endpoint = %q", got)
+	}
+}
@@ -39,13 +39,14 @@ func NewXAIProvider(cfg *config.Config) (*XAIProvider, error) {
 func defaultXAIModels() []types.ModelCapabilities {
 	return []types.ModelCapabilities{
 		{
-			Provider:          types.ProviderXAI,
-			ModelName:         "grok-beta",
-			FriendlyName:      "Grok Beta",
-			IntelligenceScore: 85,
-			ContextWindow:     128000,
-			MaxOutputTokens:   4096,
-			SupportsStreaming: true,
+			Provider:              types.ProviderXAI,
+			ModelName:             "grok-beta",
+			FriendlyName:          "Grok Beta",
+			IntelligenceScore:     85,
+			ContextWindow:         128000,
+			MaxOutputTokens:       4096,
+			SupportsStreaming:     true,
+			SupportsSystemPrompts: true,
 		},
 	}
 }
@@ -0,0 +1,172 @@
+// Package redact masks API keys and other credentials in errors, log output
+// and tool results before they leave the process. Errors and logs are also
+// scanned for credential-shaped strings; tool results, which carry model
+// output and code, only have the configured secrets masked.
+package redact
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every redacted secret
+const Mask = "[REDACTED]"
+
+// minSecretLen keeps placeholder keys such as "ollama" from masking
+// ordinary words
+const minSecretLen = 8
+
+// Patterns catch credentials that were never configured, such as access
+// tokens fetched at runtime or keys pasted into prompts. The first group of
+// a prefixed pattern is kept; token patterns are masked whole.
+var (
+	prefixed = []*regexp.Regexp{
+		regexp.MustCompile(`([?&](?:key|api_key|apikey|access_token|token|client_secret)=)[^&\s"']+`),
+		regexp.MustCompile(`(?i)(\bBearer\s+)[A-Za-z0-9._~+/=-]{20,}`),
+	}
+	tokens = []*regexp.Regexp{
+		regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),                  // Google API keys
+		regexp.MustCompile(`\bya29\.[0-9A-Za-z_.-]{20,}`),              // Google OAuth2 access tokens
+		regexp.MustCompile(`\bsk-(?:proj-|or-v1-)?[A-Za-z0-9_-]{20,}`), // OpenAI and OpenRouter keys
+		regexp.MustCompile(`\bxai-[A-Za-z0-9]{20,}`),                   // X.AI keys
+	}
+)
+
+var (
+	mu       sync.RWMutex
+	replacer = strings.NewReplacer()
+)
+
+// SetSecrets replaces the set of secrets masked by String. Values shorter
+// than eight characters are ignored.
+func SetSecrets(secrets ...string) {
+	var keep []string
+	for _, s := range secrets {
+		if len(s) >= minSecretLen && !slices.Contains(keep, s) {
+			keep = append(keep, s)
+		}
+	}
+	// Longest first, so a secret containing another is masked whole
+	slices.SortFunc(keep, func(a, b string) int { return len(b) - len(a) })
+
+	pairs := make([]string, 0, 2*len(keep))
+	for _, s := range keep {
+		pairs = append(pairs, s, Mask)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// Secrets masks only the configured secrets, leaving code samples and docs
+// that merely look like credentials intact
+func Secrets(s string) string {
+	mu.RLock()
+	r := replacer
+	mu.RUnlock()
+	return r.Replace(s)
+}
+
+// String masks configured secrets and credential-shaped substrings
+func String(s string) string {
+	s = Secrets(s)
+	for _, re := range prefixed {
+		s = re.ReplaceAllString(s, "${1}"+Mask)
+	}
+	for _, re := range tokens {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	return s
+}
+
+// Error wraps err so its message is redacted. The original error stays
+// reachable through errors.Is and errors.As.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	clean := String(msg)
+	if clean == msg {
+		return err
+	}
+	return &redactedError{err: err, msg: clean}
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Handler is a slog.Handler that redacts messages and attribute values
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler wraps next with redaction
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	clean := slog.NewRecord(r.Time, r.Level, String(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		clean.AddAttrs(attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = attr(a)
+	}
+	return &Handler{next: h.next.WithAttrs(clean)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
+
+// attr redacts one attribute. Values other than strings and groups are
+// formatted to check for secrets and only replaced when something was
+// masked, so structured values stay structured in the common case.
+func attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, String(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		clean := make([]any, len(group))
+		for i, g := range group {
+			clean[i] = attr(g)
+		}
+		return slog.Group(a.Key, clean...)
+	case slog.KindAny:
+		var text string
+		if err, ok := v.Any().(error); ok {
+			text = err.Error()
+		} else {
+			text = fmt.Sprintf("%+v", v.Any())
+		}
+		if clean := String(text); clean != text {
+			return slog.String(a.Key, clean)
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -3,7 +3,9 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -11,6 +13,7 @@ import (
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/redact"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools/simple"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools/workflow"
@@ -52,6 +55,7 @@ func (s *Server) registerTools() {
 	// Simple tools
 	s.registerTool(simple.NewVersionTool(s.cfg))
 	s.registerTool(simple.NewListModelsTool(s.cfg, s.registry))
+	s.registerTool(simple.NewDoctorTool(s.cfg, s.registry))
 	s.registerTool(simple.NewChatTool(s.cfg, s.registry, s.memory))
 	s.registerTool(simple.NewAPILookupTool(s.cfg, s.registry, s.memory))
 	s.registerTool(simple.NewChallengeTool(s.cfg, s.registry, s.memory))
@@ -109,17 +113,31 @@ func (s *Server) handleToolCall(t tools.Tool) server.ToolHandlerFunc {
 		// request.Params.Arguments is likely map[string]interface{}
 		args := request.Params.Arguments
 
-		// Execute tool
+		// Execute tool, collecting the cost of its model calls and notes on
+		// how they were routed and trimmed
+		ctx, usage := providers.WithToolUsage(ctx, t.Name())
+		ctx, notes := providers.WithCallNotes(ctx)
 		result, err := t.Execute(ctx, args)
 		if err != nil {
 			slog.Error("tool execution failed", "name", t.Name(), "error", err)
-			res := mcp.NewToolResultText(err.Error())
+			res := mcp.NewToolResultText(redact.String(err.Error()))
 			res.IsError = true
 			return res, nil
 		}
 
 		// Return result
-		return mcp.NewToolResultText(result.Content), nil
+		content := result.Content
+		if summary := usage.Summary(); summary != "" {
+			content = strings.TrimRight(content, "\n") +
+				fmt.Sprintf("\ncost: %s; today $%.4f\n", summary, s.registry.Costs().Today().TotalUSD)
+		}
+		for _, why := range notes.Routing() {
+			content = strings.TrimRight(content, "\n") + "\nrouting: " + why + "\n"
+		}
+		for _, report := range notes.ContextTrimmed() {
+			content = strings.TrimRight(content, "\n") + "\n" + report
+		}
+		return mcp.NewToolResultText(redact.Secrets(content)), nil
 	}
 }
 
@@ -128,6 +146,11 @@ func (s *Server) Run(ctx context.Context) error {
 	// Start conversation memory cleanup goroutine
 	go s.memory.StartCleanup(ctx)
 
+	// Start model discovery for OpenAI-compatible providers
+	if s.cfg.ModelDiscovery {
+		go s.registry.StartDiscovery(ctx)
+	}
+
 	// Run MCP server on stdio
 	return server.ServeStdio(s.mcp)
 }
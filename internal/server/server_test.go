@@ -0,0 +1,347 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+)
+
+// fixtures answer each tool's prompts; the agent loop gets one read_file
+// round before answering
+const fixtures = `{
+  "responses": [
+    {"system": "advocate", "content": "FOR: ship it"},
+    {"system": "critical analyst", "content": "AGAINST: too risky"},
+    {"system": "senior decision-maker", "content": "SYNTHESIS: ship behind a flag"},
+    {"schema": "code_review", "content": "{\"summary\": \"one bug\", \"score\": 7, \"security\": \"ok\", \"performance\": \"ok\", \"issues\": [{\"severity\": \"high\", \"title\": \"nil map\", \"description\": \"write to nil map\", \"location\": \"main.go:3\", \"fix\": \"make the map\"}], \"recommendations\": [\"add tests\"]}"},
+    {"prompt": "what does main do", "times": 1, "tool_calls": [{"id": "call_1", "name": "read_file", "arguments": "{\"path\": \"main.go\"}"}]},
+    {"prompt": "what does main do", "content": "main prints hello"},
+    {"content": "MOCK ANALYSIS"}
+  ]
+}`
+
+// newTestServer builds a server whose only provider is the mock
+func newTestServer(t *testing.T) (*Server, *providers.MockProvider, string) {
+	t.Helper()
+	return newTestServerWith(t, fixtures)
+}
+
+// newTestServerWith builds a server answering from the given fixtures. The
+// mock is its only provider unless configure adds others.
+func newTestServerWith(t *testing.T, fixtures string, configure ...func(*config.Config)) (*Server, *providers.MockProvider, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fixtures.json")
+	if err := os.WriteFile(path, []byte(fixtures), 0644); err != nil {
+		t.Fatal(err)
+	}
+	workDir := filepath.Join(dir, "project")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Version:                  "test",
+		MockFixturesFile:         path,
+		CostLedgerFile:           filepath.Join(dir, "costs.jsonl"),
+		MaxConversationTurns:     50,
+		ConversationTimeoutHours: 3,
+		RequestValidation:        providers.ValidationClamp,
+		AgentTools:               true,
+		AgentMaxIterations:       3,
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	registry := providers.NewRegistry(cfg)
+	if err := registry.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	return New(cfg, registry), registry.MockProvider(), workDir
+}
+
+// call runs a tool through the MCP handler and returns its text
+func (s *Server) call(t *testing.T, name string, args map[string]any) string {
+	t.Helper()
+	tool, ok := s.tools[name]
+	if !ok {
+		t.Fatalf("tool %s not registered", name)
+	}
+
+	var req mcp.CallToolRequest
+	req.Params.Name = name
+	req.Params.Arguments = args
+
+	res, err := s.handleToolCall(tool)(context.Background(), req)
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	text := res.Content[0].(mcp.TextContent).Text
+	if res.IsError {
+		t.Fatalf("%s returned an error: %s", name, text)
+	}
+	return text
+}
+
+// finalStep returns the arguments for a single-step workflow call
+func finalStep(extra map[string]any) map[string]any {
+	args := map[string]any{
+		"step":               "Investigate the service",
+		"step_number":        1,
+		"total_steps":        1,
+		"next_step_required": false,
+		"findings":           "Found a nil map write",
+		"confidence":         "high",
+	}
+	for k, v := range extra {
+		args[k] = v
+	}
+	return args
+}
+
+func TestServer_ToolsWithMockProvider(t *testing.T) {
+	s, mock, workDir := newTestServer(t)
+
+	tests := []struct {
+		tool string
+		args map[string]any
+		want string
+	}{
+		{"version", nil, "test"},
+		{"listmodels", nil, "mock-pro"},
+		{"doctor", nil, "mock"},
+		{"chat", map[string]any{"prompt": "hello", "working_directory_absolute_path": workDir}, "MOCK ANALYSIS"},
+		{"challenge", map[string]any{"topic": "Rewrite it in Rust"}, "MOCK ANALYSIS"},
+		{"apilookup", map[string]any{"query": "Go context"}, "MOCK ANALYSIS"},
+		{"thinkdeep", finalStep(nil), "MOCK ANALYSIS"},
+		{"debug", finalStep(nil), "MOCK ANALYSIS"},
+		{"analyze", finalStep(map[string]any{"query": "How is it layered?"}), "MOCK ANALYSIS"},
+		{"codereview", finalStep(nil), "MOCK ANALYSIS"},
+		{"codereview", finalStep(map[string]any{"output_format": "json"}), `"title": "nil map"`},
+		{"precommit", finalStep(map[string]any{"files": []any{"main.go"}}), "MOCK ANALYSIS"},
+		{"refactor", finalStep(map[string]any{"goal": "simplify", "files": []any{"main.go"}}), "MOCK ANALYSIS"},
+		{"testgen", finalStep(nil), "MOCK ANALYSIS"},
+		{"planner", finalStep(nil), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			out := s.call(t, tt.tool, tt.args)
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("%s output missing %q:\n%s", tt.tool, tt.want, out)
+			}
+		})
+	}
+
+	for _, r := range mock.Requests() {
+		if r.Fixture < 0 {
+			t.Errorf("request to %s matched no fixture: %q", r.Model, r.Prompt)
+		}
+	}
+}
+
+func TestServer_Consensus(t *testing.T) {
+	s, mock, _ := newTestServer(t)
+
+	models := []any{
+		map[string]any{"model": "mock-pro", "stance": "for"},
+		map[string]any{"model": "mock-flash", "stance": "against"},
+	}
+	args := func(step int, next bool, extra map[string]any) map[string]any {
+		a := map[string]any{
+			"step":               "Adopt feature flags for every release",
+			"step_number":        step,
+			"total_steps":        4,
+			"next_step_required": next,
+			"findings":           "Proposal under review",
+			"models":             models,
+		}
+		for k, v := range extra {
+			a[k] = v
+		}
+		return a
+	}
+
+	out := s.call(t, "consensus", args(1, true, nil))
+	if !strings.Contains(out, "Ready to consult 2 models") {
+		t.Fatalf("unexpected first step:\n%s", out)
+	}
+	thread := continuationID(t, out)
+
+	out = s.call(t, "consensus", args(2, true, map[string]any{"continuation_id": thread, "current_model_index": 0}))
+	if !strings.Contains(out, "FOR: ship it") {
+		t.Fatalf("expected the advocate's view:\n%s", out)
+	}
+
+	out = s.call(t, "consensus", args(3, true, map[string]any{
+		"continuation_id":     thread,
+		"current_model_index": 1,
+		"model_responses":     []any{map[string]any{"model": "mock-pro", "stance": "for", "response": "FOR: ship it"}},
+	}))
+	if !strings.Contains(out, "SYNTHESIS: ship behind a flag") {
+		t.Fatalf("expected the synthesis after the last model:\n%s", out)
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 3 || requests[0].Model != "mock-pro" || requests[1].Model != "mock-flash" {
+		t.Fatalf("expected two stance calls and a synthesis, got %+v", requests)
+	}
+	if !strings.Contains(requests[2].Prompt, "FOR: ship it") || !strings.Contains(requests[2].Prompt, "AGAINST: too risky") {
+		t.Errorf("synthesis prompt should include both perspectives:\n%s", requests[2].Prompt)
+	}
+}
+
+func TestServer_ConsensusAcrossProviders(t *testing.T) {
+	var captured map[string]any
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured = nil
+		json.NewDecoder(r.Body).Decode(&captured)
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"AGAINST: keep it local"}}]}`)
+	}))
+	defer local.Close()
+
+	s, mock, _ := newTestServerWith(t, fixtures, func(cfg *config.Config) {
+		cfg.CustomAPIURL = local.URL
+	})
+
+	// labels is on the mock's allowlist but not the custom provider's
+	args := func(step int, next bool, extra map[string]any) map[string]any {
+		a := map[string]any{
+			"step":               "Adopt feature flags for every release",
+			"step_number":        step,
+			"total_steps":        4,
+			"next_step_required": next,
+			"findings":           "Proposal under review",
+			"provider_options":   map[string]any{"labels": map[string]any{"team": "infra"}},
+			"models": []any{
+				map[string]any{"model": "mock:mock-pro", "stance": "for"},
+				map[string]any{"model": "custom:llama3.2", "stance": "against"},
+			},
+		}
+		for k, v := range extra {
+			a[k] = v
+		}
+		return a
+	}
+
+	thread := continuationID(t, s.call(t, "consensus", args(1, true, nil)))
+	s.call(t, "consensus", args(2, true, map[string]any{"continuation_id": thread, "current_model_index": 0}))
+	out := s.call(t, "consensus", args(3, true, map[string]any{
+		"continuation_id":     thread,
+		"current_model_index": 1,
+		"model_responses":     []any{map[string]any{"model": "mock:mock-pro", "stance": "for", "response": "FOR: ship it"}},
+	}))
+	if !strings.Contains(out, "SYNTHESIS: ship behind a flag") {
+		t.Fatalf("expected the synthesis after the last model:\n%s", out)
+	}
+
+	if captured == nil {
+		t.Fatal("expected the custom provider to be consulted")
+	}
+	if _, ok := captured["labels"]; ok {
+		t.Errorf("expected options the custom provider rejects to be left out, got %v", captured)
+	}
+	requests := mock.Requests()
+	if len(requests) != 2 || !slices.Equal(requests[0].ProviderOptions, []string{"labels"}) || !slices.Equal(requests[1].ProviderOptions, []string{"labels"}) {
+		t.Errorf("expected the mock's stance call and synthesis to get the options, got %+v", requests)
+	}
+	if !strings.Contains(requests[1].Prompt, "AGAINST: keep it local") {
+		t.Errorf("synthesis prompt should include the custom model's view:\n%s", requests[1].Prompt)
+	}
+
+	// Options no consulted provider accepts are still an error
+	var req mcp.CallToolRequest
+	req.Params.Name = "consensus"
+	req.Params.Arguments = args(1, true, map[string]any{"provider_options": map[string]any{"model": "other"}})
+	res, err := s.handleToolCall(s.tools["consensus"])(context.Background(), req)
+	if err == nil && !res.IsError {
+		t.Error("expected reserved provider options to be rejected")
+	}
+}
+
+func TestServer_ChatReadsFiles(t *testing.T) {
+	s, mock, workDir := newTestServer(t)
+
+	out := s.call(t, "chat", map[string]any{"prompt": "what does main do?", "working_directory_absolute_path": workDir})
+	if !strings.Contains(out, "main prints hello") {
+		t.Fatalf("unexpected answer:\n%s", out)
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 2 || requests[1].ToolTurns != 1 || len(requests[0].Tools) != 3 {
+		t.Errorf("expected one read_file round, got %+v", requests)
+	}
+}
+
+func TestServer_RoutesToFitRequest(t *testing.T) {
+	s, mock, workDir := newTestServerWith(t, `{
+  "models": [
+    {"model_name": "mock-small", "intelligence_score": 95, "context_window": 4000, "max_output_tokens": 1000},
+    {"model_name": "mock-large", "intelligence_score": 50, "context_window": 200000, "max_output_tokens": 1000}
+  ],
+  "responses": [{"content": "MOCK ANALYSIS"}]
+}`)
+	big := strings.Repeat("func handler(w http.ResponseWriter, r *http.Request) {}\n", 1000)
+	if err := os.WriteFile(filepath.Join(workDir, "big.go"), []byte(big), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tool string
+		args map[string]any
+	}{
+		{"challenge", map[string]any{"topic": "Rewrite it in Rust", "working_directory_absolute_path": workDir, "absolute_file_paths": []any{filepath.Join(workDir, "big.go")}}},
+		{"apilookup", map[string]any{"query": "Go context", "context": big}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			s.call(t, tt.tool, tt.args)
+			requests := mock.Requests()
+			if got := requests[len(requests)-1].Model; got != "mock-large" {
+				t.Errorf("expected %s to route to the model that fits the request, got %s", tt.tool, got)
+			}
+		})
+	}
+}
+
+func TestServer_WorkflowUsesRequestedModel(t *testing.T) {
+	s, mock, _ := newTestServer(t)
+
+	s.call(t, "thinkdeep", finalStep(map[string]any{"model": "mock:mock-flash"}))
+	requests := mock.Requests()
+	last := requests[len(requests)-1]
+	if last.Model != "mock-flash" {
+		t.Errorf("expected the requested model, got %s", last.Model)
+	}
+
+	s.call(t, "thinkdeep", finalStep(map[string]any{"model": "auto"}))
+	requests = mock.Requests()
+	if got := requests[len(requests)-1].Model; got != "mock-pro" {
+		t.Errorf("expected auto to route to mock-pro, got %s", got)
+	}
+}
+
+func continuationID(t *testing.T, out string) string {
+	t.Helper()
+	_, after, ok := strings.Cut(out, "continuation_id: ")
+	if !ok {
+		t.Fatalf("no continuation_id in:\n%s", out)
+	}
+	return strings.Fields(after)[0]
+}
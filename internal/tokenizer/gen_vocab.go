@@ -0,0 +1,65 @@
+//go:build ignore
+
+// gen_vocab downloads the tiktoken vocabularies into data/ for embedding.
+// Files already present with the expected checksum are left alone, so it is
+// cheap to run before every build.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const baseURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+// Checksums published with tiktoken (tiktoken_ext/openai_public.py)
+var vocabularies = map[string]string{
+	"cl100k_base.tiktoken": "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	"o200k_base.tiktoken":  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+}
+
+func main() {
+	client := &http.Client{Timeout: 2 * time.Minute}
+	for name, sum := range vocabularies {
+		if err := fetch(client, name, sum); err != nil {
+			fmt.Fprintf(os.Stderr, "gen_vocab: %s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func fetch(client *http.Client, name, sum string) error {
+	path := filepath.Join("data", name)
+	if data, err := os.ReadFile(path); err == nil && checksum(data) == sum {
+		return nil
+	}
+
+	resp, err := client.Get(baseURL + name)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if got := checksum(data); got != sum {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, sum)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func checksum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
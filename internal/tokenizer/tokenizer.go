@@ -0,0 +1,329 @@
+// Package tokenizer implements byte-pair encoding for the OpenAI tiktoken
+// vocabularies, with a heuristic fallback for other model families.
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Supported encodings
+const (
+	CL100K = "cl100k_base"
+	O200K  = "o200k_base"
+)
+
+// maxPieceBytes bounds the quadratic merge loop for pathological inputs such as
+// long base64 blobs; longer pieces are encoded in chunks
+const maxPieceBytes = 1024
+
+//go:generate go run gen_vocab.go
+
+//go:embed data
+var vocabFS embed.FS
+
+// ws matches Unicode whitespace; RE2's \s is ASCII-only
+const ws = `\s\x0B\x{85}\p{Z}`
+
+var patterns = map[string]*regexp.Regexp{
+	CL100K: regexp.MustCompile(strings.Join([]string{
+		`(?i:'s|'t|'re|'ve|'m|'ll|'d)`,
+		`[^\r\n\p{L}\p{N}]?\p{L}+`,
+		`\p{N}{1,3}`,
+		` ?[^` + ws + `\p{L}\p{N}]+[\r\n]*`,
+		`[` + ws + `]*[\r\n]+`,
+		`[` + ws + `]+`,
+	}, "|")),
+	O200K: regexp.MustCompile(strings.Join([]string{
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?`,
+		`\p{N}{1,3}`,
+		` ?[^` + ws + `\p{L}\p{N}]+[\r\n/]*`,
+		`[` + ws + `]*[\r\n]+`,
+		`[` + ws + `]+`,
+	}, "|")),
+}
+
+// ErrVocabUnavailable indicates the vocabulary file wasn't embedded at build time
+type ErrVocabUnavailable struct {
+	Encoding string
+}
+
+func (e ErrVocabUnavailable) Error() string {
+	return fmt.Sprintf("tokenizer vocabulary %s not available (run 'go generate ./internal/tokenizer')", e.Encoding)
+}
+
+// Encoding is a BPE tokenizer for one vocabulary
+type Encoding struct {
+	name    string
+	ranks   map[string]int
+	pattern *regexp.Regexp
+}
+
+type lazyEncoding struct {
+	once sync.Once
+	enc  *Encoding
+	err  error
+}
+
+var encodings = map[string]*lazyEncoding{
+	CL100K: {},
+	O200K:  {},
+}
+
+// Missing returns the encodings whose vocabulary wasn't embedded at build
+// time. Counts for their models fall back to Estimate.
+func Missing() []string {
+	var missing []string
+	for _, name := range []string{CL100K, O200K} {
+		if _, err := fs.Stat(vocabFS, "data/"+name+".tiktoken"); err != nil {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Get returns the named encoding, loading its vocabulary on first use
+func Get(name string) (*Encoding, error) {
+	lazy, ok := encodings[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+
+	lazy.once.Do(func() {
+		data, err := vocabFS.ReadFile("data/" + name + ".tiktoken")
+		if err != nil {
+			lazy.err = ErrVocabUnavailable{Encoding: name}
+			slog.Warn("tokenizer vocabulary missing, token counts are estimates", "encoding", name, "error", lazy.err)
+			return
+		}
+
+		ranks, err := LoadRanks(data)
+		if err != nil {
+			lazy.err = fmt.Errorf("loading %s: %w", name, err)
+			return
+		}
+
+		lazy.enc = NewEncoding(name, ranks)
+	})
+
+	return lazy.enc, lazy.err
+}
+
+// NewEncoding creates an encoding from a rank table using the named
+// encoding's pre-tokenization pattern
+func NewEncoding(name string, ranks map[string]int) *Encoding {
+	pattern, ok := patterns[name]
+	if !ok {
+		pattern = patterns[CL100K]
+	}
+	return &Encoding{name: name, ranks: ranks, pattern: pattern}
+}
+
+// LoadRanks parses a tiktoken rank file ("<base64 token> <rank>" per line)
+func LoadRanks(data []byte) (map[string]int, error) {
+	ranks := make(map[string]int)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed entry", lineNo)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		r, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		ranks[string(decoded)] = r
+	}
+
+	return ranks, scanner.Err()
+}
+
+// Name returns the encoding name
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode converts text to token IDs. Special tokens are treated as plain text.
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range splitPieces(e.pattern, text) {
+		for len(piece) > maxPieceBytes {
+			cut := maxPieceBytes
+			for cut > 0 && !utf8.RuneStart(piece[cut]) {
+				cut--
+			}
+			tokens = append(tokens, bytePairEncode([]byte(piece[:cut]), e.ranks)...)
+			piece = piece[cut:]
+		}
+		tokens = append(tokens, bytePairEncode([]byte(piece), e.ranks)...)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens in text
+func (e *Encoding) Count(text string) int {
+	return len(e.Encode(text))
+}
+
+// EncodingForModel picks the vocabulary used by a model. Provider prefixes
+// such as "openai/" are ignored. Newer and unknown models use o200k.
+func EncodingForModel(model string) string {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	switch {
+	case strings.HasPrefix(name, "gpt-4o"),
+		strings.HasPrefix(name, "gpt-4.1"),
+		strings.HasPrefix(name, "gpt-4.5"),
+		strings.HasPrefix(name, "chatgpt"):
+		return O200K
+	case strings.HasPrefix(name, "gpt-4"),
+		strings.HasPrefix(name, "gpt-3.5"),
+		strings.HasPrefix(name, "gpt-35"),
+		strings.HasPrefix(name, "text-embedding"):
+		return CL100K
+	default:
+		return O200K
+	}
+}
+
+// CountTokens counts tokens for a model, falling back to Estimate when the
+// vocabulary isn't available
+func CountTokens(text, model string) int {
+	enc, err := Get(EncodingForModel(model))
+	if err != nil {
+		return Estimate(text)
+	}
+	return enc.Count(text)
+}
+
+// Estimate approximates a token count without a vocabulary. Text is split with
+// the cl100k pre-tokenizer so punctuation-heavy code and non-Latin scripts are
+// counted closer to their real cost than a flat characters-per-token ratio.
+func Estimate(text string) int {
+	count := 0
+	for _, piece := range splitPieces(patterns[CL100K], text) {
+		if isASCII(piece) {
+			count += max(1, (len(piece)+3)/5)
+		} else {
+			count += utf8.RuneCountInString(piece)
+		}
+	}
+	return count
+}
+
+// splitPieces applies the pre-tokenization pattern. RE2 has no lookahead, so
+// the "\s+(?!\S)" rule is emulated: a whitespace run followed by a
+// non-whitespace character leaves its last character for the next piece.
+func splitPieces(pattern *regexp.Regexp, text string) []string {
+	var pieces []string
+
+	for len(text) > 0 {
+		loc := pattern.FindStringIndex(text)
+		if loc == nil {
+			pieces = append(pieces, text)
+			break
+		}
+		if loc[0] > 0 {
+			pieces = append(pieces, text[:loc[0]])
+		}
+
+		end := loc[1]
+		piece := text[loc[0]:end]
+		if end < len(text) && isSpaceRun(piece) && !strings.HasSuffix(piece, "\n") && !strings.HasSuffix(piece, "\r") {
+			next, _ := utf8.DecodeRuneInString(text[end:])
+			_, size := utf8.DecodeLastRuneInString(piece)
+			if !unicode.IsSpace(next) && len(piece) > size {
+				end -= size
+				piece = piece[:len(piece)-size]
+			}
+		}
+
+		pieces = append(pieces, piece)
+		text = text[end:]
+	}
+
+	return pieces
+}
+
+// bytePairEncode merges adjacent parts by lowest rank until no pair is in the
+// vocabulary, matching tiktoken's byte_pair_merge
+func bytePairEncode(piece []byte, ranks map[string]int) []int {
+	if len(piece) == 0 {
+		return nil
+	}
+	if r, ok := ranks[string(piece)]; ok {
+		return []int{r}
+	}
+
+	// bounds[i] is the start offset of part i; the last entry is len(piece)
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+
+	for len(bounds) > 2 {
+		minRank, minIdx := math.MaxInt, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if r, ok := ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && r < minRank {
+				minRank, minIdx = r, i
+			}
+		}
+		if minIdx < 0 {
+			break
+		}
+		bounds = append(bounds[:minIdx+1], bounds[minIdx+2:]...)
+	}
+
+	tokens := make([]int, 0, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		tokens = append(tokens, ranks[string(piece[bounds[i]:bounds[i+1]])])
+	}
+	return tokens
+}
+
+func isSpaceRun(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
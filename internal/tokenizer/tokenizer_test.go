@@ -0,0 +1,152 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPieces_CL100K(t *testing.T) {
+	got := splitPieces(patterns[CL100K], "Hello world  foo\n\nbar 12345 it's!!\n")
+	want := []string{"Hello", " world", " ", " foo", "\n\n", "bar", " ", "123", "45", " it", "'s", "!!\n"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected pieces:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestSplitPieces_TrailingWhitespace(t *testing.T) {
+	got := splitPieces(patterns[O200K], "func main() {\n\treturn\n}   ")
+	want := []string{"func", " main", "()", " {\n", "\treturn", "\n", "}", "   "}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected pieces:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestEncoding_BytePairMerge(t *testing.T) {
+	ranks := make(map[string]int)
+	for i := 0; i < 256; i++ {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	ranks["ab"] = 256
+	ranks["abc"] = 257
+	ranks[" ab"] = 258
+
+	enc := NewEncoding(CL100K, ranks)
+
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"abc", []int{257}},
+		{"abcab", []int{257, 256}},
+		{"xyz", []int{'x', 'y', 'z'}},
+		{"abc ab", []int{257, 258}},
+	}
+
+	for _, tt := range tests {
+		if got := enc.Encode(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+
+	if n := enc.Count(strings.Repeat("a", 5000)); n != 5000 {
+		t.Errorf("expected long piece to be chunked into single bytes, got %d tokens", n)
+	}
+}
+
+func TestLoadRanks(t *testing.T) {
+	var sb strings.Builder
+	for i, tok := range []string{"a", "b", "ab", " the"} {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), i)
+	}
+
+	ranks, err := LoadRanks([]byte(sb.String()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ranks[" the"] != 3 || ranks["ab"] != 2 {
+		t.Errorf("unexpected ranks: %v", ranks)
+	}
+
+	if _, err := LoadRanks([]byte("not-a-rank-line")); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
+
+func TestEncodingForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o":             O200K,
+		"gpt-4o-mini":        O200K,
+		"gpt-4.1":            O200K,
+		"gpt-5.2":            O200K,
+		"o3":                 O200K,
+		"openai/gpt-5":       O200K,
+		"gpt-4":              CL100K,
+		"gpt-35-turbo":       CL100K,
+		"openai/gpt-4-turbo": CL100K,
+		"grok-4":             O200K,
+	}
+
+	for model, want := range tests {
+		if got := EncodingForModel(model); got != want {
+			t.Errorf("EncodingForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	if Estimate("") != 0 {
+		t.Error("expected zero tokens for empty text")
+	}
+
+	// Non-Latin text costs roughly a token per character
+	if n := Estimate("日本語のテキスト"); n < 8 {
+		t.Errorf("expected CJK text to count per character, got %d", n)
+	}
+
+	// Punctuation-heavy code should count more than a flat len/4
+	code := "if (a[i] != b[j]) { x += y; }"
+	if n := Estimate(code); n <= len(code)/4 {
+		t.Errorf("expected code estimate above len/4 (%d), got %d", len(code)/4, n)
+	}
+}
+
+// TestCountTokens_Vocabularies checks the embedded vocabularies against
+// tiktoken's own output. It is skipped in a checkout without them; the
+// server logs an error at startup and doctor reports them as an issue.
+func TestCountTokens_Vocabularies(t *testing.T) {
+	if missing := Missing(); len(missing) > 0 {
+		t.Skipf("vocabularies %v not embedded (run 'go generate ./internal/tokenizer')", missing)
+	}
+	for _, name := range []string{CL100K, O200K} {
+		if _, err := Get(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cl100k, _ := Get(CL100K)
+	want := []int{15339, 1917, 0, 57668, 53901, 3922, 3574, 244, 98220, 6447}
+	if got := cl100k.Encode("hello world!你好，世界！"); !reflect.DeepEqual(got, want) {
+		t.Errorf("cl100k Encode = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		text, model string
+		want        int
+	}{
+		{"hello world!你好，世界！", "gpt-4", 10},
+		{"hallo world!", "gpt-4o", 4},
+		{"你好世界！", "gpt-4o", 3},
+		{"Привет мир!", "gpt-5", 4},
+		{"Bonjour le monde!", "o3", 4},
+	}
+	for _, tt := range tests {
+		if got := CountTokens(tt.text, tt.model); got != tt.want {
+			t.Errorf("CountTokens(%q, %s) = %d, want %d", tt.text, tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+const (
+	// minFileTokens is the smallest part of a file worth sending; files whose
+	// share of the context is smaller are dropped instead of truncated
+	minFileTokens = 256
+
+	// fileOverhead covers the heading and fences around each file
+	fileOverhead = 16
+)
+
+// ContextFit is what preflight kept of a request's files and history
+type ContextFit struct {
+	Model   string
+	Window  int
+	Files   []utils.FileContent
+	History []types.ConversationTurn
+	Trimmed []string // what was dropped or truncated
+}
+
+// FitContext checks that a request fits the model's context window before it
+// is sent, and notes what was trimmed in the call notes so it is listed
+// with the result. req holds the prompt without the files, the system prompt, the full
+// history and the output and thinking settings. The prompt and system prompt
+// are always sent and room is reserved for the reply; files share what is
+// left, except for up to a quarter held for history, which then keeps the
+// newest turns that fit. Models without a declared context window get
+// everything.
+func FitContext(ctx context.Context, tool string, provider providers.Provider, req *providers.GenerateRequest, files []utils.FileContent) (*ContextFit, error) {
+	fit := &ContextFit{Model: req.Model, Files: files, History: req.ConversationHistory}
+
+	caps, err := provider.GetCapabilities(req.Model)
+	if err != nil || caps.ContextWindow <= 0 {
+		return fit, nil
+	}
+	fit.Model, fit.Window = caps.ModelName, caps.ContextWindow
+
+	count := providers.TokenCounter(ctx, provider, req.Model)
+	local := func(text string) int { return tokenizer.CountTokens(text, caps.ModelName) }
+	reserve := min(providers.ReplyReserve(req, caps), caps.ContextWindow/2)
+	room := caps.ContextWindow - reserve - count(req.Prompt) - count(req.SystemPrompt)
+	if room < 0 {
+		return nil, fmt.Errorf("prompt is %d tokens over what %s's %d-token context window leaves after reserving %d for the reply",
+			-room, caps.ModelName, caps.ContextWindow, reserve)
+	}
+
+	// Files can't crowd out the whole conversation: up to a quarter of the
+	// room is held for history
+	historyTokens := 0
+	for _, turn := range fit.History {
+		historyTokens += count(turn.Content)
+	}
+	held := min(historyTokens, room/4)
+	room = fit.fitFiles(room-held, count, local) + held
+	fit.fitHistory(room, count)
+
+	if len(fit.Trimmed) > 0 {
+		slog.Info("trimmed request context", "tool", tool, "model", fit.Model, "window", fit.Window, "trimmed", fit.Trimmed)
+		providers.NoteContextTrimmed(ctx, fit.Report())
+	}
+	return fit, nil
+}
+
+// fitFiles keeps the files within room, returning the room left. When they
+// don't all fit, the smallest files are kept whole first and the rest share
+// what remains evenly, each truncated to its share or dropped.
+func (f *ContextFit) fitFiles(room int, count, local memory.TokenCounter) int {
+	sizes := make([]int, len(f.Files))
+	total := 0
+	for i, file := range f.Files {
+		sizes[i] = count(file.Content) + count(file.Path) + fileOverhead
+		total += sizes[i]
+	}
+	if total <= room {
+		return room - total
+	}
+
+	order := make([]int, len(f.Files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sizes[order[a]] < sizes[order[b]] })
+
+	shares := make([]int, len(f.Files))
+	left := room
+	for n, i := range order {
+		shares[i] = min(sizes[i], left/(len(order)-n))
+		left -= shares[i]
+	}
+
+	var kept []utils.FileContent
+	for i, file := range f.Files {
+		if shares[i] == sizes[i] {
+			kept = append(kept, file)
+			continue
+		}
+
+		if shares[i] >= minFileTokens {
+			content, lines, totalLines := truncateLines(file.Content, shares[i]-count(file.Path)-fileOverhead, count, local)
+			if lines > 0 {
+				kept = append(kept, utils.FileContent{Path: file.Path, Content: content})
+				f.Trimmed = append(f.Trimmed, fmt.Sprintf("%s: truncated to the first %d of %d lines (~%d of %d tokens)",
+					file.Path, lines, totalLines, shares[i], sizes[i]))
+				continue
+			}
+		}
+
+		f.Trimmed = append(f.Trimmed, fmt.Sprintf("%s: dropped (~%d tokens)", file.Path, sizes[i]))
+		left += shares[i]
+	}
+	f.Files = kept
+	return left
+}
+
+// fitHistory keeps the newest turns that fit in room
+func (f *ContextFit) fitHistory(room int, count memory.TokenCounter) {
+	history := f.History
+	f.History = memory.NewThreadBuilder(&types.ThreadContext{Turns: history}, room).
+		WithTokenCounter(count).
+		BuildConversationHistory()
+
+	dropped := len(history) - len(f.History)
+	if dropped == 0 {
+		return
+	}
+	tokens := 0
+	for _, turn := range history[:dropped] {
+		tokens += count(turn.Content)
+	}
+	turns := "turns"
+	if dropped == 1 {
+		turns = "turn"
+	}
+	f.Trimmed = append(f.Trimmed, fmt.Sprintf("history: dropped the %d oldest %s of %d (~%d tokens)", dropped, turns, len(history), tokens))
+}
+
+// truncateLines keeps the most leading lines of content that fit in budget
+// tokens, with a note that the rest was cut. It returns the lines kept and
+// the file's line count. Lines are counted with the local tokenizer, scaled
+// to the provider's count of the whole file, so a provider that counts
+// remotely is asked about the file and the kept prefix rather than once per
+// step of a search.
+func truncateLines(content string, budget int, count, local memory.TokenCounter) (string, int, int) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	marker := func(n int) string {
+		return fmt.Sprintf("\n[... truncated: %d of %d lines shown]\n", n, len(lines))
+	}
+	budget -= local(marker(len(lines)))
+
+	sizes := make([]int, len(lines))
+	localTotal := 0
+	for i, line := range lines {
+		sizes[i] = local(line)
+		localTotal += sizes[i]
+	}
+	scale := 1.0
+	if localTotal > 0 {
+		scale = float64(count(content)) / float64(localTotal)
+	}
+
+	// Largest prefix within budget by the scaled local counts
+	n, used := 0, 0.0
+	for n < len(lines) && used+float64(sizes[n])*scale <= float64(budget) {
+		used += float64(sizes[n]) * scale
+		n++
+	}
+
+	// Check the prefix with the provider, shrinking it in proportion if the
+	// scaled counts were optimistic
+	for n > 0 {
+		got := count(strings.Join(lines[:n], ""))
+		if got <= budget {
+			break
+		}
+		n = min(n-1, n*budget/got)
+	}
+	if n <= 0 {
+		return "", 0, len(lines)
+	}
+	return strings.Join(lines[:n], "") + marker(n), n, len(lines)
+}
+
+// Report lists what was trimmed, or "" when everything fit
+func (f *ContextFit) Report() string {
+	if len(f.Trimmed) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("context trimmed to fit %s's %d-token window:\n", f.Model, f.Window))
+	for _, t := range f.Trimmed {
+		sb.WriteString("- " + t + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+func TestFitContext(t *testing.T) {
+	provider := providers.NewOpenAICompatProvider(types.ProviderCustom, "", "http://localhost:1", []types.ModelCapabilities{
+		{Provider: types.ProviderCustom, ModelName: "small", ContextWindow: 4000, MaxOutputTokens: 1000},
+		{Provider: types.ProviderCustom, ModelName: "unbounded"},
+	}, 0)
+
+	var history []types.ConversationTurn
+	for i := 0; i < 6; i++ {
+		history = append(history, types.ConversationTurn{Role: "user", Content: strings.Repeat("earlier discussion ", 60)})
+	}
+	notes := utils.FileContent{Path: "/work/notes.md", Content: "short notes\n"}
+	big := utils.FileContent{Path: "/work/big.go", Content: strings.Repeat("func handler(w http.ResponseWriter, r *http.Request) {}\n", 1000)}
+
+	t.Run("everything fits", func(t *testing.T) {
+		req := &providers.GenerateRequest{Prompt: "hi", Model: "small", ConversationHistory: history[:2]}
+		fit, err := FitContext(context.Background(), "chat", provider, req, []utils.FileContent{notes})
+		if err != nil {
+			t.Fatalf("FitContext failed: %v", err)
+		}
+		if len(fit.Files) != 1 || len(fit.History) != 2 || fit.Report() != "" {
+			t.Errorf("expected nothing trimmed, got %+v", fit.Trimmed)
+		}
+	})
+
+	t.Run("files and history trimmed", func(t *testing.T) {
+		req := &providers.GenerateRequest{Prompt: "hi", Model: "small", ConversationHistory: history}
+		ctx, callNotes := providers.WithCallNotes(context.Background())
+		fit, err := FitContext(ctx, "chat", provider, req, []utils.FileContent{big, notes})
+		if err != nil {
+			t.Fatalf("FitContext failed: %v", err)
+		}
+		if len(fit.Files) != 2 || fit.Files[1] != notes {
+			t.Fatalf("expected the small file kept whole, got %d files", len(fit.Files))
+		}
+		if !strings.Contains(fit.Files[0].Content, "of 1000 lines shown]") || len(fit.Files[0].Content) >= len(big.Content) {
+			t.Errorf("expected big.go to be truncated with a note, got %d bytes", len(fit.Files[0].Content))
+		}
+		if len(fit.History) == 0 || len(fit.History) >= len(history) || fit.History[len(fit.History)-1].Content != history[len(history)-1].Content {
+			t.Errorf("expected the newest turns kept and older ones dropped, kept %d", len(fit.History))
+		}
+
+		report := fit.Report()
+		for _, want := range []string{"small's 4000-token window", "/work/big.go: truncated to the first", "history: dropped the"} {
+			if !strings.Contains(report, want) {
+				t.Errorf("expected %q in report:\n%s", want, report)
+			}
+		}
+		if noted := callNotes.ContextTrimmed(); len(noted) != 1 || noted[0] != report {
+			t.Errorf("expected the report noted for the tool result, got %q", noted)
+		}
+	})
+
+	t.Run("prompt too large", func(t *testing.T) {
+		req := &providers.GenerateRequest{Prompt: strings.Repeat("word ", 5000), Model: "small"}
+		if _, err := FitContext(context.Background(), "chat", provider, req, nil); err == nil || !strings.Contains(err.Error(), "context window") {
+			t.Errorf("expected an oversized prompt to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("no declared window", func(t *testing.T) {
+		req := &providers.GenerateRequest{Prompt: "hi", Model: "unbounded", ConversationHistory: history}
+		fit, err := FitContext(context.Background(), "chat", provider, req, []utils.FileContent{big})
+		if err != nil || len(fit.Files) != 1 || fit.Files[0] != big || len(fit.History) != len(history) {
+			t.Errorf("expected everything to be sent, got %+v, %v", fit, err)
+		}
+	})
+}
+
+func TestTruncateLines_CountsFewTimes(t *testing.T) {
+	content := strings.Repeat("func handler(w http.ResponseWriter, r *http.Request) {}\n", 1000)
+
+	// A provider that counts a third more than the local tokenizer
+	calls := 0
+	count := func(text string) int {
+		calls++
+		return tokenizer.Estimate(text) * 4 / 3
+	}
+
+	kept, lines, total := truncateLines(content, 2000, count, tokenizer.Estimate)
+	counted := calls
+	if lines == 0 || lines >= total || total != 1000 {
+		t.Fatalf("expected a truncated prefix of 1000 lines, kept %d of %d", lines, total)
+	}
+	if got := count(strings.Join(strings.SplitAfter(content, "\n")[:lines], "")); got > 2000 {
+		t.Errorf("kept %d tokens, over the 2000-token budget", got)
+	}
+	if !strings.Contains(kept, "of 1000 lines shown]") {
+		t.Errorf("expected a truncation note, got %q", kept[len(kept)-50:])
+	}
+	if counted > 3 {
+		t.Errorf("expected a handful of provider counts, got %d", counted)
+	}
+}
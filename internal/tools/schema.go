@@ -118,11 +118,15 @@ func (b *SchemaBuilder) AddStringArray(name, description string, required bool)
 // AddObject adds an object property
 func (b *SchemaBuilder) AddObject(name, description string, required bool, properties map[string]any) *SchemaBuilder {
 	props := b.schema["properties"].(map[string]any)
-	props[name] = map[string]any{
+	object := map[string]any{
 		"type":        "object",
 		"description": description,
-		"properties":  properties,
 	}
+	// Without properties any keys are accepted
+	if properties != nil {
+		object["properties"] = properties
+	}
+	props[name] = object
 	if required {
 		b.addRequired(name)
 	}
@@ -150,6 +154,21 @@ func (b *SchemaBuilder) AddObjectArray(name, description string, required bool,
 	return b
 }
 
+// AddSamplingParams adds the optional sampling controls read by
+// ArgumentParser.GetSampling, and provider_options
+func (b *SchemaBuilder) AddSamplingParams() *SchemaBuilder {
+	zero, one, two, minusTwo := 0.0, 1.0, 2.0, -2.0
+	first := 1
+	return b.
+		AddNumber("top_p", "Nucleus sampling: only tokens within this probability mass are considered", false, &zero, &one).
+		AddInteger("seed", "Seed for more reproducible sampling, where supported", false, nil, nil).
+		AddStringArray("stop", "Sequences that end generation", false).
+		AddNumber("presence_penalty", "Penalize tokens that already appeared, encouraging new topics", false, &minusTwo, &two).
+		AddNumber("frequency_penalty", "Penalize tokens by how often they appeared, reducing repetition", false, &minusTwo, &two).
+		AddInteger("n", "Number of completions to generate; extras are returned as alternatives", false, &first, nil).
+		AddObject("provider_options", "Extra fields for the provider's request body, such as OpenRouter 'provider' or vLLM 'guided_json'; keys must be on the provider's allowlist", false, nil)
+}
+
 // addRequired adds a field to the required list, initializing it if needed
 func (b *SchemaBuilder) addRequired(name string) {
 	if b.schema["required"] == nil {
@@ -3,14 +3,15 @@ package simple
 import (
 	"context"
 	"fmt"
-	    "strings"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
-	)
-	// APILookupTool helps users find documentation and API details
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
+)
+
+// APILookupTool helps users find documentation and API details
 type APILookupTool struct {
 	*BaseTool
 }
@@ -25,8 +26,10 @@ func NewAPILookupTool(cfg *config.Config, registry *providers.Registry, mem *mem
 	tool.schema.
 		AddString("query", "The library, function, or concept to look up (e.g., 'React useEffect', 'Python requests')", true).
 		AddString("context", "Additional context about what you're trying to achieve", false).
-		AddString("model", "Model to use", false).
-		AddString("continuation_id", "Thread ID", false)
+		AddString("model", "Model to use, optionally provider-qualified as provider:model (e.g. azure:gpt-4o), or 'auto' for automatic selection", false).
+		AddString("continuation_id", "Thread ID", false).
+		AddBoolean("no_cache", "Skip the response cache and call the model again", false).
+		AddSamplingParams()
 
 	return tool
 }
@@ -41,16 +44,13 @@ func (t *APILookupTool) Execute(ctx context.Context, args map[string]any) (*tool
 	userContext := parser.GetString("context")
 	modelName := parser.GetString("model")
 	continuationID := parser.GetString("continuation_id")
+	noCache := parser.GetBool("no_cache", false)
+	sampling := parser.GetSampling()
+	providerOptions := parser.GetObject("provider_options")
 
 	// Get thread
 	thread, _ := t.GetOrCreateThread(continuationID)
 
-	// Resolve model
-	resolvedModel, provider, err := t.ResolveModel(modelName)
-	if err != nil {
-		return nil, fmt.Errorf("resolving model: %w", err)
-	}
-
 	// Build prompt
 	var promptBuilder strings.Builder
 	promptBuilder.WriteString(fmt.Sprintf("Please provide documentation and usage examples for: %s\n\n", query))
@@ -58,14 +58,42 @@ func (t *APILookupTool) Execute(ctx context.Context, args map[string]any) (*tool
 		promptBuilder.WriteString(fmt.Sprintf("Context: %s\n\n", userContext))
 	}
 	promptBuilder.WriteString("Include:\n1. Brief explanation\n2. Function signature/syntax\n3. Common usage examples\n4. Best practices/gotchas")
+	prompt := promptBuilder.String()
+	systemPrompt := "You are a technical documentation expert. Provide clear, accurate, and concise API documentation with code examples."
+	history := t.memory.GetHistory(thread.ThreadID)
 
-	// Generate response
-	resp, err := t.GenerateContent(ctx, provider, &providers.GenerateRequest{
-		Prompt:              promptBuilder.String(),
-		SystemPrompt:        "You are a technical documentation expert. Provide clear, accurate, and concise API documentation with code examples.",
-		Model:               resolvedModel,
-		ConversationHistory: t.memory.GetHistory(thread.ThreadID),
+	// Resolve model, routing to one that fits the whole request
+	parts := []string{prompt, systemPrompt}
+	for _, turn := range history {
+		parts = append(parts, turn.Content)
+	}
+	resolvedModel, provider, err := t.ResolveModelFor(ctx, modelName, providers.ModelRequirements{
+		MinContextWindow: providers.RequiredContext(parts...),
 	})
+	if err != nil {
+		return nil, fmt.Errorf("resolving model: %w", err)
+	}
+
+	req := &providers.GenerateRequest{
+		Prompt:              prompt,
+		SystemPrompt:        systemPrompt,
+		Model:               resolvedModel,
+		Sampling:            sampling,
+		ProviderOptions:     providerOptions,
+		ConversationHistory: history,
+		ThreadID:            thread.ThreadID,
+		NoCache:             noCache,
+	}
+
+	// Trim history to the model's context window
+	fit, err := tools.FitContext(ctx, t.name, provider, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.ConversationHistory = fit.History
+
+	// Generate response
+	resp, err := t.GenerateContent(ctx, provider, req)
 	if err != nil {
 		return nil, fmt.Errorf("generating content: %w", err)
 	}
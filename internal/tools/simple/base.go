@@ -4,13 +4,15 @@ import (
 	"context"
 	"log/slog"
 
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/agent"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
 	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
 )
-	// BaseTool provides common functionality for simple tools
+
+// BaseTool provides common functionality for simple tools
 type BaseTool struct {
 	name        string
 	description string
@@ -41,22 +43,16 @@ func (t *BaseTool) Name() string           { return t.name }
 func (t *BaseTool) Description() string    { return t.description }
 func (t *BaseTool) Schema() map[string]any { return t.schema.Build() }
 
-// GetProvider finds a provider for the given model
-func (t *BaseTool) GetProvider(modelName string) (providers.Provider, error) {
-	if modelName == "" || modelName == "auto" {
-		// Use auto-selection based on requirements
-		caps, provider, err := t.registry.SelectBestModel(providers.ModelRequirements{})
-		if err != nil {
-			return nil, err
-		}
-		_ = caps // Use caps if needed
-		return provider, nil
-	}
-	return t.registry.GetProviderForModel(modelName)
+// ResolveModel determines the actual model to use
+func (t *BaseTool) ResolveModel(ctx context.Context, requestedModel string) (string, providers.Provider, error) {
+	return t.ResolveModelFor(ctx, requestedModel, providers.ModelRequirements{})
 }
 
-// ResolveModel determines the actual model to use
-func (t *BaseTool) ResolveModel(requestedModel string) (string, providers.Provider, error) {
+// ResolveModelFor determines the model to use for a request with specific
+// requirements. Auto-selection routes on them and the tool's task profile;
+// an explicit model that can't meet them is rejected with a descriptive
+// error.
+func (t *BaseTool) ResolveModelFor(ctx context.Context, requestedModel string, requirements providers.ModelRequirements) (string, providers.Provider, error) {
 	modelToUse := requestedModel
 
 	// Use config default if not specified
@@ -66,11 +62,17 @@ func (t *BaseTool) ResolveModel(requestedModel string) (string, providers.Provid
 
 	// Auto-select only if still empty or explicit "auto"
 	if modelToUse == "" || modelToUse == "auto" {
-		caps, provider, err := t.registry.SelectBestModel(providers.ModelRequirements{})
+		d, err := t.registry.Route(ctx, requirements)
+		if err != nil && requirements.MinContextWindow > 0 {
+			// Nothing holds the whole request; take the best model and let
+			// FitContext trim the request to it
+			requirements.MinContextWindow = 0
+			d, err = t.registry.Route(ctx, requirements)
+		}
 		if err != nil {
 			return "", nil, err
 		}
-		return caps.ModelName, provider, nil
+		return d.Model.ModelName, d.Provider, nil
 	}
 
 	provider, err := t.registry.GetProviderForModel(modelToUse)
@@ -78,6 +80,12 @@ func (t *BaseTool) ResolveModel(requestedModel string) (string, providers.Provid
 		return "", nil, err
 	}
 
+	if requirements.NeedsVision {
+		if caps, err := provider.GetCapabilities(modelToUse); err == nil && !caps.SupportsVision {
+			return "", nil, providers.ErrVisionNotSupported{Model: modelToUse, Provider: provider.GetProviderType()}
+		}
+	}
+
 	return modelToUse, provider, nil
 }
 
@@ -112,6 +120,7 @@ func (t *BaseTool) AddTurn(threadID, role, content string, files, images []strin
 			"error", err)
 	}
 }
+
 // GenerateContent calls the AI provider
 func (t *BaseTool) GenerateContent(
 	ctx context.Context,
@@ -120,3 +129,14 @@ func (t *BaseTool) GenerateContent(
 ) (*types.ModelResponse, error) {
 	return provider.GenerateContent(ctx, req)
 }
+
+// GenerateContentIn calls the AI provider, letting models that support
+// function calling read files under workDir
+func (t *BaseTool) GenerateContentIn(
+	ctx context.Context,
+	provider providers.Provider,
+	req *providers.GenerateRequest,
+	workDir string,
+) (*types.ModelResponse, error) {
+	return agent.Generate(ctx, t.cfg, provider, req, workDir)
+}
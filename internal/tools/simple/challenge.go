@@ -3,15 +3,16 @@ package simple
 import (
 	"context"
 	"fmt"
-	    "strings"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
-	)
-	// ChallengeTool provides critical analysis of ideas or code
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+// ChallengeTool provides critical analysis of ideas or code
 type ChallengeTool struct {
 	*BaseTool
 }
@@ -26,8 +27,10 @@ func NewChallengeTool(cfg *config.Config, registry *providers.Registry, mem *mem
 		AddString("topic", "The idea, code, or decision to analyze", true).
 		AddString("working_directory_absolute_path", "Absolute path to working directory", false).
 		AddStringArray("absolute_file_paths", "Related file paths", false).
-		AddString("model", "Model to use", false).
-		AddString("continuation_id", "Thread ID", false)
+		AddString("model", "Model to use, optionally provider-qualified as provider:model (e.g. azure:gpt-4o), or 'auto' for automatic selection", false).
+		AddString("continuation_id", "Thread ID", false).
+		AddBoolean("no_cache", "Skip the response cache and call the model again", false).
+		AddSamplingParams()
 
 	return tool
 }
@@ -43,29 +46,75 @@ func (t *ChallengeTool) Execute(ctx context.Context, args map[string]any) (*tool
 	filePaths := parser.GetStringArray("absolute_file_paths")
 	modelName := parser.GetString("model")
 	continuationID := parser.GetString("continuation_id")
+	noCache := parser.GetBool("no_cache", false)
+	sampling := parser.GetSampling()
+	providerOptions := parser.GetObject("provider_options")
 
 	// Get thread
 	thread, _ := t.GetOrCreateThread(continuationID)
 
-	// Resolve model
-	resolvedModel, provider, err := t.ResolveModel(modelName)
-	if err != nil {
-		return nil, fmt.Errorf("resolving model: %w", err)
-	}
-
 	// Read files
 	var fileContents []utils.FileContent
 	if len(filePaths) > 0 && workDir != "" {
 		fileContents, _ = utils.ReadFiles(filePaths, workDir)
 	}
 
-	// Build prompt
+	systemPrompt := "You are a senior principal engineer performing a critical design review. Your goal is to find flaws before they become problems."
+	history := t.memory.GetHistory(thread.ThreadID)
+
+	// Resolve model, routing to one that fits the whole request
+	parts := []string{t.buildPrompt(topic, fileContents), systemPrompt}
+	for _, turn := range history {
+		parts = append(parts, turn.Content)
+	}
+	resolvedModel, provider, err := t.ResolveModelFor(ctx, modelName, providers.ModelRequirements{
+		MinContextWindow: providers.RequiredContext(parts...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resolving model: %w", err)
+	}
+
+	req := &providers.GenerateRequest{
+		Prompt:              t.buildPrompt(topic, nil),
+		SystemPrompt:        systemPrompt,
+		Model:               resolvedModel,
+		Sampling:            sampling,
+		ProviderOptions:     providerOptions,
+		ConversationHistory: history,
+		ThreadID:            thread.ThreadID,
+		NoCache:             noCache,
+	}
+
+	// Trim files and history to the model's context window
+	fit, err := tools.FitContext(ctx, t.name, provider, req, fileContents)
+	if err != nil {
+		return nil, err
+	}
+	req.Prompt = t.buildPrompt(topic, fit.Files)
+	req.ConversationHistory = fit.History
+
+	// Generate response
+	resp, err := t.GenerateContent(ctx, provider, req)
+	if err != nil {
+		return nil, fmt.Errorf("generating content: %w", err)
+	}
+
+	// Save to memory
+	t.AddTurn(thread.ThreadID, "user", fmt.Sprintf("Challenge: %s", topic), filePaths, nil)
+	t.AddTurn(thread.ThreadID, "assistant", resp.Content, nil, nil)
+
+	result := fmt.Sprintf("%s\n\n---\ncontinuation_id: %s", resp.Content, thread.ThreadID)
+	return tools.NewToolResult(result), nil
+}
+
+// buildPrompt asks for a critical analysis of topic with the files as context
+func (t *ChallengeTool) buildPrompt(topic string, files []utils.FileContent) string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Please critically analyze this topic: %s\n\n", topic))
 
-	if len(fileContents) > 0 {
+	if len(files) > 0 {
 		sb.WriteString("## Context Files\n\n")
-		for _, f := range fileContents {
+		for _, f := range files {
 			sb.WriteString(fmt.Sprintf("### %s\n```\n%s\n```\n\n", f.Path, f.Content))
 		}
 	}
@@ -81,21 +130,5 @@ Please play Devil's Advocate and provide a critical analysis:
 
 Be constructive but rigorous.`)
 
-	// Generate response
-	resp, err := t.GenerateContent(ctx, provider, &providers.GenerateRequest{
-		Prompt:              sb.String(),
-		SystemPrompt:        "You are a senior principal engineer performing a critical design review. Your goal is to find flaws before they become problems.",
-		Model:               resolvedModel,
-		ConversationHistory: t.memory.GetHistory(thread.ThreadID),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("generating content: %w", err)
-	}
-
-	// Save to memory
-	t.AddTurn(thread.ThreadID, "user", fmt.Sprintf("Challenge: %s", topic), filePaths, nil)
-	t.AddTurn(thread.ThreadID, "assistant", resp.Content, nil, nil)
-
-	result := fmt.Sprintf("%s\n\n---\ncontinuation_id: %s", resp.Content, thread.ThreadID)
-	return tools.NewToolResult(result), nil
+	return sb.String()
 }
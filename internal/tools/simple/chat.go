@@ -4,16 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	    "strings"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/types"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
-	)
-	// ChatTool handles multi-turn conversations
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
+)
+
+// ChatTool handles multi-turn conversations
 type ChatTool struct {
 	*BaseTool
 }
@@ -28,12 +29,14 @@ func NewChatTool(cfg *config.Config, registry *providers.Registry, mem *memory.C
 	tool.schema.
 		AddString("prompt", "Your question or idea for collaborative thinking", true).
 		AddString("working_directory_absolute_path", "Absolute path to working directory", true).
-		AddString("model", "Model to use (or 'auto' for automatic selection)", false).
+		AddString("model", "Model to use, optionally provider-qualified as provider:model (e.g. azure:gpt-4o), or 'auto' for automatic selection", false).
 		AddStringArray("absolute_file_paths", "Full paths to relevant code files", false).
 		AddStringArray("images", "Image paths or base64 strings", false).
 		AddString("continuation_id", "Thread ID for multi-turn conversations", false).
 		AddNumber("temperature", "0 = deterministic, 1 = creative", false, ptr(0.0), ptr(1.0)).
-		AddStringEnum("thinking_mode", "Reasoning depth", []string{"minimal", "low", "medium", "high", "max"}, false)
+		AddStringEnum("thinking_mode", "Reasoning depth", []string{"minimal", "low", "medium", "high", "max"}, false).
+		AddBoolean("no_cache", "Skip the response cache and call the model again", false).
+		AddSamplingParams()
 
 	return tool
 }
@@ -57,17 +60,14 @@ func (t *ChatTool) Execute(ctx context.Context, args map[string]any) (*tools.Too
 	continuationID := parser.GetString("continuation_id")
 	temperature := parser.GetFloat("temperature", 0.7)
 	thinkingMode := types.ThinkingMode(parser.GetString("thinking_mode"))
+	noCache := parser.GetBool("no_cache", false)
+	sampling := parser.GetSampling()
+	providerOptions := parser.GetObject("provider_options")
 
 	// Get or create conversation thread
 	thread, isExisting := t.GetOrCreateThread(continuationID)
 	slog.Debug("chat thread", "id", thread.ThreadID, "existing", isExisting)
 
-	// Resolve model
-	resolvedModel, provider, err := t.ResolveModel(modelName)
-	if err != nil {
-		return nil, fmt.Errorf("resolving model: %w", err)
-	}
-
 	// Read files
 	fileContents, err := utils.ReadFiles(filePaths, workDir)
 	if err != nil {
@@ -76,20 +76,48 @@ func (t *ChatTool) Execute(ctx context.Context, args map[string]any) (*tools.Too
 
 	// Build prompt with file contents
 	fullPrompt := t.buildPrompt(prompt, fileContents)
+	systemPrompt := t.getSystemPrompt()
 
 	// Get conversation history
 	history := t.memory.GetHistory(thread.ThreadID)
 
-	// Generate response
-	resp, err := t.GenerateContent(ctx, provider, &providers.GenerateRequest{
-		Prompt:              fullPrompt,
-		SystemPrompt:        t.getSystemPrompt(),
+	// Resolve model, routing to one that fits the whole request
+	parts := []string{fullPrompt, systemPrompt}
+	for _, turn := range history {
+		parts = append(parts, turn.Content)
+	}
+	resolvedModel, provider, err := t.ResolveModelFor(ctx, modelName, providers.ModelRequirements{
+		NeedsVision:      len(images) > 0,
+		MinContextWindow: providers.RequiredContext(parts...),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resolving model: %w", err)
+	}
+
+	req := &providers.GenerateRequest{
+		Prompt:              prompt,
+		SystemPrompt:        systemPrompt,
 		Model:               resolvedModel,
 		Temperature:         temperature,
+		Sampling:            sampling,
+		ProviderOptions:     providerOptions,
 		ThinkingMode:        thinkingMode,
 		ConversationHistory: history,
+		ThreadID:            thread.ThreadID,
 		Images:              images,
-	})
+		NoCache:             noCache,
+	}
+
+	// Trim files and history to the model's context window
+	fit, err := tools.FitContext(ctx, t.name, provider, req, fileContents)
+	if err != nil {
+		return nil, err
+	}
+	req.Prompt = t.buildPrompt(prompt, fit.Files)
+	req.ConversationHistory = fit.History
+
+	// Generate response
+	resp, err := t.GenerateContentIn(ctx, provider, req, workDir)
 	if err != nil {
 		return nil, fmt.Errorf("generating content: %w", err)
 	}
@@ -98,8 +126,12 @@ func (t *ChatTool) Execute(ctx context.Context, args map[string]any) (*tools.Too
 	t.AddTurn(thread.ThreadID, "user", prompt, filePaths, images)
 	t.AddTurn(thread.ThreadID, "assistant", resp.Content, nil, nil)
 
-	// Build response with continuation ID
-	result := fmt.Sprintf("%s\n\n---\ncontinuation_id: %s", resp.Content, thread.ThreadID)
+	// Build response with any alternatives and the continuation ID
+	content := resp.Content
+	for i, alt := range resp.Alternatives {
+		content += fmt.Sprintf("\n\n---\n### Alternative %d\n\n%s", i+2, alt)
+	}
+	result := fmt.Sprintf("%s\n\n---\ncontinuation_id: %s", content, thread.ThreadID)
 
 	return tools.NewToolResult(result), nil
 }
@@ -0,0 +1,150 @@
+package simple
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tokenizer"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+)
+
+// DoctorTool reports provider health and configuration problems
+type DoctorTool struct {
+	cfg      *config.Config
+	registry *providers.Registry
+}
+
+// NewDoctorTool creates a new doctor tool
+func NewDoctorTool(cfg *config.Config, registry *providers.Registry) *DoctorTool {
+	return &DoctorTool{
+		cfg:      cfg,
+		registry: registry,
+	}
+}
+
+func (t *DoctorTool) Name() string {
+	return "doctor"
+}
+
+func (t *DoctorTool) Description() string {
+	return "Diagnoses the server: provider health and circuit breaker state, recent errors and latency, model counts, and configuration problems."
+}
+
+func (t *DoctorTool) Schema() map[string]any {
+	return tools.NewSchemaBuilder().Build()
+}
+
+func (t *DoctorTool) Execute(ctx context.Context, args map[string]any) (*tools.ToolResult, error) {
+	var sb strings.Builder
+	var issues []string
+
+	sb.WriteString("# Relay Doctor\n\n## Providers\n\n")
+
+	available := make(map[types.ProviderType]int)
+	for _, m := range t.registry.GetAllModels() {
+		available[m.Provider]++
+	}
+	hidden := make(map[types.ProviderType]int)
+	for _, h := range t.registry.HiddenModels() {
+		hidden[h.Model.Provider]++
+	}
+
+	health := t.registry.Health()
+	if len(health) == 0 {
+		sb.WriteString("No providers configured.\n")
+		issues = append(issues, "no providers configured; set at least one API key (see .env.example)")
+	}
+
+	for _, h := range health {
+		sb.WriteString(fmt.Sprintf("- **%s**: %s\n", h.Provider, describeHealth(h)))
+		sb.WriteString(fmt.Sprintf("  - Models: %d available, %d hidden by policy\n", available[h.Provider], hidden[h.Provider]))
+		if h.Calls > 0 {
+			sb.WriteString(fmt.Sprintf("  - Calls: %d, failures: %d (%d consecutive), avg latency: %s\n",
+				h.Calls, h.Failures, h.ConsecutiveFailures, h.AvgLatency.Round(time.Millisecond)))
+		}
+		if h.LastError != "" {
+			sb.WriteString(fmt.Sprintf("  - Last error (%s): %s\n", h.LastErrorAt.Format(time.TimeOnly), truncate(h.LastError, 200)))
+		}
+
+		if !h.Healthy() {
+			issues = append(issues, fmt.Sprintf("%s circuit is %s: %s", h.Provider, h.State, truncate(h.LastError, 200)))
+		}
+		if available[h.Provider] == 0 {
+			issues = append(issues, fmt.Sprintf("%s has no usable models", h.Provider))
+		}
+	}
+
+	for _, c := range t.registry.AliasCollisions() {
+		issues = append(issues, fmt.Sprintf("%q refers to several models and resolves to %s (also %s); use a provider-qualified name",
+			c.Name, c.Targets[0], strings.Join(c.Targets[1:], ", ")))
+	}
+
+	if missing := tokenizer.Missing(); len(missing) > 0 {
+		issues = append(issues, fmt.Sprintf("tokenizer vocabularies %s not embedded, token counts are estimates; run 'go generate ./internal/tokenizer' and rebuild",
+			strings.Join(missing, ", ")))
+	}
+
+	sb.WriteString("\n## Settings\n\n")
+	sb.WriteString(fmt.Sprintf("- Provider order: %s\n", joinProviders(t.registry.Priority())))
+	sb.WriteString(fmt.Sprintf("- Request validation: %s\n", t.cfg.RequestValidation))
+	if t.cfg.ResponseCache {
+		sb.WriteString(fmt.Sprintf("- Response cache: on (ttl %s, %d MB)\n", t.cfg.ResponseCacheTTL, t.cfg.ResponseCacheMaxMB))
+	} else {
+		sb.WriteString("- Response cache: off\n")
+	}
+	sb.WriteString(fmt.Sprintf("- Circuit breaker: opens after %d failures, %s cooldown\n",
+		t.cfg.CircuitFailureThreshold, t.cfg.CircuitCooldown))
+	if len(t.cfg.RateLimits) > 0 {
+		sb.WriteString(fmt.Sprintf("- Rate limits: %d providers (max wait %s)\n", len(t.cfg.RateLimits), t.cfg.RateLimitMaxWait))
+	}
+
+	today := t.registry.Costs().Today()
+	sb.WriteString(fmt.Sprintf("- Spend today: $%.4f over %d calls", today.TotalUSD, today.Calls))
+	if t.cfg.Budgets.DailyUSD > 0 {
+		sb.WriteString(fmt.Sprintf(" (daily budget $%.2f)", t.cfg.Budgets.DailyUSD))
+		if today.TotalUSD >= t.cfg.Budgets.DailyUSD {
+			issues = append(issues, "daily budget reached")
+		}
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("\n## Issues\n\n")
+	if len(issues) == 0 {
+		sb.WriteString("None found.\n")
+	}
+	for _, issue := range issues {
+		sb.WriteString(fmt.Sprintf("- %s\n", issue))
+	}
+
+	return tools.NewToolResult(sb.String()), nil
+}
+
+// describeHealth summarizes a provider's circuit state in a few words
+func describeHealth(h providers.ProviderHealth) string {
+	switch h.State {
+	case providers.CircuitOpen:
+		if wait := time.Until(h.RetryAt); wait > 0 {
+			return fmt.Sprintf("unhealthy (circuit open, probe in %s)", wait.Round(time.Second))
+		}
+		return "unhealthy (circuit open, probe due)"
+	case providers.CircuitHalfOpen:
+		return "recovering (circuit half-open)"
+	}
+	if h.Calls == 0 {
+		return "healthy (no calls yet)"
+	}
+	return "healthy"
+}
+
+func joinProviders(pts []types.ProviderType) string {
+	names := make([]string, len(pts))
+	for i, pt := range pts {
+		names[i] = string(pt)
+	}
+	return strings.Join(names, ", ")
+}
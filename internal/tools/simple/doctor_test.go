@@ -0,0 +1,26 @@
+package simple
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+)
+
+func TestDoctorTool_NoProviders(t *testing.T) {
+	cfg := &config.Config{RequestValidation: "clamp"}
+	tool := NewDoctorTool(cfg, providers.NewRegistry(cfg))
+
+	result, err := tool.Execute(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"# Relay Doctor", "No providers configured.", "- no providers configured"} {
+		if !strings.Contains(result.Content, want) {
+			t.Errorf("expected %q in output:\n%s", want, result.Content)
+		}
+	}
+}
@@ -2,14 +2,15 @@ package simple
 
 import (
 	"context"
-	    "fmt"
-	    "strings"
-	
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
-	    "github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
-	)
-	// ListModelsTool lists available models
+	"fmt"
+	"strings"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/config"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
+)
+
+// ListModelsTool lists available models
 type ListModelsTool struct {
 	cfg      *config.Config
 	registry *providers.Registry
@@ -45,7 +46,11 @@ func (t *ListModelsTool) Execute(ctx context.Context, args map[string]any) (*too
 	for _, m := range models {
 		if string(m.Provider) != currentProvider {
 			currentProvider = string(m.Provider)
-			sb.WriteString(fmt.Sprintf("\n## %s\n\n", strings.ToUpper(currentProvider)))
+			status := ""
+			if h := t.registry.ProviderHealth(m.Provider); !h.Healthy() {
+				status = fmt.Sprintf(" (%s)", describeHealth(h))
+			}
+			sb.WriteString(fmt.Sprintf("\n## %s%s\n\n", strings.ToUpper(currentProvider), status))
 		}
 
 		aliases := ""
@@ -63,6 +68,16 @@ func (t *ListModelsTool) Execute(ctx context.Context, args map[string]any) (*too
 		if m.AllowCodeGeneration {
 			features = append(features, "code-gen")
 		}
+		if m.Embedding {
+			if m.EmbeddingDimensions > 0 {
+				features = append(features, fmt.Sprintf("embedding (%d dims)", m.EmbeddingDimensions))
+			} else {
+				features = append(features, "embedding")
+			}
+		}
+		if m.Discovered {
+			features = append(features, "discovered")
+		}
 
 		sb.WriteString(fmt.Sprintf("- **%s**%s\n", m.ModelName, aliases))
 		sb.WriteString(fmt.Sprintf("  - Score: %d | Context: %dk | Features: %s\n",
@@ -70,7 +85,53 @@ func (t *ListModelsTool) Execute(ctx context.Context, args map[string]any) (*too
 			m.ContextWindow/1000,
 			strings.Join(features, ", "),
 		))
+		if m.HasPricing() && m.Embedding {
+			sb.WriteString(fmt.Sprintf("  - Price: $%.2f per 1M input tokens\n", m.InputPricePerMTok))
+		} else if m.HasPricing() {
+			sb.WriteString(fmt.Sprintf("  - Price: $%.2f in / $%.2f out per 1M tokens\n",
+				m.InputPricePerMTok, m.OutputPricePerMTok))
+		}
 	}
 
+	if hidden := t.registry.HiddenModels(); len(hidden) > 0 {
+		sb.WriteString("\n## Hidden by Model Policy\n\n")
+		for _, h := range hidden {
+			sb.WriteString(fmt.Sprintf("- **%s** (%s): %s\n", h.Model.ModelName, h.Model.Provider, h.Reason))
+		}
+	}
+
+	t.writeSpend(&sb)
+
 	return tools.NewToolResult(sb.String()), nil
 }
+
+// writeSpend appends today's spend and the configured budgets
+func (t *ListModelsTool) writeSpend(sb *strings.Builder) {
+	today := t.registry.Costs().Today()
+	budgets := t.cfg.Budgets
+	if today.Calls == 0 && budgets.IsEmpty() {
+		return
+	}
+
+	sb.WriteString("\n## Spend Today\n\n")
+	sb.WriteString(fmt.Sprintf("- Total: $%.4f over %d calls", today.TotalUSD, today.Calls))
+	if budgets.DailyUSD > 0 {
+		sb.WriteString(fmt.Sprintf(" (daily budget $%.2f)", budgets.DailyUSD))
+	}
+	sb.WriteString("\n")
+
+	for _, tool := range providers.SortedSpend(today.Tools) {
+		sb.WriteString(fmt.Sprintf("- Tool %s: $%.4f", tool, today.Tools[tool]))
+		if limit := budgets.PerToolUSD[tool]; limit > 0 {
+			sb.WriteString(fmt.Sprintf(" (budget $%.2f)", limit))
+		}
+		sb.WriteString("\n")
+	}
+	for _, model := range providers.SortedSpend(today.Models) {
+		sb.WriteString(fmt.Sprintf("- Model %s: $%.4f\n", model, today.Models[model]))
+	}
+
+	if budgets.PerThreadUSD > 0 {
+		sb.WriteString(fmt.Sprintf("- Per-thread budget: $%.2f\n", budgets.PerThreadUSD))
+	}
+}
@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+
+	"github.com/Narcoleptic-Fox/relay-mcp/internal/types"
 )
 
 // Tool is the interface all tools must implement
@@ -130,6 +132,37 @@ func (p *ArgumentParser) GetObjectArray(key string) []map[string]any {
 	return result
 }
 
+// GetObject returns an object argument
+func (p *ArgumentParser) GetObject(key string) map[string]any {
+	v, _ := p.args[key].(map[string]any)
+	return v
+}
+
+// GetSampling returns the sampling controls added by
+// SchemaBuilder.AddSamplingParams, leaving absent ones unset
+func (p *ArgumentParser) GetSampling() types.SamplingParams {
+	var s types.SamplingParams
+	if _, ok := p.args["top_p"]; ok {
+		s.TopP = ptr(p.GetFloat("top_p", 0))
+	}
+	if _, ok := p.args["seed"]; ok {
+		s.Seed = ptr(p.GetInt("seed", 0))
+	}
+	s.Stop = p.GetStringArray("stop")
+	if _, ok := p.args["presence_penalty"]; ok {
+		s.PresencePenalty = ptr(p.GetFloat("presence_penalty", 0))
+	}
+	if _, ok := p.args["frequency_penalty"]; ok {
+		s.FrequencyPenalty = ptr(p.GetFloat("frequency_penalty", 0))
+	}
+	s.N = p.GetInt("n", 0)
+	return s
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
 // Error types
 type ErrMissingRequired struct {
 	Field string
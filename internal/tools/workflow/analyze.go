@@ -51,7 +51,7 @@ func (t *AnalyzeTool) Execute(ctx context.Context, args map[string]any) (*tools.
 	    })
 	
 	    if state.UseAssistant {
-	        resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Analyze findings: %s", state.Findings), "You are a software architect.")
+	        resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Analyze findings: %s", state.Findings), "You are a software architect.", state)
 	        if err != nil {
 	            return nil, err
 	        }
@@ -6,11 +6,13 @@ import (
 	    "log/slog"
 	    "strings"
 	
+	    "github.com/Narcoleptic-Fox/relay-mcp/internal/agent"
 	    "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
 	    "github.com/Narcoleptic-Fox/relay-mcp/internal/memory"
 	    "github.com/Narcoleptic-Fox/relay-mcp/internal/providers"
 	    "github.com/Narcoleptic-Fox/relay-mcp/internal/tools"
 	    "github.com/Narcoleptic-Fox/relay-mcp/internal/types"
+	    "github.com/Narcoleptic-Fox/relay-mcp/internal/utils"
 	)
 	// WorkflowTool provides common functionality for multi-step workflow tools
 type WorkflowTool struct {
@@ -51,7 +53,7 @@ func (t *WorkflowTool) addCommonSchema() {
 		AddInteger("total_steps", "Estimated total steps needed", true, intPtr(1), nil).
 		AddBoolean("next_step_required", "Whether another step is needed", true).
 		AddString("findings", "Important discoveries and evidence", true).
-		AddString("model", "Model to use", false).
+		AddString("model", "Model to use, optionally provider-qualified as provider:model (e.g. azure:gpt-4o), or 'auto' for automatic selection", false).
 		AddString("hypothesis", "Current theory based on evidence", false).
 		AddStringEnum("confidence", "Confidence level", []string{
 			"exploring", "low", "medium", "high", "very_high", "almost_certain", "certain",
@@ -63,7 +65,10 @@ func (t *WorkflowTool) addCommonSchema() {
 		AddStringEnum("thinking_mode", "Reasoning depth", []string{
 			"minimal", "low", "medium", "high", "max",
 		}, false).
-		AddNumber("temperature", "0 = deterministic, 1 = creative", false, floatPtr(0.0), floatPtr(1.0))
+		AddNumber("temperature", "0 = deterministic, 1 = creative", false, floatPtr(0.0), floatPtr(1.0)).
+		AddBoolean("no_cache", "Skip the response cache and call the model again", false).
+		AddString("working_directory_absolute_path", "Absolute path to the project; lets the expert model read files under it", false).
+		AddSamplingParams()
 }
 
 func (t *WorkflowTool) Name() string           { return t.name }
@@ -86,6 +91,10 @@ type WorkflowState struct {
 	ThinkingMode     types.ThinkingMode
 	Temperature      float64
 	Model            string
+	NoCache          bool
+	WorkDir          string
+	Sampling         types.SamplingParams
+	ProviderOptions  map[string]any
 }
 
 // ParseWorkflowState extracts workflow state from arguments
@@ -117,6 +126,10 @@ func (t *WorkflowTool) ParseWorkflowState(args map[string]any) (*WorkflowState,
 		ThinkingMode:     types.ThinkingMode(parser.GetString("thinking_mode")),
 		Temperature:      parser.GetFloat("temperature", 0.3),
 		Model:            parser.GetString("model"),
+		NoCache:          parser.GetBool("no_cache", false),
+		WorkDir:          parser.GetString("working_directory_absolute_path"),
+		Sampling:         parser.GetSampling(),
+		ProviderOptions:  parser.GetObject("provider_options"),
 	}, nil
 }
 
@@ -164,31 +177,129 @@ func (t *WorkflowTool) ConsolidateFindings(thread *types.ThreadContext, currentF
 	return strings.Join(allFindings, "\n\n---\n\n")
 }
 
-// CallExpertModel calls a high-intelligence model for final analysis
+// CallExpertModel calls a high-intelligence model for final analysis.
+// The state supplies the thread for cost attribution, the no_cache flag,
+// sampling parameters and provider options, the relevant files sent with the
+// prompt and the working directory the model may read files from.
 func (t *WorkflowTool) CallExpertModel(
 	ctx context.Context,
 	prompt string,
 	systemPrompt string,
+	state *WorkflowState,
 ) (*types.ModelResponse, error) {
-	// Select best available model
-	caps, provider, err := t.registry.SelectBestModel(providers.ModelRequirements{
-		MinIntelligence:     80,
-		NeedsThinking:       true,
-		NeedsCodeGeneration: true,
-	})
+	return t.callExpert(ctx, prompt, systemPrompt, state, nil)
+}
+
+// CallExpertModelJSON calls the expert model for a JSON response matching
+// schema; decode it with providers.DecodeResponse
+func (t *WorkflowTool) CallExpertModelJSON(
+	ctx context.Context,
+	prompt string,
+	systemPrompt string,
+	state *WorkflowState,
+	schema *providers.JSONSchema,
+) (*types.ModelResponse, error) {
+	return t.callExpert(ctx, prompt, systemPrompt, state, schema)
+}
+
+func (t *WorkflowTool) callExpert(
+	ctx context.Context,
+	prompt string,
+	systemPrompt string,
+	state *WorkflowState,
+	schema *providers.JSONSchema,
+) (*types.ModelResponse, error) {
+	files, err := utils.ReadFiles(state.RelevantFiles, state.WorkDir)
+	if err != nil {
+		slog.Warn("error reading files", "error", err)
+	}
+
+	model, provider, err := t.expertModel(ctx, state.Model, prompt, systemPrompt, files)
 	if err != nil {
 		return nil, fmt.Errorf("selecting expert model: %w", err)
 	}
 
-	slog.Info("calling expert model", "model", caps.ModelName, "provider", caps.Provider)
+	thinkingMode := state.ThinkingMode
+	if thinkingMode == "" {
+		thinkingMode = types.ThinkingHigh
+	}
+
+	req := &providers.GenerateRequest{
+		Prompt:          prompt,
+		SystemPrompt:    systemPrompt,
+		Model:           model,
+		Temperature:     state.Temperature,
+		Sampling:        state.Sampling,
+		ProviderOptions: state.ProviderOptions,
+		ThinkingMode:    thinkingMode,
+		ThreadID:        state.ContinuationID,
+		NoCache:         state.NoCache,
+		ResponseSchema:  schema,
+	}
+	fit, err := tools.FitContext(ctx, t.name, provider, req, files)
+	if err != nil {
+		return nil, err
+	}
+	req.Prompt = withFiles(prompt, fit.Files)
 
-	return provider.GenerateContent(ctx, &providers.GenerateRequest{
-		Prompt:       prompt,
-		SystemPrompt: systemPrompt,
-		Model:        caps.ModelName,
-		Temperature:  0.3,
-		ThinkingMode: types.ThinkingHigh,
+	slog.Info("calling expert model", "model", model, "provider", provider.GetProviderType())
+
+	return agent.Generate(ctx, t.cfg, provider, req, state.WorkDir)
+}
+
+// expertModel picks the model for an expert call. A requested model, or the
+// configured default, is used as named, provider qualifier included; "auto"
+// routes to the best model for this tool's task profile that fits the whole
+// request, or failing that the prompt, and FitContext trims the files to it.
+func (t *WorkflowTool) expertModel(
+	ctx context.Context,
+	requested string,
+	prompt string,
+	systemPrompt string,
+	files []utils.FileContent,
+) (string, providers.Provider, error) {
+	if requested == "" {
+		requested = t.cfg.DefaultModel
+	}
+	if requested != "" && requested != "auto" {
+		provider, err := t.registry.GetProviderForModel(requested)
+		if err != nil {
+			return "", nil, err
+		}
+		return requested, provider, nil
+	}
+
+	parts := []string{prompt, systemPrompt}
+	for _, f := range files {
+		parts = append(parts, f.Content)
+	}
+	d, err := t.registry.Route(ctx, providers.ModelRequirements{
+		MinContextWindow: providers.RequiredContext(parts...),
 	})
+	if err != nil && len(files) > 0 {
+		d, err = t.registry.Route(ctx, providers.ModelRequirements{
+			MinContextWindow: providers.RequiredContext(prompt, systemPrompt),
+		})
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return d.Model.ModelName, d.Provider, nil
+}
+
+// withFiles appends the relevant files to an expert prompt
+func withFiles(prompt string, files []utils.FileContent) string {
+	if len(files) == 0 {
+		return prompt
+	}
+
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\n## Relevant Files\n\n")
+	for _, f := range files {
+		sb.WriteString(fmt.Sprintf("### %s\n```\n%s\n```\n\n", f.Path, f.Content))
+	}
+	return sb.String()
 }
 
 // BuildGuidanceResponse creates the response for intermediate steps
@@ -2,6 +2,7 @@ package workflow
 
 import (
 	    "context"
+	    "encoding/json"
 	    "fmt"
 	
 	    "github.com/Narcoleptic-Fox/relay-mcp/internal/config"
@@ -29,7 +30,9 @@ func NewCodeReviewTool(cfg *config.Config, registry *providers.Registry, mem *me
 	tool.schema.
 		AddStringArray("focus_areas", "Areas to focus on (security, performance, style)", false).
 		AddString("pr_context", "Pull request or change context", false).
-		AddBoolean("generate_fix_suggestions", "Generate code fixes for issues", false)
+		AddBoolean("generate_fix_suggestions", "Generate code fixes for issues", false).
+		AddStringEnum("output_format", "markdown (default) or json for a machine-readable review with a score and issue severities",
+			[]string{"markdown", "json"}, false)
 
 	return tool
 }
@@ -44,6 +47,7 @@ func (t *CodeReviewTool) Execute(ctx context.Context, args map[string]any) (*too
 	focusAreas := parser.GetStringArray("focus_areas")
 	prContext := parser.GetString("pr_context")
 	genFixes := parser.GetBool("generate_fix_suggestions", false)
+	outputFormat := parser.GetString("output_format")
 
 	// Get thread
 	thread, _ := t.GetOrCreateThread(state.ContinuationID)
@@ -92,7 +96,12 @@ Provide:
 			expertPrompt += "\n6. Suggested code fixes for major issues"
 		}
 
-		resp, err := t.CallExpertModel(ctx, expertPrompt, "You are a senior principal engineer conducting a final code review sign-off.")
+		systemPrompt := "You are a senior principal engineer conducting a final code review sign-off."
+		if outputFormat == "json" {
+			return t.structuredReview(ctx, expertPrompt, systemPrompt, state)
+		}
+
+		resp, err := t.CallExpertModel(ctx, expertPrompt, systemPrompt, state)
 		if err != nil {
 			return nil, fmt.Errorf("expert analysis: %w", err)
 		}
@@ -110,3 +119,85 @@ Provide:
 	return tools.NewToolResult(fmt.Sprintf("## Review Complete\n\n%s\n\n---\ncontinuation_id: %s",
 		state.Findings, thread.ThreadID)), nil
 }
+
+// CodeReviewResult is the machine-readable review returned for
+// output_format=json
+type CodeReviewResult struct {
+	Summary         string            `json:"summary"`
+	Score           int               `json:"score"`
+	Security        string            `json:"security"`
+	Performance     string            `json:"performance"`
+	Issues          []CodeReviewIssue `json:"issues"`
+	Recommendations []string          `json:"recommendations"`
+}
+
+// CodeReviewIssue is one finding in a CodeReviewResult
+type CodeReviewIssue struct {
+	Severity    string `json:"severity"` // critical, high, medium or low
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	Fix         string `json:"fix"`
+}
+
+// codeReviewSchema follows strict-mode rules: every property required and no
+// additional properties
+var codeReviewSchema = &providers.JSONSchema{
+	Name:   "code_review",
+	Strict: true,
+	Schema: map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"required":             []string{"summary", "score", "security", "performance", "issues", "recommendations"},
+		"properties": map[string]any{
+			"summary":     map[string]any{"type": "string", "description": "Summary of critical issues"},
+			"score":       map[string]any{"type": "integer", "minimum": 1, "maximum": 10, "description": "Code quality and maintainability score"},
+			"security":    map[string]any{"type": "string", "description": "Security assessment"},
+			"performance": map[string]any{"type": "string", "description": "Performance impact"},
+			"issues": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":                 "object",
+					"additionalProperties": false,
+					"required":             []string{"severity", "title", "description", "location", "fix"},
+					"properties": map[string]any{
+						"severity":    map[string]any{"type": "string", "enum": []string{"critical", "high", "medium", "low"}},
+						"title":       map[string]any{"type": "string"},
+						"description": map[string]any{"type": "string"},
+						"location":    map[string]any{"type": "string", "description": "File and line, or empty"},
+						"fix":         map[string]any{"type": "string", "description": "Suggested fix, or empty"},
+					},
+				},
+			},
+			"recommendations": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	},
+}
+
+// structuredReview asks the expert model for a CodeReviewResult and returns
+// it as a JSON block
+func (t *CodeReviewTool) structuredReview(ctx context.Context, prompt, systemPrompt string, state *WorkflowState) (*tools.ToolResult, error) {
+	resp, err := t.CallExpertModelJSON(ctx, prompt, systemPrompt, state, codeReviewSchema)
+	if err != nil {
+		return nil, fmt.Errorf("expert analysis: %w", err)
+	}
+
+	review, err := providers.DecodeResponse[CodeReviewResult](resp)
+	if err != nil {
+		return nil, fmt.Errorf("decoding review: %w", err)
+	}
+
+	data, err := json.MarshalIndent(review, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding review: %w", err)
+	}
+
+	_ = t.memory.AddTurn(state.ContinuationID, types.ConversationTurn{
+		Role:     "assistant",
+		Content:  string(data),
+		ToolName: t.name,
+	})
+
+	return tools.NewToolResult(fmt.Sprintf("## Code Review Complete\n\n```json\n%s\n```\n\n---\ncontinuation_id: %s",
+		data, state.ContinuationID)), nil
+}
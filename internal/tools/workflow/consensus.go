@@ -32,7 +32,7 @@ func NewConsensusTool(cfg *config.Config, registry *providers.Registry, mem *mem
 		AddObjectArray("models", "Models to consult with stance", true, map[string]any{
 			"model": map[string]any{
 				"type":        "string",
-				"description": "Model name",
+				"description": "Model name, optionally provider-qualified (e.g. openrouter:openai/gpt-5)",
 			},
 			"stance": map[string]any{
 				"type":        "string",
@@ -94,6 +94,9 @@ func (t *ConsensusTool) Execute(ctx context.Context, args map[string]any) (*tool
 		if err := t.validateModels(state.Models); err != nil {
 			return nil, err
 		}
+		if err := t.validateProviderOptions(state); err != nil {
+			return nil, err
+		}
 
 		// Store proposal in thread
 		t.AddTurn(thread.ThreadID, types.ConversationTurn{
@@ -175,6 +178,43 @@ continuation_id: %s`, synthesis, thread.ThreadID)
 	return tools.NewToolResult(result), nil
 }
 
+// validateProviderOptions rejects provider options that no consulted
+// model's provider accepts
+func (t *ConsensusTool) validateProviderOptions(state *ConsensusState) error {
+	if len(state.ProviderOptions) == 0 {
+		return nil
+	}
+	var firstErr error
+	for _, model := range state.Models {
+		provider, err := t.registry.GetProviderForModel(model.Model)
+		if err != nil {
+			continue
+		}
+		err = t.registry.CheckProviderOptions(provider.GetProviderType(), state.ProviderOptions, model.Model)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// providerOptionsFor returns the request's provider options if the model's
+// provider accepts all of them. Consulted models can span providers, each
+// with its own allowlist, so the rest get none rather than failing.
+func (t *ConsensusTool) providerOptionsFor(provider providers.Provider, model string, state *ConsensusState) map[string]any {
+	if len(state.ProviderOptions) == 0 {
+		return nil
+	}
+	if err := t.registry.CheckProviderOptions(provider.GetProviderType(), state.ProviderOptions, model); err != nil {
+		slog.Info("not sending provider options", "model", model, "provider", provider.GetProviderType(), "reason", err)
+		return nil
+	}
+	return state.ProviderOptions
+}
+
 // consultModel calls a specific model with its stance
 func (t *ConsensusTool) consultModel(ctx context.Context, model ConsensusModel, state *ConsensusState) (string, error) {
 	// Get provider for this model
@@ -194,10 +234,14 @@ func (t *ConsensusTool) consultModel(ctx context.Context, model ConsensusModel,
 	)
 
 	resp, err := provider.GenerateContent(ctx, &providers.GenerateRequest{
-		Prompt:       prompt,
-		SystemPrompt: systemPrompt,
-		Model:        model.Model,
-		Temperature:  0.7,
+		Prompt:          prompt,
+		SystemPrompt:    systemPrompt,
+		Model:           model.Model,
+		Temperature:     0.7,
+		Sampling:        state.Sampling,
+		ProviderOptions: t.providerOptionsFor(provider, model.Model, state),
+		ThreadID:        state.ContinuationID,
+		NoCache:         state.NoCache,
 	})
 	if err != nil {
 		return "", err
@@ -269,13 +313,12 @@ Structure your response to cover benefits, risks, and overall recommendation.`
 
 // synthesize combines all model responses into a unified recommendation
 func (t *ConsensusTool) synthesize(ctx context.Context, state *ConsensusState) (string, error) {
-	// Use the best available model for synthesis
-	caps, provider, err := t.registry.SelectBestModel(providers.ModelRequirements{
-		MinIntelligence: 80,
-	})
+	// Route to the best available model for synthesis
+	d, err := t.registry.Route(ctx, providers.ModelRequirements{})
 	if err != nil {
 		return "", err
 	}
+	caps, provider := d.Model, d.Provider
 
 	// Build synthesis prompt
 	var sb strings.Builder
@@ -308,10 +351,14 @@ Your role is to:
 Structure your response clearly with the sections requested.`
 
 	resp, err := provider.GenerateContent(ctx, &providers.GenerateRequest{
-		Prompt:       sb.String(),
-		SystemPrompt: systemPrompt,
-		Model:        caps.ModelName,
-		Temperature:  0.5,
+		Prompt:          sb.String(),
+		SystemPrompt:    systemPrompt,
+		Model:           caps.ModelName,
+		Temperature:     0.5,
+		Sampling:        state.Sampling,
+		ProviderOptions: t.providerOptionsFor(provider, caps.ModelName, state),
+		ThreadID:        state.ContinuationID,
+		NoCache:         state.NoCache,
 	})
 	if err != nil {
 		return "", err
@@ -69,7 +69,7 @@ Please provide:
 3. Prevention strategies
 4. Any additional considerations`, consolidated, state.Hypothesis, state.FilesChecked)
 
-		resp, err := t.CallExpertModel(ctx, expertPrompt, t.getSystemPrompt())
+		resp, err := t.CallExpertModel(ctx, expertPrompt, t.getSystemPrompt(), state)
 		if err != nil {
 			return nil, fmt.Errorf("expert analysis: %w", err)
 		}
@@ -108,7 +108,7 @@ Please provide:
 4. Suggested order of execution
 5. Dependencies between steps`, allSteps, state.Findings)
 
-		resp, err := t.CallExpertModel(ctx, expertPrompt, t.getPlannerSystemPrompt())
+		resp, err := t.CallExpertModel(ctx, expertPrompt, t.getPlannerSystemPrompt(), state.WorkflowState)
 		if err != nil {
 			return nil, fmt.Errorf("expert analysis: %w", err)
 		}
@@ -46,7 +46,7 @@ func (t *PrecommitTool) Execute(ctx context.Context, args map[string]any) (*tool
 	        ToolName: t.name,
 	    })
 		if state.UseAssistant {
-		resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Validate commit: %s", state.Findings), "You are a code quality gatekeeper.")
+		resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Validate commit: %s", state.Findings), "You are a code quality gatekeeper.", state)
 		if err != nil {
 			return nil, err
 		}
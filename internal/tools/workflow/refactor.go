@@ -48,7 +48,7 @@ func (t *RefactorTool) Execute(ctx context.Context, args map[string]any) (*tools
 	        ToolName: t.name,
 	    })
 		if state.UseAssistant {
-		resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Analyze refactoring: %s", state.Findings), "You are a refactoring expert.")
+		resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Analyze refactoring: %s", state.Findings), "You are a refactoring expert.", state)
 		if err != nil {
 			return nil, err
 		}
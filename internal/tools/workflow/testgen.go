@@ -48,7 +48,7 @@ func (t *TestGenTool) Execute(ctx context.Context, args map[string]any) (*tools.
 	        ToolName: t.name,
 	    })
 		if state.UseAssistant {
-		resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Generate tests: %s", state.Findings), "You are a QA automation expert.")
+		resp, err := t.CallExpertModel(ctx, fmt.Sprintf("Generate tests: %s", state.Findings), "You are a QA automation expert.", state)
 		if err != nil {
 			return nil, err
 		}
@@ -86,7 +86,7 @@ Provide:
 3. Key insights and recommendations
 4. Areas that may need further investigation`, problemContext, consolidated, state.Hypothesis, focusAreas)
 
-		resp, err := t.CallExpertModel(ctx, expertPrompt, t.getThinkDeepSystemPrompt())
+		resp, err := t.CallExpertModel(ctx, expertPrompt, t.getThinkDeepSystemPrompt(), state)
 		if err != nil {
 			return nil, fmt.Errorf("expert analysis: %w", err)
 		}
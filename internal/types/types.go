@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // ProviderType represents an AI provider
 type ProviderType string
@@ -13,6 +16,13 @@ const (
 	ProviderDIAL       ProviderType = "dial"
 	ProviderOpenRouter ProviderType = "openrouter"
 	ProviderCustom     ProviderType = "custom"
+	ProviderMock       ProviderType = "mock" // scripted responses for offline testing
+)
+
+// API selects which endpoint an OpenAI-compatible model is served from
+const (
+	APIChatCompletions = "chat_completions"
+	APIResponses       = "responses"
 )
 
 // ModelCapabilities defines what a model can do
@@ -34,10 +44,121 @@ type ModelCapabilities struct {
 	SupportsStreaming        bool `json:"supports_streaming"`
 	SupportsVision           bool `json:"supports_vision"`
 	AllowCodeGeneration      bool `json:"allow_code_generation"`
+	SupportsJSONSchema       bool `json:"supports_json_schema,omitempty"`      // native schema-constrained output
+	SupportsFunctionCalling  bool `json:"supports_function_calling,omitempty"` // can request tool calls
 
 	// Temperature constraints
 	MinTemperature *float64 `json:"min_temperature,omitempty"`
 	MaxTemperature *float64 `json:"max_temperature,omitempty"`
+
+	// Azure deployment mapping. Deployment defaults to the model name and
+	// APIVersion to AZURE_OPENAI_API_VERSION.
+	Deployment string `json:"deployment,omitempty"`
+	APIVersion string `json:"api_version,omitempty"`
+
+	// Pricing in USD per million tokens. Thinking tokens default to the output
+	// price and cached input to the input price.
+	InputPricePerMTok       float64 `json:"input_price_per_mtok,omitempty"`
+	OutputPricePerMTok      float64 `json:"output_price_per_mtok,omitempty"`
+	ThinkingPricePerMTok    float64 `json:"thinking_price_per_mtok,omitempty"`
+	CachedInputPricePerMTok float64 `json:"cached_input_price_per_mtok,omitempty"`
+
+	// API is the endpoint used for this model: chat_completions (default) or responses
+	API string `json:"api,omitempty"`
+
+	// Embedding models turn text into vectors through Embed and are never
+	// selected for generation. EmbeddingDimensions is the vector size, or 0
+	// when unknown.
+	Embedding           bool `json:"embedding,omitempty"`
+	EmbeddingDimensions int  `json:"embedding_dimensions,omitempty"`
+
+	// Defaults fill sampling parameters and provider options a request
+	// leaves unset
+	Defaults *ModelDefaults `json:"defaults,omitempty"`
+
+	// Discovered is set for models found via the provider's /models endpoint
+	// rather than the curated registry files
+	Discovered bool `json:"discovered,omitempty"`
+}
+
+// UnmarshalJSON defaults supports_system_prompts to true, so registries
+// written before the field was honored keep sending system prompts
+func (m *ModelCapabilities) UnmarshalJSON(data []byte) error {
+	type plain ModelCapabilities
+	p := plain{SupportsSystemPrompts: true}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*m = ModelCapabilities(p)
+	return nil
+}
+
+// SamplingParams are optional sampling controls. Unset fields leave the
+// provider's default.
+type SamplingParams struct {
+	TopP             *float64 `json:"top_p,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	N                int      `json:"n,omitempty"` // completions to generate; extras are returned as alternatives
+}
+
+// WithDefaults fills the unset fields from d
+func (s SamplingParams) WithDefaults(d SamplingParams) SamplingParams {
+	if s.TopP == nil {
+		s.TopP = d.TopP
+	}
+	if s.Seed == nil {
+		s.Seed = d.Seed
+	}
+	if s.Stop == nil {
+		s.Stop = d.Stop
+	}
+	if s.PresencePenalty == nil {
+		s.PresencePenalty = d.PresencePenalty
+	}
+	if s.FrequencyPenalty == nil {
+		s.FrequencyPenalty = d.FrequencyPenalty
+	}
+	if s.N == 0 {
+		s.N = d.N
+	}
+	return s
+}
+
+// ModelDefaults are per-model request defaults from the model registry
+type ModelDefaults struct {
+	SamplingParams
+	ProviderOptions map[string]any `json:"provider_options,omitempty"`
+}
+
+// HasPricing reports whether the model has prices configured
+func (m *ModelCapabilities) HasPricing() bool {
+	return m.InputPricePerMTok > 0 || m.OutputPricePerMTok > 0
+}
+
+// Cost returns the USD cost of a call. Cached tokens are a subset of the
+// prompt tokens and thinking tokens a subset of the completion tokens.
+func (m *ModelCapabilities) Cost(usage TokenUsage) float64 {
+	cachedPrice := m.CachedInputPricePerMTok
+	if cachedPrice == 0 {
+		cachedPrice = m.InputPricePerMTok
+	}
+	thinkingPrice := m.ThinkingPricePerMTok
+	if thinkingPrice == 0 {
+		thinkingPrice = m.OutputPricePerMTok
+	}
+
+	cached := min(usage.CachedTokens, usage.PromptTokens)
+	thinking := min(usage.ThinkingTokens, usage.CompletionTokens)
+
+	cost := float64(usage.PromptTokens-cached)*m.InputPricePerMTok +
+		float64(cached)*cachedPrice +
+		float64(usage.CompletionTokens-thinking)*m.OutputPricePerMTok +
+		float64(thinking)*thinkingPrice
+
+	return cost / 1_000_000
 }
 
 // ModelResponse is the unified response from any provider
@@ -47,15 +168,30 @@ type ModelResponse struct {
 	Provider     ProviderType   `json:"provider"`
 	TokensUsed   TokenUsage     `json:"tokens_used"`
 	FinishReason string         `json:"finish_reason,omitempty"`
+	CostUSD      float64        `json:"cost_usd,omitempty"` // zero when unpriced or served from cache
+	ToolCalls    []ToolCall     `json:"tool_calls,omitempty"`
+	Alternatives []string       `json:"alternatives,omitempty"` // further completions when more than one was requested
 	Metadata     map[string]any `json:"metadata,omitempty"`
 }
 
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON object
+
+	// Signature is an opaque provider token that must be sent back with the
+	// call, such as a Gemini thought signature
+	Signature string `json:"signature,omitempty"`
+}
+
 // TokenUsage tracks token consumption
 type TokenUsage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
 	ThinkingTokens   int `json:"thinking_tokens,omitempty"`
+	CachedTokens     int `json:"cached_tokens,omitempty"` // prompt tokens served from the provider's prompt cache
 }
 
 // ConversationTurn represents a single turn in a conversation
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestModelCapabilities_SystemPromptDefault(t *testing.T) {
+	var models []ModelCapabilities
+	data := `[
+		{"model_name": "legacy", "context_window": 32000},
+		{"model_name": "no-system", "supports_system_prompts": false}
+	]`
+	if err := json.Unmarshal([]byte(data), &models); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(models) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(models))
+	}
+	if !models[0].SupportsSystemPrompts {
+		t.Error("expected a model without supports_system_prompts to keep system prompts")
+	}
+	if models[1].SupportsSystemPrompts {
+		t.Error("expected an explicit false to be kept")
+	}
+	if models[0].ModelName != "legacy" || models[0].ContextWindow != 32000 {
+		t.Errorf("unexpected model %+v", models[0])
+	}
+}
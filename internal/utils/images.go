@@ -35,6 +35,11 @@ func ProcessImages(images []string) []ImageData {
 	return result
 }
 
+// DataURI returns the image as a data URI (data:image/png;base64,...)
+func (d ImageData) DataURI() string {
+	return "data:" + d.MimeType + ";base64," + d.Base64
+}
+
 func processImage(img string) (ImageData, error) {
 	// Check if it's a data URI (data:image/jpeg;base64,...)
 	if strings.HasPrefix(img, "data:") {